@@ -1,27 +1,45 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/cookie"
 	"github.com/perpus_backend/pkg/cors"
+	"github.com/perpus_backend/pkg/csrf"
+	"github.com/perpus_backend/pkg/encrypt"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/pkg/limiter"
+	"github.com/perpus_backend/pkg/mail"
+	"github.com/perpus_backend/pkg/oidc"
+	"github.com/perpus_backend/pkg/requestid"
+	"github.com/perpus_backend/pkg/search"
+	"github.com/perpus_backend/pkg/secure"
+	"github.com/perpus_backend/pkg/static"
+	"github.com/perpus_backend/pkg/upload"
+	"github.com/perpus_backend/service/apikey"
+	auditlog "github.com/perpus_backend/service/audit_log"
 	"github.com/perpus_backend/service/auth"
 	"github.com/perpus_backend/service/book"
 	"github.com/perpus_backend/service/circulation"
 	"github.com/perpus_backend/service/member"
 	"github.com/perpus_backend/service/role"
 	roleuser "github.com/perpus_backend/service/role_user"
+	"github.com/perpus_backend/service/session"
+	"github.com/perpus_backend/service/sso"
+	"github.com/perpus_backend/service/suggest"
+	"github.com/perpus_backend/service/twofactor"
 	"github.com/perpus_backend/service/user"
 	"github.com/perpus_backend/service/websocket"
+	"github.com/perpus_backend/types"
 
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/time/rate"
 )
 
 type APIServer struct {
@@ -39,42 +57,88 @@ func NewAPIServer(addr string, db *sql.DB, rdb *redis.Client) *APIServer {
 	}
 }
 
-var publicURLHandler = http.StripPrefix("/public/", http.FileServer(http.Dir("./assets/public")))
+var publicURLHandler = http.StripPrefix("/public", static.FileServer("./assets/public", config.Env.StaticCacheMaxAge))
 
 func (s *APIServer) Run() error {
+	// the member personal data can't be read without the keys
+	if err := encrypt.Check(); err != nil {
+		return err
+	}
+
+	// the csrf token must be valid at every replica
+	if err := csrf.Check(); err != nil {
+		return err
+	}
+
+	// the reset password and verification mail can't only be logged at production
+	if err := mail.Check(); err != nil {
+		return err
+	}
+
 	r := mux.NewRouter()
+	r.Use(requestid.Middleware)
+	r.Use(secure.HeadersMiddleware)
 	r.Use(cookie.CookieMiddleware)
-	r.Use(cors.CORSMiddleware)
-
-	// for ensures that OPTIONS "/" is not thrown to 404 (which does not have a CORS header).
-	r.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNoContent)
-	})
+	r.Use(csrf.Middleware)
 
+	// suggest is called on every keystroke, so it has own limiter. it must be before "/api" subrouter to be matched first.
+	suggestSubrouter := r.PathPrefix("/api").Subrouter()
 	subrouter := r.PathPrefix("/api").Subrouter()
+	wsSubrouter := r.PathPrefix("/ws").Subrouter()
+	publicSubrouter := r.PathPrefix("/public/").Subrouter()
 
-	// limiter for env production
-	if config.Env.AppENV == "production" {
-		r.Use(limiter.SetRateLimitMiddleware(rate.Every(1*time.Hour), 3000))
-		subrouter.Use(limiter.SetRateLimitMiddleware(rate.Every(1*time.Minute), 10))
+	// the other routes (/profile, /private, jwks), it match every path so it must be the last
+	rootSubrouter := r.NewRoute().Subrouter()
+
+	// each group has own cors policy, and the OPTIONS at the group is answered by it.
+	if err := useCORS(suggestSubrouter, subrouter, wsSubrouter, publicSubrouter, rootSubrouter); err != nil {
+		return err
 	}
 
-	// for ensures that OPTIONS "/api" is not thrown to 404 (which does not have a CORS header).
-	subrouter.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNoContent)
-	})
+	// administrative actions are recorded at the audit log, the old log is deleted once a day
+	auditStore := auditlog.NewStore(s.db)
+	audit.SetStore(auditStore)
+	audit.StartRetention(context.Background(), auditStore, config.Env.AuditRetention, 24*time.Hour)
+
+	if config.Env.ClamAVAddress != "" {
+		upload.SetScanner(upload.NewClamAV(config.Env.ClamAVAddress, config.Env.ClamAVTimeout))
+	}
 
 	userStore := user.NewStore(s.db, s.rdb)
 
-	jwt := jwt.NewAuthJWT(userStore, s.rdb)
+	sessionStore := session.NewStore(s.rdb)
+
+	keys, err := jwt.LoadKeyring(config.Env.JWTKeysDir, config.Env.JWTAlg)
+	if err != nil {
+		return err
+	}
+
+	// the permissions of roles is stored at role store
+	roleStore := role.NewStore(s.db, s.rdb)
+
+	apiKeyStore := apikey.NewStore(s.db, s.rdb)
+
+	jwt := jwt.NewAuthJWT(userStore, sessionStore, roleStore, apiKeyStore, keys, s.rdb)
+
+	// limiter for env production, each client (user, api key, or ip) has own bucket at redis
+	if config.Env.AppENV == "production" {
+		if err := s.useRateLimiter(r, subrouter, suggestSubrouter, jwt); err != nil {
+			return err
+		}
+	}
+
+	// public keys for other services to verify our tokens
+	rootSubrouter.HandleFunc("/.well-known/jwks.json", jwt.HandleJWKS).Methods(http.MethodGet)
+
+	// failed login counter and lockout, it's shared by the login and the change password
+	authStore := auth.NewStore(s.rdb)
 
 	// user routes
-	userHandler := user.NewHandler(jwt, userStore)
+	userHandler := user.NewHandler(jwt, userStore, authStore)
 	userHandler.RegisterRoutes(subrouter)
 
 	// role routes
-	roleStore := role.NewStore(s.db, s.rdb)
-	roleHandler := role.NewHandler(jwt, roleStore, userStore)
+	roleHandler := role.NewHandler(jwt, roleStore, roleStore, userStore)
 	roleHandler.RegisterRoutes(subrouter)
 
 	// role_user routes
@@ -87,32 +151,170 @@ func (s *APIServer) Run() error {
 	bookHandler := book.NewHandler(jwt, bookStore, userStore)
 	bookHandler.RegisterRoutes(subrouter)
 
-	// circulation routes
+	// member store, the handler is registered after the search indexer
+	memberStore := member.NewStore(s.db, s.rdb)
+
+	// the rows which is plaintext or sealed by the rotated key are encrypted with the active key,
+	// then the circulations before member_id is linked by the blind index
+	go func() {
+		updated, err := memberStore.RekeyMembers(context.Background())
+		if err != nil {
+			log.Printf("rekey members: %v", err)
+			return
+		} else if updated > 0 {
+			log.Printf("rekey members: %d members updated", updated)
+		}
+
+		linked, err := memberStore.LinkCirculations(context.Background())
+		if err != nil {
+			log.Printf("link circulations: %v", err)
+		} else if linked > 0 {
+			log.Printf("link circulations: %d circulations linked", linked)
+		}
+	}()
+
+	// the memberships which is passed berlaku_sampai is expired once an hour
+	member.StartExpiry(context.Background(), memberStore, 1*time.Hour)
+
+	// circulation routes, the inactive member is blocked at checkout
 	circulationStore := circulation.NewStore(s.db, s.rdb)
-	circulationHandler := circulation.NewHandler(jwt, circulationStore, userStore)
+	circulationHandler := circulation.NewHandler(jwt, circulationStore, userStore, memberStore)
 	circulationHandler.RegisterRoutes(subrouter)
 
-	// member routes
-	memberStore := member.NewStore(s.db, s.rdb)
-	memberHandler := member.NewHandler(jwt, memberStore, userStore)
-	memberHandler.RegisterRoutes(subrouter)
-
 	// auth routes
-	authHandler := auth.NewHandler(jwt, userStore)
+	mailer, err := mail.NewMailSender(config.Env.MailDriver)
+	if err != nil {
+		return err
+	}
+
+	twoFactorStore := twofactor.NewStore(s.db, s.rdb)
+
+	authHandler := auth.NewHandler(jwt, userStore, authStore, authStore, twoFactorStore, mailer)
 	authHandler.RegisterRoutes(subrouter)
 
+	// csrf token for the client app, it's sent back at X-CSRF-Token header
+	subrouter.HandleFunc("/csrf", csrf.HandleToken).Methods(http.MethodGet)
+
+	// sso routes, only when the identity provider is configured
+	if config.Env.OIDCIssuer != "" {
+		provider := oidc.NewProvider(config.Env.OIDCIssuer, config.Env.OIDCClientID, config.Env.OIDCClientSecret, config.Env.OIDCRedirectURL, config.Env.OIDCScopes)
+
+		ssoStore := sso.NewStore(s.db, s.rdb)
+		ssoHandler := sso.NewHandler(jwt, provider, ssoStore, userStore, roleStore, roleUserStore, authStore)
+		ssoHandler.RegisterRoutes(subrouter)
+	}
+
+	// api key routes, for kiosk and integration script
+	apiKeyHandler := apikey.NewHandler(jwt, apiKeyStore)
+	apiKeyHandler.RegisterRoutes(subrouter)
+
+	// audit log routes
+	auditHandler := auditlog.NewHandler(jwt, auditStore)
+	auditHandler.RegisterRoutes(subrouter)
+
+	// two factor routes
+	twoFactorHandler := twofactor.NewHandler(jwt, twoFactorStore, userStore)
+	twoFactorHandler.RegisterRoutes(subrouter)
+
+	// session routes
+	sessionHandler := session.NewHandler(jwt, sessionStore, userStore)
+	sessionHandler.RegisterRoutes(subrouter)
+
 	// search routes
-	wsSubrouter := r.PathPrefix("/ws").Subrouter()
-	wsHandler := websocket.NewHandler(jwt, userStore, roleStore, memberStore, bookStore, circulationStore)
+	searchIndex, err := search.NewSearchIndex(config.Env.SearchDriver)
+	if err != nil {
+		return err
+	}
+
+	indexer := search.NewIndexer(searchIndex, search.NewResources(userStore, roleStore, memberStore, bookStore, circulationStore), 1*time.Minute)
+	if err := indexer.Configure(context.Background()); err != nil {
+		return err
+	}
+
+	// member routes, it purge the search copies at anonymization
+	memberHandler := member.NewHandler(jwt, memberStore, userStore, indexer)
+	memberHandler.RegisterRoutes(subrouter)
+
+	wsHandler := websocket.NewHandler(jwt, indexer)
 	wsHandler.RegisterRoutes(wsSubrouter)
 
-	r.PathPrefix("/public/").Handler(publicURLHandler).Methods(http.MethodGet) // set accessing files across public url.
+	// suggest routes
+	suggestStore := suggest.NewStore(s.rdb)
+	suggestHandler := suggest.NewHandler(jwt, suggestStore, indexer)
+	suggestHandler.RegisterRoutes(suggestSubrouter)
+
+	publicSubrouter.Methods(http.MethodGet, http.MethodHead).Handler(publicURLHandler) // set accessing files across public url.
 
 	// get info logged profile
-	r.HandleFunc("/profile", jwt.AuthWithJWTToken(userHandler.HandleGetProfileUser)).Methods(http.MethodGet)
+	rootSubrouter.HandleFunc("/profile", jwt.AuthWithJWTToken(userHandler.HandleGetProfileUser)).Methods(http.MethodGet)
 
 	// set accessing files across private routes. Which means, it is need to login auth.
-	r.HandleFunc("/private/{filename:.+}", jwt.AuthWithJWTToken(jwt.RoleGate(authHandler.PrivateURLHandler, "admin", "staff", "user"))).Methods(http.MethodGet)
+	rootSubrouter.HandleFunc("/private/{filename:.+}", jwt.AuthWithJWTToken(jwt.RequirePermission(authHandler.PrivateURLHandler, types.PermFilesRead))).Methods(http.MethodGet)
 
 	return http.ListenAndServe(s.addr, r)
 }
+
+func (s *APIServer) useRateLimiter(r, subrouter, suggestSubrouter *mux.Router, j *jwt.AuthJWT) error {
+	l, err := limiter.NewLimiter(s.rdb, j.RateLimitKey, config.Env.RateLimitExempt)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range []struct {
+		name, rule string
+		router     *mux.Router
+	}{
+		{"global", config.Env.RateLimitGlobal, r},
+		{"api", config.Env.RateLimitAPI, subrouter},
+		{"suggest", config.Env.RateLimitSuggest, suggestSubrouter},
+	} {
+		rule, err := limiter.ParseRule(group.rule)
+		if err != nil {
+			return err
+		}
+
+		group.router.Use(l.Middleware(group.name, rule))
+	}
+
+	// the validated api key has own bucket, the middleware above only know its ip
+	apiKeyRule, err := limiter.ParseRule(config.Env.RateLimitAPIKey)
+	if err != nil {
+		return err
+	}
+
+	j.SetAPIKeyLimit(func(w http.ResponseWriter, r *http.Request, client string) bool {
+		return l.Limit(w, r, "api_key", client, apiKeyRule)
+	})
+
+	return nil
+}
+
+func useCORS(suggestSubrouter, subrouter, wsSubrouter, publicSubrouter, rootSubrouter *mux.Router) error {
+	for _, group := range []struct {
+		methods     []string
+		credentials bool // the refresh cookie is sent cross origin
+		routers     []*mux.Router
+	}{
+		{[]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}, true, []*mux.Router{suggestSubrouter, subrouter, rootSubrouter}},
+		{[]string{http.MethodGet}, true, []*mux.Router{wsSubrouter}},
+		{[]string{http.MethodGet, http.MethodHead}, false, []*mux.Router{publicSubrouter}},
+	} {
+		policy, err := cors.NewPolicy(cors.Options{
+			Origins:        config.Env.CORSOrigins,
+			Methods:        group.methods,
+			Headers:        config.Env.CORSHeaders,
+			ExposedHeaders: config.Env.CORSExposedHeaders,
+			MaxAge:         config.Env.CORSMaxAge,
+			Credentials:    group.credentials,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, router := range group.routers {
+			policy.Register(router)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/jwt"
+)
+
+// admin command for rotate the jwt signing keys.
+//
+//	go run cmd/keys/main.go rotate          -> make new signing key, and remove the old keys except the newest 3
+//	go run cmd/keys/main.go -keep 2 rotate
+//	go run cmd/keys/main.go -alg RS256 rotate
+//
+// the new key is published at JWKS first, and the server start signing with it after jwt.KeyActivationDelay.
+// the old key is only removed when the tokens signed by it are expired (the access token ttl after the next key is activated).
+func main() {
+	alg := flag.String("alg", config.Env.JWTAlg, "algorithm of the new key, RS256 or EdDSA")
+	keep := flag.Int("keep", 3, "how many newest keys are kept")
+	flag.Parse()
+
+	if flag.Arg(0) != "rotate" {
+		log.Fatalf("invalid command: %q, only rotate", flag.Arg(0))
+	}
+
+	kid, err := jwt.GenerateKey(config.Env.JWTKeysDir, *alg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("New signing key: %s", kid)
+
+	removed, err := jwt.PruneKeys(config.Env.JWTKeysDir, *keep, config.Env.AccessTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, kid := range removed {
+		log.Printf("Removed key: %s", kid)
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE `books`
+DROP COLUMN `kategori`,
+DROP COLUMN `bahasa`;
@@ -0,0 +1,3 @@
+ALTER TABLE `books`
+ADD COLUMN `kategori` VARCHAR(100) NOT NULL DEFAULT '-' AFTER `tahun`,
+ADD COLUMN `bahasa` VARCHAR(50) NOT NULL DEFAULT '-' AFTER `kategori`;
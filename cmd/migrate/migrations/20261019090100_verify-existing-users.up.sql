@@ -0,0 +1,2 @@
+-- the existing users was registered before the verification, so they are treated as verified.
+UPDATE `users` SET `email_verified_at` = `created_at` WHERE `email_verified_at` IS NULL;
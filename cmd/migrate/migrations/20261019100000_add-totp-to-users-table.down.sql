@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+DROP COLUMN `totp_secret`,
+DROP COLUMN `totp_enabled_at`;
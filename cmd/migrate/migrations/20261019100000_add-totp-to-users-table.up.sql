@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+ADD COLUMN `totp_secret` VARCHAR(64) NULL DEFAULT NULL AFTER `email_verified_at`,
+ADD COLUMN `totp_enabled_at` TIMESTAMP NULL DEFAULT NULL AFTER `totp_secret`;
@@ -0,0 +1,11 @@
+CREATE TABLE
+    IF NOT EXISTS `recovery_codes` (
+        `id` CHAR(36) NOT NULL,
+        `user_id` CHAR(36) NOT NULL,
+        `code_hash` CHAR(64) NOT NULL,
+        `used_at` TIMESTAMP NULL DEFAULT NULL,
+        `created_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (`id`),
+        UNIQUE KEY (`user_id`, `code_hash`),
+        CONSTRAINT `fk_recovery_codes_user_id` FOREIGN KEY (`user_id`) REFERENCES users (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+    );
@@ -0,0 +1,12 @@
+CREATE TABLE
+    IF NOT EXISTS `user_identities` (
+        `id` CHAR(36) NOT NULL,
+        `user_id` CHAR(36) NOT NULL,
+        `provider` VARCHAR(255) NOT NULL,
+        `subject` VARCHAR(255) NOT NULL,
+        `email` VARCHAR(255) NOT NULL DEFAULT '',
+        `created_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (`id`),
+        UNIQUE KEY (`provider`, `subject`),
+        CONSTRAINT `fk_user_identities_user_id` FOREIGN KEY (`user_id`) REFERENCES users (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+    );
@@ -0,0 +1,8 @@
+CREATE TABLE
+    IF NOT EXISTS `role_permissions` (
+        `role_id` CHAR(36) NOT NULL,
+        `permission` VARCHAR(64) NOT NULL,
+        `created_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (`role_id`, `permission`),
+        CONSTRAINT `fk_role_permissions_role_id` FOREIGN KEY (`role_id`) REFERENCES roles (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+    );
@@ -0,0 +1,28 @@
+-- the existing roles get the same access as the role gate before, see types.DefaultRolePermissions.
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.id, p.permission FROM roles r
+JOIN (
+    SELECT 'admin' AS role_name, 'books:read' AS permission
+    UNION ALL SELECT 'admin', 'books:write'
+    UNION ALL SELECT 'admin', 'members:read'
+    UNION ALL SELECT 'admin', 'members:write'
+    UNION ALL SELECT 'admin', 'circulations:read'
+    UNION ALL SELECT 'admin', 'circulations:write'
+    UNION ALL SELECT 'admin', 'circulations:return'
+    UNION ALL SELECT 'admin', 'users:admin'
+    UNION ALL SELECT 'admin', 'roles:admin'
+    UNION ALL SELECT 'admin', 'search:read'
+    UNION ALL SELECT 'admin', 'files:read'
+    UNION ALL SELECT 'staff', 'books:read'
+    UNION ALL SELECT 'staff', 'books:write'
+    UNION ALL SELECT 'staff', 'members:read'
+    UNION ALL SELECT 'staff', 'members:write'
+    UNION ALL SELECT 'staff', 'circulations:read'
+    UNION ALL SELECT 'staff', 'circulations:write'
+    UNION ALL SELECT 'staff', 'circulations:return'
+    UNION ALL SELECT 'staff', 'search:read'
+    UNION ALL SELECT 'staff', 'files:read'
+    UNION ALL SELECT 'user', 'books:read'
+    UNION ALL SELECT 'user', 'search:read'
+    UNION ALL SELECT 'user', 'files:read'
+) p ON p.role_name = r.name;
@@ -0,0 +1,16 @@
+CREATE TABLE
+    IF NOT EXISTS `api_keys` (
+        `id` CHAR(36) NOT NULL,
+        `name` VARCHAR(100) NOT NULL,
+        `prefix` VARCHAR(16) NOT NULL,
+        `key_hash` CHAR(64) NOT NULL,
+        `permissions` TEXT NOT NULL,
+        `created_by` CHAR(36) NULL DEFAULT NULL,
+        `expires_at` TIMESTAMP NULL DEFAULT NULL,
+        `last_used_at` TIMESTAMP NULL DEFAULT NULL,
+        `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+        `created_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (`id`),
+        UNIQUE KEY (`key_hash`),
+        CONSTRAINT `fk_api_keys_created_by` FOREIGN KEY (`created_by`) REFERENCES users (`id`) ON DELETE SET NULL ON UPDATE CASCADE
+    );
@@ -0,0 +1,18 @@
+CREATE TABLE
+    IF NOT EXISTS `audit_log` (
+        `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+        `actor_id` VARCHAR(64) NULL DEFAULT NULL,
+        `actor_type` VARCHAR(16) NOT NULL,
+        `action` VARCHAR(64) NOT NULL,
+        `resource` VARCHAR(64) NOT NULL,
+        `resource_id` VARCHAR(64) NULL DEFAULT NULL,
+        `changes` JSON NULL DEFAULT NULL,
+        `ip` VARCHAR(45) NOT NULL,
+        `request_id` VARCHAR(64) NOT NULL,
+        `status` SMALLINT UNSIGNED NOT NULL,
+        `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (`id`),
+        KEY (`created_at`),
+        KEY (`actor_id`),
+        KEY (`resource`, `resource_id`)
+    );
@@ -0,0 +1,8 @@
+ALTER TABLE `members`
+DROP INDEX `idx_members_nama_bidx`,
+DROP INDEX `idx_members_no_telepon_bidx`,
+DROP COLUMN `nama_bidx`,
+DROP COLUMN `no_telepon_bidx`,
+DROP COLUMN `tanggal_lahir`,
+MODIFY COLUMN `nama` VARCHAR(255) NOT NULL,
+MODIFY COLUMN `no_telepon` VARCHAR(100) NOT NULL;
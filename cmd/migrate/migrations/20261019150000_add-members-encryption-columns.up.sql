@@ -0,0 +1,8 @@
+ALTER TABLE `members`
+MODIFY COLUMN `nama` VARCHAR(512) NOT NULL,
+MODIFY COLUMN `no_telepon` VARCHAR(512) NOT NULL,
+ADD COLUMN `nama_bidx` CHAR(64) NULL AFTER `nama`,
+ADD COLUMN `no_telepon_bidx` CHAR(64) NULL AFTER `no_telepon`,
+ADD COLUMN `tanggal_lahir` DATE NULL AFTER `profil_anggota`,
+ADD INDEX `idx_members_nama_bidx` (`nama_bidx`),
+ADD INDEX `idx_members_no_telepon_bidx` (`no_telepon_bidx`);
@@ -0,0 +1,2 @@
+ALTER TABLE `members`
+DROP COLUMN `anonymized_at`;
@@ -0,0 +1,2 @@
+ALTER TABLE `members`
+ADD COLUMN `anonymized_at` TIMESTAMP NULL AFTER `tanggal_lahir`;
@@ -0,0 +1 @@
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`) SELECT r.id, 'members:privacy' FROM roles r WHERE r.name = 'admin';
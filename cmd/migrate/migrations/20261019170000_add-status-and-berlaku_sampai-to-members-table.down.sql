@@ -0,0 +1,4 @@
+ALTER TABLE `members`
+DROP INDEX `idx_members_status_berlaku_sampai`,
+DROP COLUMN `berlaku_sampai`,
+DROP COLUMN `status`;
@@ -0,0 +1,4 @@
+ALTER TABLE `members`
+ADD COLUMN `status` ENUM('active', 'suspended', 'expired', 'graduated') NOT NULL DEFAULT 'active' AFTER `anonymized_at`,
+ADD COLUMN `berlaku_sampai` DATE NULL AFTER `status`,
+ADD INDEX `idx_members_status_berlaku_sampai` (`status`, `berlaku_sampai`);
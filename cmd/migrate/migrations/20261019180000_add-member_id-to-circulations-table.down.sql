@@ -0,0 +1,3 @@
+ALTER TABLE `circulations`
+DROP FOREIGN KEY `fk_circulations_member_id`,
+DROP COLUMN `member_id`;
@@ -0,0 +1,3 @@
+ALTER TABLE `circulations`
+ADD COLUMN `member_id` CHAR(36) NULL AFTER `peminjam`,
+ADD CONSTRAINT `fk_circulations_member_id` FOREIGN KEY (`member_id`) REFERENCES members (`id`) ON DELETE SET NULL ON UPDATE CASCADE;
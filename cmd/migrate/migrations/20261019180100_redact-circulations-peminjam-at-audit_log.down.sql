@@ -0,0 +1,2 @@
+-- the redacted names can't be restored
+SELECT 1;
@@ -0,0 +1,6 @@
+UPDATE `audit_log`
+SET `changes` = JSON_SET(`changes`, '$.peminjam', JSON_OBJECT(
+    'before', IF(JSON_TYPE(JSON_EXTRACT(`changes`, '$.peminjam.before')) = 'NULL', NULL, '[redacted]'),
+    'after', IF(JSON_TYPE(JSON_EXTRACT(`changes`, '$.peminjam.after')) = 'NULL', NULL, '[redacted]')
+))
+WHERE `resource` = 'circulations' AND JSON_CONTAINS_PATH(`changes`, 'one', '$.peminjam');
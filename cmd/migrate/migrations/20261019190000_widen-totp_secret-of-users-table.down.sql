@@ -0,0 +1,2 @@
+ALTER TABLE `users`
+MODIFY COLUMN `totp_secret` VARCHAR(64) NULL DEFAULT NULL;
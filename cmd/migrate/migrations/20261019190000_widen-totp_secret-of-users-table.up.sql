@@ -0,0 +1,2 @@
+ALTER TABLE `users`
+MODIFY COLUMN `totp_secret` VARCHAR(512) NULL DEFAULT NULL;
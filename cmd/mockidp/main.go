@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/oidc/oidctest"
+)
+
+// local identity provider for try the sso login without the real one.
+//
+//	go run cmd/mockidp/main.go -addr :9090
+//
+// then set OIDC_ISSUER=http://localhost:9090 and OIDC_CLIENT_ID same as -client at env.
+// every login is approved automatically, the user can be changed per login by add sub, email and name query at the authorize url.
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9090", "issuer url, must be same as OIDC_ISSUER")
+	client := flag.String("client", config.Env.OIDCClientID, "client id")
+	email := flag.String("email", "staff@perpus.test", "email of the logged in user")
+	name := flag.String("name", "Mock Staff", "name of the logged in user")
+	flag.Parse()
+
+	idp, err := oidctest.NewIdP(*issuer, *client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	idp.User.Email = *email
+	idp.User.Name = *name
+
+	log.Printf("Mock identity provider running at %s, issuer %s", *addr, *issuer)
+
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
@@ -4,13 +4,26 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	AppENV, AppURL, ClientPort, CookieName, CookieValue, DBUser, DBPassword, DBName, DBAddress, LocalAddress, MeilisearchURL, MSApiKey, Port, RedisAddress, RedisClient, RedisPassword, JWTSecret, SessionDomain string
+	AppENV, AppURL, BlindIndexKey, ClamAVAddress, ClientPort, ContentSecurityPolicy, CookieName, CookieValue, CSRFSecret, DBUser, DBPassword, DBName, DBAddress, LocalAddress, MeilisearchURL, MSApiKey, Port, RedisAddress, RedisClient, RedisPassword, JWTAlg, JWTKeysDir, MailDriver, MailDir, MailFrom, OIDCIssuer, OIDCClientID, OIDCClientSecret, OIDCClientRedirectURL, OIDCRedirectURL, OIDCDefaultRole, QuarantineDir, RateLimitGlobal, RateLimitAPI, RateLimitSuggest, RateLimitAPIKey, ReferrerPolicy, RefreshCookieName, SMTPHost, SMTPPort, SMTPUsername, SMTPPassword, SearchDriver, SessionDomain string
+
+	AccessTokenTTL, AuditRetention, ClamAVTimeout, CORSMaxAge, HSTSMaxAge, RefreshTokenTTL, StaticCacheMaxAge time.Duration // zero AuditRetention keep the audit log forever
+
+	EncryptionKeys []string // "<id>:<base64 key>", the first key is active
+
+	CORSOrigins, CORSHeaders, CORSExposedHeaders []string // the origin can be pattern, ex: https://*.perpus.id
+
+	OIDCScopes, TwoFactorRoles []string // user with only TwoFactorRoles must enable 2fa before using the role gated routes
+
+	RateLimitExempt []string // client key (user:<id>), ip, or cidr which is not limited
+
+	RequireVerifiedEmail bool // unverified account can't login
 
 	DBLoc *time.Location
 }
@@ -25,12 +38,19 @@ func initConfig() *Config {
 		log.Fatal(err)
 	}
 
+	// the client app is the allowed origin by default, https for production & http for debug
+	defaultOrigin := fmt.Sprintf("%s:%s", getENVConfigValue("APP_URL"), getENVConfigValue("CLIENT_PORT"))
+	if getENVConfigValue("APP_ENV") == "production" {
+		defaultOrigin = getENVConfigValue("APP_URL")
+	}
+
 	return &Config{
 		AppENV:         getENVConfigValue("APP_ENV"),
 		AppURL:         getENVConfigValue("APP_URL"),
 		ClientPort:     getENVConfigValue("CLIENT_PORT"),
 		CookieName:     getENVConfigValue("COOKIE_NAME"),
 		CookieValue:    getENVConfigValue("COOKIE_VALUE"),
+		CSRFSecret:     getENVConfigValue("CSRF_SECRET"),
 		DBUser:         getENVConfigValue("DB_USERNAME"),
 		DBPassword:     getENVConfigValue("DB_PASSWORD"),
 		DBName:         getENVConfigValue("DB_DATABASE"),
@@ -43,8 +63,65 @@ func initConfig() *Config {
 		RedisAddress:   fmt.Sprintf("%s:%s", getENVConfigValue("REDIS_HOST"), getENVConfigValue("REDIS_PORT")),
 		RedisClient:    getENVConfigValue("REDIS_CLIENT"),
 		RedisPassword:  getENVConfigValue("REDIS_PASSWORD"),
-		JWTSecret:      getENVConfigValue("JWT_SECRET"),
+		SearchDriver:   getENVConfigValue("SEARCH_DRIVER"),
 		SessionDomain:  getENVConfigValue("SESSION_DOMAIN"),
+
+		JWTAlg:            getENVConfigValueOr("JWT_ALG", "EdDSA"),
+		JWTKeysDir:        getENVConfigValueOr("JWT_KEYS_DIR", "./keys"),
+		RefreshCookieName: getENVConfigValueOr("REFRESH_COOKIE_NAME", "refresh_token"),
+		AccessTokenTTL:    getENVDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:   getENVDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+
+		MailDriver:   getENVConfigValue("MAIL_DRIVER"),
+		MailDir:      getENVConfigValue("MAIL_DIR"),
+		MailFrom:     getENVConfigValueOr("MAIL_FROM", "noreply@localhost"),
+		SMTPHost:     getENVConfigValue("SMTP_HOST"),
+		SMTPPort:     getENVConfigValue("SMTP_PORT"),
+		SMTPUsername: getENVConfigValue("SMTP_USERNAME"),
+		SMTPPassword: getENVConfigValue("SMTP_PASSWORD"),
+
+		// sso is disabled when the issuer is empty
+		OIDCIssuer:       getENVConfigValue("OIDC_ISSUER"),
+		OIDCClientID:     getENVConfigValue("OIDC_CLIENT_ID"),
+		OIDCClientSecret: getENVConfigValue("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:  getENVConfigValue("OIDC_REDIRECT_URL"),
+		OIDCDefaultRole:  getENVConfigValueOr("OIDC_DEFAULT_ROLE", "user"),
+		OIDCScopes:       getENVList("OIDC_SCOPES", "openid,email,profile"),
+
+		// page of the client app which the callback redirect to, the result is sent at the url fragment
+		OIDCClientRedirectURL: getENVConfigValueOr("OIDC_CLIENT_REDIRECT_URL", defaultOrigin+"/sso/callback"),
+
+		AuditRetention: getENVDuration("AUDIT_RETENTION", 365*24*time.Hour),
+
+		CORSOrigins:        getENVList("CORS_ORIGINS", defaultOrigin),
+		CORSHeaders:        getENVList("CORS_HEADERS", "Content-Type,Authorization,X-CSRF-Token,X-Request-ID"),
+		CORSExposedHeaders: getENVList("CORS_EXPOSED_HEADERS", "X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After"),
+		CORSMaxAge:         getENVDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		// security headers, HSTS is only sent at production
+		ContentSecurityPolicy: getENVConfigValueOr("CONTENT_SECURITY_POLICY", "default-src 'none'; img-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"),
+		ReferrerPolicy:        getENVConfigValueOr("REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		HSTSMaxAge:            getENVDuration("HSTS_MAX_AGE", 365*24*time.Hour),
+		StaticCacheMaxAge:     getENVDuration("STATIC_CACHE_MAX_AGE", 7*24*time.Hour),
+
+		// the upload isn't scanned when the clamd address is empty, ex: localhost:3310, unix:/run/clamav/clamd.ctl
+		ClamAVAddress: getENVConfigValue("CLAMAV_ADDRESS"),
+		ClamAVTimeout: getENVDuration("CLAMAV_TIMEOUT", 30*time.Second),
+		QuarantineDir: getENVConfigValueOr("QUARANTINE_DIR", "./assets/quarantine"),
+
+		// the member personal data is encrypted, add the new key at the front to rotate
+		EncryptionKeys: getENVList("ENCRYPTION_KEYS", "none"),
+		BlindIndexKey:  getENVConfigValue("BLIND_INDEX_KEY"),
+
+		// rate limit per client of each route group, "limit/period" or "none"
+		RateLimitGlobal:  getENVConfigValueOr("RATE_LIMIT_GLOBAL", "3000/1h"),
+		RateLimitAPI:     getENVConfigValueOr("RATE_LIMIT_API", "120/1m"),
+		RateLimitSuggest: getENVConfigValueOr("RATE_LIMIT_SUGGEST", "30/3s"),
+		RateLimitAPIKey:  getENVConfigValueOr("RATE_LIMIT_API_KEY", "120/1m"),
+		RateLimitExempt:  getENVList("RATE_LIMIT_EXEMPT", "none"),
+
+		RequireVerifiedEmail: getENVConfigValue("REQUIRE_VERIFIED_EMAIL") == "true",
+		TwoFactorRoles:       getENVList("TWO_FACTOR_ROLES", "admin,staff"),
 	}
 }
 
@@ -57,3 +134,45 @@ func getENVConfigValue(variable string) string {
 
 	return v
 }
+
+// same as getENVConfigValue, but return the fallback when the variable is empty.
+func getENVConfigValueOr(variable, fallback string) string {
+	if v := getENVConfigValue(variable); v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+// parse duration variable, ex: 15m, 168h. the fallback is used when it empty.
+func getENVDuration(variable string, fallback time.Duration) time.Duration {
+	v := getENVConfigValue(variable)
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("invalid duration %s: %s", variable, v)
+	}
+
+	return d
+}
+
+// split comma separated variable, ex: admin,staff. set it to "none" to make it empty.
+func getENVList(variable, fallback string) []string {
+	v := getENVConfigValueOr(variable, fallback)
+	if v == "none" {
+		return nil
+	}
+
+	list := make([]string, 0)
+
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -23,6 +23,7 @@ func ScanAndCountRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, int6
 		count int64
 
 		roleID, roleName sql.NullString
+		emailVerifiedAt  sql.NullTime
 	)
 
 	err := rows.Scan(
@@ -32,6 +33,8 @@ func ScanAndCountRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, int6
 		&u.Password,
 		&u.Avatar,
 		&u.TokenVersion,
+		&emailVerifiedAt,
+		&u.TwoFactorEnabled,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 		&roleID,
@@ -42,6 +45,10 @@ func ScanAndCountRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, int6
 		return nil, nil, 0, err
 	}
 
+	if emailVerifiedAt.Valid {
+		u.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+
 	if roleID.Valid && roleName.Valid {
 		r.ID = roleID.String
 		r.Name = roleName.String
@@ -55,7 +62,10 @@ func ScanRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, error) {
 	u := new(types.User)
 	r := new(types.Role)
 
-	var roleID, roleName sql.NullString
+	var (
+		roleID, roleName sql.NullString
+		emailVerifiedAt  sql.NullTime
+	)
 
 	err := rows.Scan(
 		&u.ID,
@@ -64,6 +74,8 @@ func ScanRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, error) {
 		&u.Password,
 		&u.Avatar,
 		&u.TokenVersion,
+		&emailVerifiedAt,
+		&u.TwoFactorEnabled,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 		&roleID,
@@ -73,6 +85,10 @@ func ScanRowsUserAndRole(rows *sql.Rows) (*types.User, *types.Role, error) {
 		return nil, nil, err
 	}
 
+	if emailVerifiedAt.Valid {
+		u.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+
 	if roleID.Valid && roleName.Valid {
 		r.ID = roleID.String
 		r.Name = roleName.String
@@ -113,6 +129,9 @@ func ScanAndCountRowsBook(rows *sql.Rows) (*types.Book, int64, error) {
 		&b.Penulis,
 		&b.Pengarang,
 		&b.Tahun,
+		&b.Kategori,
+		&b.Bahasa,
+		&b.Tersedia,
 		&b.CreatedAt,
 		&b.UpdatedAt,
 		&count,
@@ -136,6 +155,9 @@ func ScanRowsBook(rows *sql.Rows) (*types.Book, error) {
 		&b.Penulis,
 		&b.Pengarang,
 		&b.Tahun,
+		&b.Kategori,
+		&b.Bahasa,
+		&b.Tersedia,
 		&b.CreatedAt,
 		&b.UpdatedAt,
 	)
@@ -159,6 +181,10 @@ func ScanAndCountRowsMember(rows *sql.Rows) (*types.Member, int64, error) {
 		&m.Kelas,
 		&m.NoTelepon,
 		&m.ProfilAnggota,
+		&m.TanggalLahir,
+		&m.AnonymizedAt,
+		&m.Status,
+		&m.BerlakuSampai,
 		&m.CreatedAt,
 		&m.UpdatedAt,
 		&count,
@@ -181,6 +207,10 @@ func ScanRowsMember(rows *sql.Rows) (*types.Member, error) {
 		&m.Kelas,
 		&m.NoTelepon,
 		&m.ProfilAnggota,
+		&m.TanggalLahir,
+		&m.AnonymizedAt,
+		&m.Status,
+		&m.BerlakuSampai,
 		&m.CreatedAt,
 		&m.UpdatedAt,
 	)
@@ -202,6 +232,7 @@ func ScanAndCountRowsCirculation(rows *sql.Rows) (*types.Circulation, *types.Boo
 		&c.BukuID,
 		&c.IdSKL,
 		&c.Peminjam,
+		&c.MemberID,
 		&c.TanggalPinjam,
 		&c.JatuhTempo,
 		&c.Denda,
@@ -227,6 +258,7 @@ func ScanRowsCirculation(rows *sql.Rows) (*types.Circulation, *types.Book, error
 		&c.BukuID,
 		&c.IdSKL,
 		&c.Peminjam,
+		&c.MemberID,
 		&c.TanggalPinjam,
 		&c.JatuhTempo,
 		&c.Denda,
@@ -247,9 +279,12 @@ func ScanAndRetRowUserAndRole[T stringAndNumberOnly](ctx context.Context, stmt *
 	var u types.User
 	r := new(types.Role)
 
-	var roleID, roleName sql.NullString
+	var (
+		roleID, roleName sql.NullString
+		emailVerifiedAt  sql.NullTime
+	)
 
-	err := stmt.QueryRowContext(ctx, param).Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.Avatar, &u.TokenVersion, &u.CreatedAt, &u.UpdatedAt, &roleID, &roleName)
+	err := stmt.QueryRowContext(ctx, param).Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.Avatar, &u.TokenVersion, &emailVerifiedAt, &u.TwoFactorEnabled, &u.CreatedAt, &u.UpdatedAt, &roleID, &roleName)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("user not found")
@@ -258,6 +293,10 @@ func ScanAndRetRowUserAndRole[T stringAndNumberOnly](ctx context.Context, stmt *
 		return nil, err
 	}
 
+	if emailVerifiedAt.Valid {
+		u.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+
 	if roleID.Valid && roleName.Valid {
 		r.ID = roleID.String
 		r.Name = roleName.String
@@ -288,10 +327,10 @@ func ScanAndRetRowRole[T stringAndNumberOnly](ctx context.Context, stmt *sql.Stm
 func ScanAndRetRowMember[T stringAndNumberOnly](ctx context.Context, stmt *sql.Stmt, param T) (*types.Member, error) {
 	var m types.Member
 
-	err := stmt.QueryRowContext(ctx, param).Scan(&m.ID, &m.IdAnggota, &m.Nama, &m.JenisKelamin, &m.Kelas, &m.NoTelepon, &m.ProfilAnggota, &m.CreatedAt, &m.UpdatedAt)
+	err := stmt.QueryRowContext(ctx, param).Scan(&m.ID, &m.IdAnggota, &m.Nama, &m.JenisKelamin, &m.Kelas, &m.NoTelepon, &m.ProfilAnggota, &m.TanggalLahir, &m.AnonymizedAt, &m.Status, &m.BerlakuSampai, &m.CreatedAt, &m.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("member not found")
+			return nil, types.ErrMemberNotFound
 		}
 
 		return nil, err
@@ -304,7 +343,7 @@ func ScanAndRetRowMember[T stringAndNumberOnly](ctx context.Context, stmt *sql.S
 func ScanAndRetRowBook[T stringAndNumberOnly](ctx context.Context, stmt *sql.Stmt, param T) (*types.Book, error) {
 	var b types.Book
 
-	err := stmt.QueryRowContext(ctx, param).Scan(&b.ID, &b.IdBuku, &b.JudulBuku, &b.CoverBuku, &b.BukuPDF, &b.Penulis, &b.Pengarang, &b.Tahun, &b.CreatedAt, &b.UpdatedAt)
+	err := stmt.QueryRowContext(ctx, param).Scan(&b.ID, &b.IdBuku, &b.JudulBuku, &b.CoverBuku, &b.BukuPDF, &b.Penulis, &b.Pengarang, &b.Tahun, &b.Kategori, &b.Bahasa, &b.Tersedia, &b.CreatedAt, &b.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("book not found")
@@ -321,7 +360,7 @@ func ScanAndRetRowCirculation[T stringAndNumberOnly](ctx context.Context, stmt *
 	var c types.Circulation
 	var b types.Book
 
-	err := stmt.QueryRowContext(ctx, param).Scan(&c.ID, &c.BukuID, &c.IdSKL, &c.Peminjam, &c.TanggalPinjam, &c.JatuhTempo, &c.Denda, &c.CreatedAt, &c.UpdatedAt, &b.ID, &b.JudulBuku)
+	err := stmt.QueryRowContext(ctx, param).Scan(&c.ID, &c.BukuID, &c.IdSKL, &c.Peminjam, &c.MemberID, &c.TanggalPinjam, &c.JatuhTempo, &c.Denda, &c.CreatedAt, &c.UpdatedAt, &b.ID, &b.JudulBuku)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("circulation not found")
@@ -353,3 +392,41 @@ func AddDocumentsWithWait(client meilisearch.ServiceManager, index string, prima
 
 	return nil
 }
+
+// method DeleteDocuments custom meili, wait until the documents has been removed.
+func DeleteDocumentsWithWait(client meilisearch.ServiceManager, index string, ids []string) error {
+	res, err := client.Index(index).DeleteDocuments(ids)
+	if err != nil {
+		return err
+	}
+
+	task, err := client.WaitForTask(res.TaskUID, 3*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if task.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("error deleting task: %v", task.Error)
+	}
+
+	return nil
+}
+
+// method UpdateSettings custom meili, wait until the settings has been applied.
+func UpdateSettingsWithWait(client meilisearch.ServiceManager, index string, settings *meilisearch.Settings) error {
+	res, err := client.Index(index).UpdateSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	task, err := client.WaitForTask(res.TaskUID, 3*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if task.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("error settings task: %v", task.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/requestid"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/bytedance/sonic"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ActorUser   = "user"
+	ActorAPIKey = "api_key"
+
+	writeTimeout = 3 * time.Second
+)
+
+var (
+	// set when the server start. it's nil at the test, so nothing is recorded.
+	store types.AuditStore
+
+	// never written into the log, the member personal data is encrypted at db so it isn't copied here
+	redactedFields = []string{"password", "current_password", "new_password", "code", "token", "key", "key_hash", "nama", "no_telepon", "tanggal_lahir", "peminjam"}
+
+	// always changed by the update, it's only noise at the diff
+	ignoredFields = []string{"created_at", "updated_at"}
+)
+
+func SetStore(s types.AuditStore) {
+	store = s
+}
+
+// the resource which is changed by the action.
+type Target struct {
+	Resource string
+
+	// mux var of the resource id, or the form field when the route doesn't have it.
+	IDVar string
+
+	// get the resource for the before/after diff. when it's nil or there is no id, the form values is recorded as after.
+	Load func(ctx context.Context, id string) (any, error)
+}
+
+// make the loader from the store getter, ex: audit.Load(store.GetBookByID).
+func Load[T any](get func(ctx context.Context, id string) (T, error)) func(ctx context.Context, id string) (any, error) {
+	return func(ctx context.Context, id string) (any, error) {
+		return get(ctx, id)
+	}
+}
+
+// record the successful action into audit log. it must be inside the auth middleware, because the actor is read from ctx.
+func Log(h http.HandlerFunc, action string, t Target) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			h(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		resourceID := resourceIDFromRequest(r, t.IDVar)
+
+		var before any
+		if t.Load != nil && resourceID != "" {
+			before = load(ctx, t, resourceID)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h(rec, r)
+
+		if rec.status >= http.StatusBadRequest {
+			return
+		}
+
+		var after any
+
+		switch {
+		case t.Load == nil || resourceID == "":
+			after = formValues(r) // the created resource doesn't have id yet
+		case r.Method != http.MethodDelete:
+			after = load(ctx, t, resourceID)
+		}
+
+		l := &types.AuditLog{
+			Status:     rec.status,
+			Action:     action,
+			Resource:   t.Resource,
+			ResourceID: resourceID,
+			IP:         utils.GetClientIP(r),
+			RequestID:  requestid.FromContext(ctx),
+			Changes:    Diff(before, after),
+		}
+
+		l.ActorID, l.ActorType = jwt.GetUserIDFromContext(ctx), ActorUser
+		if keyID := jwt.GetAPIKeyIDFromContext(ctx); keyID != "" {
+			l.ActorID, l.ActorType = keyID, ActorAPIKey
+		}
+
+		// the action is done already, so the log is still written when the client is gone
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+		defer cancel()
+
+		if err := store.CreateAuditLog(ctx, l); err != nil {
+			log.Printf("audit log %s: %v", action, err)
+		}
+	}
+}
+
+// changed fields between before and after, nil means the resource isn't exist.
+func Diff(before, after any) map[string]types.AuditChange {
+	b, a := toMap(before), toMap(after)
+
+	changes := make(map[string]types.AuditChange)
+
+	for field, value := range b {
+		if !reflect.DeepEqual(value, a[field]) {
+			changes[field] = types.AuditChange{Before: value, After: a[field]}
+		}
+	}
+
+	for field, value := range a {
+		if _, exists := b[field]; !exists {
+			changes[field] = types.AuditChange{After: value}
+		}
+	}
+
+	for field, change := range changes {
+		switch {
+		case slices.Contains(ignoredFields, field):
+			delete(changes, field)
+		case slices.Contains(redactedFields, field):
+			changes[field] = types.AuditChange{Before: redact(change.Before), After: redact(change.After)}
+		default:
+			// the nested resource can have the redacted fields too, ex: the book or member of circulation
+			changes[field] = types.AuditChange{Before: redactNested(change.Before), After: redactNested(change.After)}
+		}
+	}
+
+	return changes
+}
+
+// delete the audit log older than retention, at start and every interval. zero retention keep the log forever.
+func StartRetention(ctx context.Context, s types.AuditStore, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			deleted, err := s.DeleteAuditLogsBefore(ctx, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("audit retention: %v", err)
+			} else if deleted > 0 {
+				log.Printf("audit retention: %d logs deleted", deleted)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func resourceIDFromRequest(r *http.Request, idVar string) string {
+	if idVar == "" {
+		return ""
+	}
+
+	if id, exists := mux.Vars(r)[idVar]; exists {
+		return id
+	}
+
+	return r.FormValue(idVar)
+}
+
+func load(ctx context.Context, t Target, id string) any {
+	v, err := t.Load(ctx, id)
+	if err != nil {
+		return nil
+	}
+
+	return v
+}
+
+// the form is parsed by the handler, the file is not included.
+func formValues(r *http.Request) map[string]any {
+	if len(r.Form) == 0 {
+		return nil
+	}
+
+	values := make(map[string]any, len(r.Form))
+
+	for field, v := range r.Form {
+		if len(v) == 1 {
+			values[field] = v[0]
+			continue
+		}
+
+		values[field] = v
+	}
+
+	return values
+}
+
+func toMap(v any) map[string]any {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	m := make(map[string]any)
+	if err := sonic.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+
+	return m
+}
+
+func redact(v any) any {
+	if v == nil {
+		return nil
+	}
+
+	return "[redacted]"
+}
+
+// redact the fields of the nested objects and arrays, the value is copied so the loaded resource isn't changed.
+func redactNested(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+
+		for field, value := range v {
+			if slices.Contains(redactedFields, field) {
+				m[field] = redact(value)
+				continue
+			}
+
+			m[field] = redactNested(value)
+		}
+
+		return m
+	case []any:
+		items := make([]any, len(v))
+
+		for i, item := range v {
+			items[i] = redactNested(item)
+		}
+
+		return items
+	default:
+		return v
+	}
+}
+
+// keep the status code, so only the successful action is recorded.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+type testBook struct {
+	ID        string `json:"id"`
+	JudulBuku string `json:"judul_buku"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type testCirculation struct {
+	ID       string         `json:"id"`
+	Status   string         `json:"status"`
+	Book     *testBook      `json:"book"`
+	Member   map[string]any `json:"member"`
+	Password string         `json:"password"`
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   map[string]types.AuditChange
+	}{
+		{
+			name:   "it should record only the changed fields",
+			before: &testBook{ID: "1", JudulBuku: "Bumi"},
+			after:  &testBook{ID: "1", JudulBuku: "Bulan"},
+			want:   map[string]types.AuditChange{"judul_buku": {Before: "Bumi", After: "Bulan"}},
+		},
+		{
+			name:   "it should record every field as after at create",
+			before: nil,
+			after:  map[string]any{"judul_buku": "Bumi"},
+			want:   map[string]types.AuditChange{"judul_buku": {After: "Bumi"}},
+		},
+		{
+			name:   "it should record every field as before at delete",
+			before: &testBook{ID: "1", JudulBuku: "Bumi"},
+			after:  (*testBook)(nil),
+			want: map[string]types.AuditChange{
+				"id":         {Before: "1"},
+				"judul_buku": {Before: "Bumi"},
+			},
+		},
+		{
+			name:   "it should ignore the timestamps",
+			before: &testBook{ID: "1", JudulBuku: "Bumi", UpdatedAt: "2026-01-01"},
+			after:  &testBook{ID: "1", JudulBuku: "Bumi", UpdatedAt: "2026-01-02"},
+			want:   map[string]types.AuditChange{},
+		},
+		{
+			name:   "it should redact the secret fields",
+			before: map[string]any{"password": "old", "token": nil},
+			after:  map[string]any{"password": "new", "token": "abc"},
+			want: map[string]types.AuditChange{
+				"password": {Before: "[redacted]", After: "[redacted]"},
+				"token":    {Before: nil, After: "[redacted]"},
+			},
+		},
+		{
+			name:   "it should redact the borrower of circulation",
+			before: map[string]any{"peminjam": "Budi Santoso", "denda": 0},
+			after:  map[string]any{"peminjam": "ID001", "denda": 0},
+			want: map[string]types.AuditChange{
+				"peminjam": {Before: "[redacted]", After: "[redacted]"},
+			},
+		},
+		{
+			name:   "it should redact the fields of nested objects",
+			before: &testCirculation{ID: "1", Status: "dipinjam", Member: map[string]any{"id_anggota": "A1", "nama": "Budi"}},
+			after: &testCirculation{ID: "1", Status: "dikembalikan", Member: map[string]any{
+				"id_anggota": "A1",
+				"nama":       "Budi Santoso",
+				"wali":       []any{map[string]any{"nama": "Sari", "no_telepon": "0812"}},
+			}},
+			want: map[string]types.AuditChange{
+				"status": {Before: "dipinjam", After: "dikembalikan"},
+				"member": {
+					Before: map[string]any{"id_anggota": "A1", "nama": "[redacted]"},
+					After: map[string]any{
+						"id_anggota": "A1",
+						"nama":       "[redacted]",
+						"wali":       []any{map[string]any{"nama": "[redacted]", "no_telepon": "[redacted]"}},
+					},
+				},
+			},
+		},
+		{
+			name:   "it should keep the nested object which has no redacted fields",
+			before: &testCirculation{ID: "1", Book: &testBook{ID: "b1", JudulBuku: "Bumi"}},
+			after:  &testCirculation{ID: "1", Book: &testBook{ID: "b2", JudulBuku: "Bulan"}},
+			want: map[string]types.AuditChange{
+				"book": {
+					Before: map[string]any{"id": "b1", "judul_buku": "Bumi", "updated_at": ""},
+					After:  map[string]any{"id": "b2", "judul_buku": "Bulan", "updated_at": ""},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected changes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+type recordAuditStore struct {
+	types.MockAuditStore
+
+	logs []*types.AuditLog
+}
+
+func (s *recordAuditStore) CreateAuditLog(ctx context.Context, l *types.AuditLog) error {
+	s.logs = append(s.logs, l)
+	return nil
+}
+
+func TestLog(t *testing.T) {
+	books := map[string]*testBook{"1": {ID: "1", JudulBuku: "Bumi"}}
+
+	target := Target{
+		Resource: "books",
+		IDVar:    "bookID",
+		Load: Load(func(ctx context.Context, id string) (*testBook, error) {
+			b := *books[id]
+			return &b, nil
+		}),
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		written int // how many logs is written
+	}{
+		{name: "it should record the successful action", status: http.StatusOK, written: 1},
+		{name: "it should not record the failed action", status: http.StatusUnprocessableEntity, written: 0},
+		{name: "it should not record the server error", status: http.StatusInternalServerError, written: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &recordAuditStore{}
+
+			SetStore(s)
+			defer SetStore(nil)
+
+			h := Log(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status < http.StatusBadRequest {
+					books["1"].JudulBuku = r.FormValue("judul_buku")
+				}
+
+				w.WriteHeader(tt.status)
+			}, "books.update", target)
+
+			req := httptest.NewRequest(http.MethodPatch, "/books/1?judul_buku=Bulan", nil)
+
+			w := httptest.NewRecorder()
+			r := mux.NewRouter()
+
+			r.HandleFunc("/books/{bookID}", h).Methods(http.MethodPatch)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status code %d, got %d", tt.status, w.Code)
+			}
+
+			if len(s.logs) != tt.written {
+				t.Fatalf("expected %d audit logs, got %d", tt.written, len(s.logs))
+			}
+
+			if tt.written == 0 {
+				return
+			}
+
+			l := s.logs[0]
+
+			if l.Action != "books.update" || l.ResourceID != "1" || l.Status != tt.status {
+				t.Errorf("expected books.update of 1 with status %d, got %+v", tt.status, l)
+			}
+
+			if want := (types.AuditChange{Before: "Bumi", After: "Bulan"}); !reflect.DeepEqual(l.Changes["judul_buku"], want) {
+				t.Errorf("expected judul_buku change %v, got %v", want, l.Changes)
+			}
+		})
+	}
+
+	t.Run("it should record the form as after when the resource is created", func(t *testing.T) {
+		s := &recordAuditStore{}
+
+		SetStore(s)
+		defer SetStore(nil)
+
+		h := Log(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			w.WriteHeader(http.StatusCreated)
+		}, "books.create", Target{Resource: "books"})
+
+		req := httptest.NewRequest(http.MethodPost, "/books?judul_buku=Bumi&password=secret", nil)
+
+		w := httptest.NewRecorder()
+		h(w, req)
+
+		if len(s.logs) != 1 {
+			t.Fatalf("expected 1 audit log, got %d", len(s.logs))
+		}
+
+		want := map[string]types.AuditChange{
+			"judul_buku": {After: "Bumi"},
+			"password":   {After: "[redacted]"},
+		}
+
+		if !reflect.DeepEqual(s.logs[0].Changes, want) {
+			t.Errorf("expected changes %v, got %v", want, s.logs[0].Changes)
+		}
+	})
+}
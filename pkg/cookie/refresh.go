@@ -0,0 +1,61 @@
+package cookie
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/perpus_backend/config"
+)
+
+const (
+	// only sent to the refresh route, so the refresh token didn't travel at every request.
+	// logout doesn't read it, it only clear the cookie.
+	refreshCookiePath = "/api/refresh"
+
+	// the path of the cookie before it was scoped, it's expired so the old cookie stop being sent to every api route.
+	legacyRefreshCookiePath = "/api"
+)
+
+// save the refresh token into HttpOnly cookie, so javascript at client can't read it.
+func SetRefreshCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	clearRefreshCookie(w, legacyRefreshCookiePath)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.Env.RefreshCookieName,
+		Value:    token,
+		Path:     refreshCookiePath,
+		Domain:   config.Env.SessionDomain,
+		HttpOnly: true,
+		Secure:   config.Env.AppENV == "production",
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(ttl.Seconds()),
+	})
+}
+
+// remove the refresh token cookie, used at logout.
+func ClearRefreshCookie(w http.ResponseWriter) {
+	clearRefreshCookie(w, legacyRefreshCookiePath)
+	clearRefreshCookie(w, refreshCookiePath)
+}
+
+func clearRefreshCookie(w http.ResponseWriter, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.Env.RefreshCookieName,
+		Value:    "",
+		Path:     path,
+		Domain:   config.Env.SessionDomain,
+		HttpOnly: true,
+		Secure:   config.Env.AppENV == "production",
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	})
+}
+
+func GetRefreshCookie(r *http.Request) string {
+	c, err := r.Cookie(config.Env.RefreshCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
@@ -0,0 +1,52 @@
+package cookie
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/perpus_backend/config"
+)
+
+const (
+	ssoStateCookieName = "sso_state"
+
+	// only sent to the sso routes, the callback compare it with the state of the identity provider
+	ssoStateCookiePath = "/api/sso"
+)
+
+// bind the sso login to the browser which start it. Lax, because the callback is a top level redirect from the identity provider.
+func SetSSOStateCookie(w http.ResponseWriter, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookieName,
+		Value:    value,
+		Path:     ssoStateCookiePath,
+		Domain:   config.Env.SessionDomain,
+		HttpOnly: true,
+		Secure:   config.Env.AppENV == "production",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(ttl.Seconds()),
+	})
+}
+
+// the state is single use, so the cookie is removed at every callback.
+func ClearSSOStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookieName,
+		Value:    "",
+		Path:     ssoStateCookiePath,
+		Domain:   config.Env.SessionDomain,
+		HttpOnly: true,
+		Secure:   config.Env.AppENV == "production",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+func GetSSOStateCookie(r *http.Request) string {
+	c, err := r.Cookie(ssoStateCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
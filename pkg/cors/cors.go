@@ -1,36 +1,162 @@
 package cors
 
 import (
-	"log"
+	"fmt"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/perpus_backend/config"
+	"github.com/gorilla/mux"
 )
 
+// cors policy of a route group.
+type Options struct {
+	// exact origin (https://perpus.id), or pattern with "*" for subdomain or port (https://*.perpus.id, http://localhost:*).
+	// "*" alone allow every origin, it can't be used with Credentials.
+	Origins []string
+
+	Methods, Headers, ExposedHeaders []string
+
+	MaxAge time.Duration
+
+	Credentials bool
+}
+
+// the allowed origins, it's used by the cors policy and the websocket origin check.
+type Matcher struct {
+	origins  []string
+	patterns []*regexp.Regexp
+	anyOrig  bool
+}
+
+// origin is exact (https://perpus.id), or pattern with "*" (https://*.perpus.id, http://localhost:*), or "*" alone.
+func NewMatcher(origins []string) (*Matcher, error) {
+	m := new(Matcher)
+
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+
+		switch {
+		case origin == "*":
+			m.anyOrig = true
+		case strings.Contains(origin, "*"):
+			if !strings.Contains(origin, "://") {
+				return nil, fmt.Errorf("invalid cors origin pattern: %s", origin)
+			}
+
+			// the "*" is one or more dns label, or the port
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[a-z0-9-]+(?:\.[a-z0-9-]+)*`)
+			m.patterns = append(m.patterns, regexp.MustCompile("^"+pattern+"$"))
+		default:
+			m.origins = append(m.origins, origin)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Matcher) Match(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	if m.anyOrig || slices.Contains(m.origins, origin) {
+		return true
+	}
+
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Allows web pages to securely access resources from other domains, overcoming the same-origin policy restrictions that apply by default.
-func CORSMiddleware(next http.Handler) http.Handler {
+// the request Origin is echoed only when it match the allowed origins.
+type Policy struct {
+	origins *Matcher
+
+	methods, headers, exposed, maxAge string
+
+	credentials bool
+}
+
+func NewPolicy(o Options) (*Policy, error) {
+	origins, err := NewMatcher(o.Origins)
+	if err != nil {
+		return nil, err
+	}
+
+	// the origin is echoed, so "*" with credentials would let every site read the response with the user cookie
+	if origins.anyOrig && o.Credentials {
+		return nil, fmt.Errorf("cors origin * can't be used with credentials, list the allowed origins")
+	}
+
+	return &Policy{
+		origins:     origins,
+		methods:     strings.Join(o.Methods, ", "),
+		headers:     strings.Join(o.Headers, ", "),
+		exposed:     strings.Join(o.ExposedHeaders, ", "),
+		maxAge:      strconv.Itoa(int(o.MaxAge.Seconds())),
+		credentials: o.Credentials,
+	}, nil
+}
+
+// use the policy at the route group. OPTIONS of every path at the group is matched too,
+// because mux only run the middleware for the matched route.
+func (p *Policy) Register(r *mux.Router) {
+	r.Use(p.Middleware)
+
+	r.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (p *Policy) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// set to http for debug & https for production
-		switch config.Env.AppENV {
-		case "production":
-			w.Header().Set("Access-Control-Allow-Origin", config.Env.AppURL)
-		case "debug":
-			w.Header().Set("Access-Control-Allow-Origin", config.Env.LocalAddress)
-		default:
-			log.Fatalf("invalid read value env: %s", config.Env.AppENV)
+		origin := r.Header.Get("Origin")
+
+		// the response is different by the origin, so the cache must keep it separately
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" || !p.origins.Match(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			// without the cors headers, the browser doesn't give the response into the other origin
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
 
-		w.Header().Set("Vary", "Origin")
+		if p.credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if p.exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposed)
+			}
 
-		if r.Method != http.MethodOptions {
 			next.ServeHTTP(w, r)
 			return
-		} else {
-			w.WriteHeader(http.StatusNoContent)
 		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		w.Header().Set("Access-Control-Allow-Methods", p.methods)
+		w.Header().Set("Access-Control-Allow-Headers", p.headers)
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+
+		w.WriteHeader(http.StatusNoContent)
 	})
 }
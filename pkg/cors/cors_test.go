@@ -0,0 +1,184 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{name: "it should match the exact origin", origins: []string{"https://perpus.id"}, origin: "https://perpus.id", want: true},
+		{name: "it should match the origin case insensitive", origins: []string{"https://Perpus.id/"}, origin: "HTTPS://PERPUS.ID", want: true},
+		{name: "it should not match the other scheme", origins: []string{"https://perpus.id"}, origin: "http://perpus.id", want: false},
+		{name: "it should match the subdomain pattern", origins: []string{"https://*.perpus.id"}, origin: "https://admin.perpus.id", want: true},
+		{name: "it should match the nested subdomain pattern", origins: []string{"https://*.perpus.id"}, origin: "https://a.b.perpus.id", want: true},
+		{name: "it should not match the apex at subdomain pattern", origins: []string{"https://*.perpus.id"}, origin: "https://perpus.id", want: false},
+		{name: "it should not match the suffix of other domain", origins: []string{"https://*.perpus.id"}, origin: "https://evil-perpus.id", want: false},
+		{name: "it should not match the pattern as prefix", origins: []string{"https://*.perpus.id"}, origin: "https://admin.perpus.id.evil.com", want: false},
+		{name: "it should match the port pattern", origins: []string{"http://localhost:*"}, origin: "http://localhost:5173", want: true},
+		{name: "it should not match the other host at port pattern", origins: []string{"http://localhost:*"}, origin: "http://localhost.evil.com:80", want: false},
+		{name: "it should match every origin", origins: []string{"*"}, origin: "https://evil.com", want: true},
+		{name: "it should not match without origins", origins: nil, origin: "https://perpus.id", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.origins)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := m.Match(tt.origin); got != tt.want {
+				t.Errorf("expected match %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("it should reject the pattern without scheme", func(t *testing.T) {
+		if _, err := NewMatcher([]string{"*.perpus.id"}); err == nil {
+			t.Error("expected error for pattern without scheme")
+		}
+	})
+}
+
+func TestNewPolicy(t *testing.T) {
+	t.Run("it should reject every origin with credentials", func(t *testing.T) {
+		if _, err := NewPolicy(Options{Origins: []string{"https://perpus.id", "*"}, Credentials: true}); err == nil {
+			t.Error("expected error for * with credentials")
+		}
+	})
+
+	t.Run("it should allow every origin without credentials", func(t *testing.T) {
+		if _, err := NewPolicy(Options{Origins: []string{"*"}}); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(Options{
+		Origins:        []string{"https://perpus.id", "https://*.perpus.id"},
+		Methods:        []string{http.MethodGet, http.MethodPost},
+		Headers:        []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+		Credentials:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	p.Register(r)
+
+	r.HandleFunc("/books", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet, http.MethodPost)
+
+	serve := func(method, origin, requestMethod string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/books", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("it should echo the allowed origin", func(t *testing.T) {
+		w := serve(http.MethodGet, "https://admin.perpus.id", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		for header, want := range map[string]string{
+			"Access-Control-Allow-Origin":      "https://admin.perpus.id",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Expose-Headers":    "X-Request-ID",
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("expected %s %q, got %q", header, want, got)
+			}
+		}
+
+		if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+			t.Errorf("expected Vary Origin, got %v", w.Header().Values("Vary"))
+		}
+	})
+
+	t.Run("it should answer the preflight of allowed origin", func(t *testing.T) {
+		w := serve(http.MethodOptions, "https://perpus.id", http.MethodPost)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, w.Code)
+		}
+
+		for header, want := range map[string]string{
+			"Access-Control-Allow-Origin":  "https://perpus.id",
+			"Access-Control-Allow-Methods": "GET, POST",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+			"Access-Control-Max-Age":       "600",
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("expected %s %q, got %q", header, want, got)
+			}
+		}
+	})
+
+	t.Run("it should reject the preflight of other origin", func(t *testing.T) {
+		w := serve(http.MethodOptions, "https://evil.com", http.MethodPost)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no allow origin, got %s", got)
+		}
+	})
+
+	t.Run("it should serve other origin without cors headers", func(t *testing.T) {
+		w := serve(http.MethodGet, "https://evil.com", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no allow origin, got %s", got)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("expected no allow credentials, got %s", got)
+		}
+	})
+
+	t.Run("it should serve the same origin request without cors headers", func(t *testing.T) {
+		w := serve(http.MethodGet, "", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no allow origin, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/utils"
+)
+
+const (
+	CookieName = "csrf_token"
+	HeaderName = "X-CSRF-Token"
+
+	cookieMaxAge = 12 * 60 * 60
+)
+
+var errInvalidToken = errors.New("invalid csrf token")
+
+// the token is signed, so the cookie which is injected by other site (ex: from a subdomain) is rejected.
+// without CSRF_SECRET the key is random, so the token is only valid at this process.
+var secret = sync.OnceValue(func() []byte {
+	if config.Env.CSRFSecret != "" {
+		return []byte(config.Env.CSRFSecret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	return key
+})
+
+// check the secret at startup, the production has many replicas so the token must be valid at every one.
+func Check() error {
+	if config.Env.CSRFSecret != "" {
+		return nil
+	}
+
+	if config.Env.AppENV == "production" {
+		return errors.New("CSRF_SECRET is required at production")
+	}
+
+	log.Println("CSRF_SECRET is empty, the csrf token is only valid until restart")
+
+	return nil
+}
+
+// double-submit cookie: the state-changing request must send the token of csrf cookie at X-CSRF-Token header.
+// the other site can send the cookie, but it can't read it to set the header.
+//
+// the request with Authorization header (Bearer or ApiKey) and the request without cookie is skipped,
+// because the browser doesn't attach that credential automatically.
+// the token is only given by /api/csrf, so the cacheable response (ex: /public files) never has the cookie.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "" || len(r.Cookies()) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c, err := r.Cookie(CookieName)
+		if err != nil || !validToken(c.Value) || !hmac.Equal([]byte(c.Value), []byte(r.Header.Get(HeaderName))) {
+			utils.WriteJSONError(w, http.StatusForbidden, errInvalidToken)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// give the token at body too, because the client app at other origin can't read the cookie of api domain.
+// the token is different for each browser, so it must not be cached.
+func HandleToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Vary", "Cookie")
+
+	token := ""
+
+	if c, err := r.Cookie(CookieName); err == nil && validToken(c.Value) {
+		token = c.Value
+	} else {
+		token = newToken()
+		setCookie(w, token)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.JsonData{
+		Code:   http.StatusOK,
+		Data:   map[string]string{"csrf_token": token, "header": HeaderName},
+		Status: http.StatusText(http.StatusOK),
+	})
+}
+
+// "<random>.<hmac of random>"
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	random := base64.RawURLEncoding.EncodeToString(b)
+
+	return random + "." + sign(random)
+}
+
+func validToken(token string) bool {
+	random, signature, ok := strings.Cut(token, ".")
+	if !ok || random == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(sign(random)))
+}
+
+func sign(random string) string {
+	mac := hmac.New(sha256.New, secret())
+	mac.Write([]byte(random))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// the javascript of the same site must read it, so it isn't HttpOnly.
+func setCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Domain:   config.Env.SessionDomain,
+		Secure:   config.Env.AppENV == "production",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   cookieMaxAge,
+	})
+}
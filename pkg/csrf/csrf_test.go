@@ -0,0 +1,149 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/perpus_backend/config"
+)
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := Middleware(ok)
+	token := newToken()
+
+	tests := []struct {
+		name          string
+		method        string
+		cookie        string
+		header        string
+		authorization string
+		want          int
+	}{
+		{name: "it should allow the safe method without token", method: http.MethodGet, want: http.StatusOK},
+		{name: "it should allow the matched cookie and header", method: http.MethodPost, cookie: token, header: token, want: http.StatusOK},
+		{name: "it should reject the missing header", method: http.MethodPost, cookie: token, want: http.StatusForbidden},
+		{name: "it should reject the different header", method: http.MethodDelete, cookie: token, header: newToken(), want: http.StatusForbidden},
+		{name: "it should reject the unsigned token", method: http.MethodPost, cookie: "random.signature", header: "random.signature", want: http.StatusForbidden},
+		{name: "it should skip the request with authorization header", method: http.MethodPost, cookie: token, authorization: "Bearer token", want: http.StatusOK},
+		{name: "it should skip the request without cookie", method: http.MethodPost, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/books", nil)
+
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.cookie})
+			}
+
+			if tt.header != "" {
+				req.Header.Set(HeaderName, tt.header)
+			}
+
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	t.Run("it should reject the request with other cookie but without token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "token"})
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("it should not set the cookie at the safe method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/cover.jpg", nil))
+
+		if cookies := w.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("expected no cookie, got %v", cookies)
+		}
+	})
+}
+
+func TestHandleToken(t *testing.T) {
+	t.Run("it should issue the token at cookie and body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		HandleToken(w, httptest.NewRequest(http.MethodGet, "/api/csrf", nil))
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != CookieName || !validToken(cookies[0].Value) {
+			t.Fatalf("expected signed csrf cookie, got %v", cookies)
+		}
+
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("expected Cache-Control no-store, got %s", w.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("it should keep the valid token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/csrf", nil)
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: newToken()})
+
+		w := httptest.NewRecorder()
+		HandleToken(w, req)
+
+		if cookies := w.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("expected no new cookie, got %v", cookies)
+		}
+	})
+
+	t.Run("it should replace the unsigned token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/csrf", nil)
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: "random.signature"})
+
+		w := httptest.NewRecorder()
+		HandleToken(w, req)
+
+		if cookies := w.Result().Cookies(); len(cookies) != 1 || !validToken(cookies[0].Value) {
+			t.Errorf("expected new signed cookie, got %v", cookies)
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	appENV, csrfSecret := config.Env.AppENV, config.Env.CSRFSecret
+	defer func() {
+		config.Env.AppENV, config.Env.CSRFSecret = appENV, csrfSecret
+	}()
+
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		wantErr bool
+	}{
+		{name: "it should require the secret at production", env: "production", secret: "", wantErr: true},
+		{name: "it should allow the secret at production", env: "production", secret: "secret", wantErr: false},
+		{name: "it should allow the random secret at debug", env: "debug", secret: "", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Env.AppENV, config.Env.CSRFSecret = tt.env, tt.secret
+
+			if err := Check(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/perpus_backend/config"
+)
+
+// the sealed value is "enc:v2:<key id>:<wrapped data key>:<ciphertext>", so the plaintext value
+// (the row before encryption) is still readable and the old key is found by the id.
+// v1 only has the field at the additional data, it's still opened and sealed again as v2 by the rotation.
+const (
+	prefix   = "enc:v2:"
+	prefixV1 = "enc:v1:"
+)
+
+var (
+	errInvalidValue = errors.New("invalid encrypted value")
+
+	// set from ENCRYPTION_KEYS and BLIND_INDEX_KEY, it's disabled at the test.
+	keys = sync.OnceValues(func() (*Keyring, error) {
+		return NewKeyring(config.Env.EncryptionKeys, config.Env.BlindIndexKey)
+	})
+)
+
+// the key encryption keys, the first one encrypt the new value and the others only decrypt the old value.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+
+	index []byte
+}
+
+// the key is "<id>:<base64 of 32 bytes>", ex: 2026a:q5b...=. without key the value is stored as plaintext.
+func NewKeyring(list []string, indexKey string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	for _, entry := range list {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid encryption key: %s", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid encryption key %s: must be base64 of 32 bytes", id)
+		}
+
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key: %s", id)
+		}
+
+		if k.keys[id], err = newGCM(key); err != nil {
+			return nil, err
+		}
+
+		if k.active == "" {
+			k.active = id
+		}
+	}
+
+	if k.active != "" && indexKey == "" {
+		return nil, errors.New("BLIND_INDEX_KEY is required when ENCRYPTION_KEYS is set")
+	}
+
+	k.index = []byte(indexKey)
+
+	return k, nil
+}
+
+// replace the keyring from config, it's used by the test. nil keyring disables the encryption.
+func SetKeyring(k *Keyring) {
+	if k == nil {
+		k = &Keyring{keys: make(map[string]cipher.AEAD)}
+	}
+
+	keys = func() (*Keyring, error) { return k, nil }
+}
+
+// check the keys at startup, the production must have the keys.
+func Check() error {
+	k, err := keys()
+	if err != nil {
+		return err
+	}
+
+	if k.active == "" {
+		if config.Env.AppENV == "production" {
+			return errors.New("ENCRYPTION_KEYS is required at production")
+		}
+
+		log.Println("ENCRYPTION_KEYS is empty, the personal data is stored as plaintext")
+	}
+
+	return nil
+}
+
+// encrypt the value of the field with new data key, the data key is encrypted by the active key.
+// the field and the row id is the additional data, so the value can't be moved into other column or other row.
+func Seal(field, id, plaintext string) (string, error) {
+	k, err := keys()
+	if err != nil {
+		return "", err
+	}
+
+	if k.active == "" || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	additional := additionalData(field, id)
+
+	wrapped, err := seal(k.keys[k.active], dataKey, additional)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(aead, []byte(plaintext), additional)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.active + ":" + encode(wrapped) + ":" + encode(ciphertext), nil
+}
+
+// decrypt the sealed value of the row, the plaintext value is returned as it is.
+func Open(field, id, value string) (string, error) {
+	var (
+		rest       string
+		additional []byte
+	)
+
+	switch {
+	case strings.HasPrefix(value, prefix):
+		rest, additional = strings.TrimPrefix(value, prefix), additionalData(field, id)
+	case strings.HasPrefix(value, prefixV1):
+		rest, additional = strings.TrimPrefix(value, prefixV1), []byte(field)
+	default:
+		return value, nil
+	}
+
+	k, err := keys()
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", errInvalidValue
+	}
+
+	kek, exists := k.keys[parts[0]]
+	if !exists {
+		return "", fmt.Errorf("encryption key %s not found", parts[0])
+	}
+
+	wrapped, err := decode(parts[1])
+	if err != nil {
+		return "", errInvalidValue
+	}
+
+	dataKey, err := open(kek, wrapped, additional)
+	if err != nil {
+		return "", errInvalidValue
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := decode(parts[2])
+	if err != nil {
+		return "", errInvalidValue
+	}
+
+	plaintext, err := open(aead, ciphertext, additional)
+	if err != nil {
+		return "", errInvalidValue
+	}
+
+	return string(plaintext), nil
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix) || strings.HasPrefix(value, prefixV1)
+}
+
+// the value must be sealed again, because it's plaintext, v1, or it's sealed by the old key. the empty value is never sealed.
+func NeedsRotation(value string) bool {
+	k, err := keys()
+	if err != nil || k.active == "" || value == "" {
+		return false
+	}
+
+	return !strings.HasPrefix(value, prefix+k.active+":")
+}
+
+// keyed hash of the normalized value for the equality lookup, the same value always has the same index.
+// the index key is never rotated, because all the index must be computed again.
+func BlindIndex(field, normalized string) string {
+	k, err := keys()
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(normalized))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func additionalData(field, id string) []byte {
+	return []byte(field + "\x00" + id)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// the nonce is at the front of the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errInvalidValue
+	}
+
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], additional)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
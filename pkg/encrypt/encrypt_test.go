@@ -0,0 +1,247 @@
+package encrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T, id string) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+func useKeyring(t *testing.T, list ...string) *Keyring {
+	t.Helper()
+
+	k, err := NewKeyring(list, "index-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(nil) })
+
+	return k
+}
+
+// the v1 value before the row id is the additional data.
+func sealV1(t *testing.T, k *Keyring, field, plaintext string) string {
+	t.Helper()
+
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(field))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := seal(aead, []byte(plaintext), []byte(field))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return prefixV1 + k.active + ":" + encode(wrapped) + ":" + encode(ciphertext)
+}
+
+// flip one byte of the ciphertext, the value is still valid base64.
+func tamper(value string) string {
+	i := strings.LastIndex(value, ":")
+
+	ciphertext, _ := decode(value[i+1:])
+	ciphertext[len(ciphertext)-1] ^= 1
+
+	return value[:i+1] + encode(ciphertext)
+}
+
+func TestSealAndOpen(t *testing.T) {
+	k := useKeyring(t, newKey(t, "2026a"))
+
+	sealed, err := Seal("members.nama", "member-1", "Budi Santoso")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(sealed, "enc:v2:2026a:") || strings.Contains(sealed, "Budi") {
+		t.Fatalf("expected the sealed value by the active key, got %s", sealed)
+	}
+
+	tests := []struct {
+		name    string
+		field   string
+		id      string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "it should open the sealed value", field: "members.nama", id: "member-1", value: sealed, want: "Budi Santoso"},
+		{name: "it should return the plaintext value as it is", field: "members.nama", id: "member-1", value: "Budi Santoso", want: "Budi Santoso"},
+		{name: "it should open the v1 value without the row id", field: "members.nama", id: "member-1", value: sealV1(t, k, "members.nama", "Budi Santoso"), want: "Budi Santoso"},
+		{name: "it should reject the tampered value", field: "members.nama", id: "member-1", value: tamper(sealed), wantErr: true},
+		{name: "it should reject the value copied into other row", field: "members.nama", id: "member-2", value: sealed, wantErr: true},
+		{name: "it should reject the value copied into other column", field: "members.no_telepon", id: "member-1", value: sealed, wantErr: true},
+		{name: "it should reject the malformed value", field: "members.nama", id: "member-1", value: "enc:v2:2026a:broken", wantErr: true},
+		{name: "it should reject the value of unknown key", field: "members.nama", id: "member-1", value: strings.Replace(sealed, "2026a", "2025z", 1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.field, tt.id, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("it should not seal the empty value", func(t *testing.T) {
+		if got, err := Seal("members.no_telepon", "member-1", ""); err != nil || got != "" {
+			t.Errorf("expected empty value, got %q, %v", got, err)
+		}
+	})
+}
+
+func TestRotation(t *testing.T) {
+	oldKey := newKey(t, "2025a")
+
+	useKeyring(t, oldKey)
+
+	sealed, err := Seal("members.no_telepon", "member-1", "08123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new key is the first, the old key is only for decrypt
+	useKeyring(t, newKey(t, "2026a"), oldKey)
+
+	t.Run("it should open the value of the old key", func(t *testing.T) {
+		if got, err := Open("members.no_telepon", "member-1", sealed); err != nil || got != "08123456789" {
+			t.Errorf("expected the old value is opened, got %q, %v", got, err)
+		}
+	})
+
+	t.Run("it should seal the new value with the active key", func(t *testing.T) {
+		resealed, err := Seal("members.no_telepon", "member-1", "08123456789")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(resealed, "enc:v2:2026a:") {
+			t.Errorf("expected the active key, got %s", resealed)
+		}
+	})
+
+	t.Run("it should fail after the old key is removed", func(t *testing.T) {
+		useKeyring(t, newKey(t, "2026a"))
+
+		if _, err := Open("members.no_telepon", "member-1", sealed); err == nil {
+			t.Error("expected error for the removed key")
+		}
+	})
+}
+
+func TestNeedsRotation(t *testing.T) {
+	oldKey := newKey(t, "2025a")
+
+	k := useKeyring(t, oldKey)
+
+	sealedByOld, _ := Seal("members.nama", "member-1", "Budi")
+	v1 := sealV1(t, k, "members.nama", "Budi")
+
+	useKeyring(t, newKey(t, "2026a"), oldKey)
+
+	sealed, _ := Seal("members.nama", "member-1", "Budi")
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "it should rotate the plaintext value", value: "Budi", want: true},
+		{name: "it should rotate the value of the old key", value: sealedByOld, want: true},
+		{name: "it should rotate the v1 value", value: v1, want: true},
+		{name: "it should keep the value of the active key", value: sealed, want: false},
+		{name: "it should keep the empty value", value: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRotation(tt.value); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("it should keep every value without keys", func(t *testing.T) {
+		SetKeyring(nil)
+
+		if NeedsRotation("Budi") {
+			t.Error("expected no rotation without keys")
+		}
+	})
+}
+
+func TestBlindIndex(t *testing.T) {
+	useKeyring(t, newKey(t, "2026a"))
+
+	index := BlindIndex("members.nama", "budi santoso")
+
+	tests := []struct {
+		name       string
+		field      string
+		normalized string
+		same       bool
+	}{
+		{name: "it should be the same for the same value", field: "members.nama", normalized: "budi santoso", same: true},
+		{name: "it should be different for other value", field: "members.nama", normalized: "budi santosa", same: false},
+		{name: "it should be different for other field", field: "members.no_telepon", normalized: "budi santoso", same: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BlindIndex(tt.field, tt.normalized); (got == index) != tt.same {
+				t.Errorf("expected same index %v, got %s and %s", tt.same, index, got)
+			}
+		})
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     []string
+		indexKey string
+		wantErr  bool
+	}{
+		{name: "it should allow the empty keyring", list: nil},
+		{name: "it should reject the key without id", list: []string{"q5b="}, indexKey: "index", wantErr: true},
+		{name: "it should reject the short key", list: []string{"2026a:" + base64.StdEncoding.EncodeToString([]byte("short"))}, indexKey: "index", wantErr: true},
+		{name: "it should reject the duplicate key", list: []string{newKey(t, "2026a"), newKey(t, "2026a")}, indexKey: "index", wantErr: true},
+		{name: "it should require the blind index key", list: []string{newKey(t, "2026a")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.list, tt.indexKey); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package hash
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // hash the plain password from request input.
 func HashPassword(password string) (string, error) {
@@ -17,3 +22,10 @@ func CompareHashedPassword(hashedPassword string, plainTextPassword []byte) bool
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), plainTextPassword)
 	return err == nil // true
 }
+
+// hash the random token (refresh token, etc) before it saved. it doesn't need bcrypt since the token is random
+// and long enough, and it must be looked up by the hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
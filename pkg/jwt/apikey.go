@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/utils"
+)
+
+const apiKeyScheme = "ApiKey "
+
+// accept "Authorization: ApiKey <key>" for machine client, the other request is checked by AuthWithJWTToken.
+// api key has no user, so only use it at routes which is gated by RequirePermission.
+func (j *AuthJWT) AuthWithAPIKeyOrJWT(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+
+		if !strings.HasPrefix(header, apiKeyScheme) {
+			j.AuthWithJWTToken(h)(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		raw := strings.TrimSpace(strings.TrimPrefix(header, apiKeyScheme))
+		if raw == "" {
+			utils.WriteJSONError(w, unauth, ua)
+			return
+		}
+
+		k, err := j.ks.GetAPIKeyByHash(ctx, hash.HashToken(raw))
+		if err != nil {
+			utils.WriteJSONError(w, unauth, ua)
+			log.Println(err)
+			return
+		}
+
+		if k.RevokedAt != nil {
+			utils.WriteJSONError(w, unauth, ua)
+			log.Println("api key is revoked")
+			return
+		}
+
+		if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+			utils.WriteJSONError(w, unauth, ua)
+			log.Println("api key is expired")
+			return
+		}
+
+		if j.apiKeyLimit != nil && !j.apiKeyLimit(w, r, "api_key:"+k.ID) {
+			return
+		}
+
+		_ = j.ks.TouchAPIKey(ctx, k.ID) // last used of the key
+
+		ctx = context.WithValue(ctx, claimsKey, &Claims{APIKeyID: k.ID, Permissions: k.Permissions})
+
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// get the api key id from ctx, empty when the request use the token.
+func GetAPIKeyIDFromContext(ctx context.Context) string {
+	if claims := GetClaimsFromContext(ctx); claims != nil {
+		return claims.APIKeyID
+	}
+
+	return ""
+}
@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/perpus_backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// claims of the access token. the roles is array, so the user with many roles get all of them.
+type Claims struct {
+	UserID       string   `json:"userID"`
+	SessionID    string   `json:"sid"`
+	Roles        []string `json:"roles"`
+	RoleIDs      []string `json:"role_ids"`
+	TwoFactor    bool     `json:"two_factor"`
+	TokenVersion int      `json:"token_version"`
+
+	// bumped when the roles or 2fa of user is changed, the older tokens is rejected and the client must refresh
+	ClaimsVersion int64 `json:"claims_version"`
+
+	// set when the request use api key instead of the token, it has the permissions of the key without user and roles
+	APIKeyID    string   `json:"-"`
+	Permissions []string `json:"-"`
+
+	jwt.RegisteredClaims
+}
+
+const claimsKey contextKey = "claims"
+
+// get the validated claims of the access token from ctx, nil when the route isn't authenticated.
+func GetClaimsFromContext(ctx context.Context) *Claims {
+	if claims, ok := ctx.Value(claimsKey).(*Claims); ok {
+		return claims
+	}
+
+	return nil
+}
+
+// put the claims into ctx, for the handler which is called without the auth middleware, ex: at the tests.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// the current claims version of user, 0 when it was never changed.
+func (j *AuthJWT) claimsVersion(ctx context.Context, userID string) (int64, error) {
+	versionKey, err := utils.Redis2Key("claims_version", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := j.rdb.Get(ctx, versionKey).Result()
+	if err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(res, 10, 64)
+}
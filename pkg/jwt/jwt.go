@@ -6,31 +6,42 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/perpus_backend/config"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
+	"github.com/bytedance/sonic"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
 )
 
 type AuthJWT struct {
 	us types.UserStore
+	ss types.SessionStore
+	ps types.PermissionStore
+	ks types.APIKeyStore
+
+	keys *Keyring
 
 	rdb *redis.Client
+
+	// charge the bucket of the validated api key, nil is no limit
+	apiKeyLimit APIKeyLimitFunc
 }
 
-func NewAuthJWT(us types.UserStore, rdb *redis.Client) *AuthJWT {
-	return &AuthJWT{us: us, rdb: rdb}
+func NewAuthJWT(us types.UserStore, ss types.SessionStore, ps types.PermissionStore, ks types.APIKeyStore, keys *Keyring, rdb *redis.Client) *AuthJWT {
+	return &AuthJWT{us: us, ss: ss, ps: ps, ks: ks, keys: keys, rdb: rdb}
 }
 
 type contextKey string // 16 byte string
 
 const (
-	userKey contextKey = "userID"
+	userKey    contextKey = "userID"
+	sessionKey contextKey = "sessionID"
 
 	unauth int = http.StatusUnauthorized
 )
@@ -39,6 +50,8 @@ var (
 	shortTimeoutDuration = time.Duration(3 * time.Second)
 
 	ua = errors.New("Unauthorized")
+
+	errTwoFactorRequired = errors.New("two factor authentication is required")
 )
 
 // Authentication using JWT.
@@ -62,28 +75,30 @@ func (j *AuthJWT) AuthWithJWTToken(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// get the key in redis storage
-		resInt64, err := j.rdb.Exists(ctx, tokenString).Result()
+		claims := token.Claims.(*Claims)
+		if claims.UserID == "" || claims.SessionID == "" {
+			utils.WriteJSONError(w, unauth, ua)
+			log.Println("token has no user or session")
+			return
+		}
+
+		userID, sessionID := claims.UserID, claims.SessionID
+
+		// the session is deleted when logout or the refresh token was reused, the access token die with it
+		resInt64, err := j.rdb.Exists(ctx, "session:"+sessionID).Result()
 		if err != nil {
 			utils.WriteJSONError(w, unauth, err)
 			log.Println(err)
 			return
 		}
 
-		// check once again to make sure the token is available in redis storage
 		if resInt64 == 0 {
 			utils.WriteJSONError(w, unauth, ua)
-			log.Println("token is deleted in redis storage")
+			log.Println("session is revoked")
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		userID, ok := claims["userID"].(string)
-		if !ok {
-			utils.WriteJSONError(w, unauth, ua)
-			log.Println("type assertion failed")
-			return
-		}
+		_ = j.ss.TouchSession(ctx, sessionID) // last used of the session
 
 		ctx, cancel := context.WithTimeout(ctx, shortTimeoutDuration)
 		defer cancel()
@@ -95,63 +110,97 @@ func (j *AuthJWT) AuthWithJWTToken(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if u.TokenVersion != int(claims["token_version"].(float64)) {
+		if u.TokenVersion != claims.TokenVersion {
 			utils.WriteJSONError(w, unauth, ua)
 			log.Println("token is revoked")
 			return
 		}
 
-		if time.Now().Unix() > int64(claims["exp"].(float64)) {
+		if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
 			utils.WriteJSONError(w, unauth, ua)
 			log.Println("token is expired")
 			return
 		}
 
+		// the roles or 2fa was changed after the token is made
+		version, err := j.claimsVersion(ctx, userID)
+		if err != nil || version != claims.ClaimsVersion {
+			utils.WriteJSONError(w, unauth, ua)
+			log.Println("token claims is outdated")
+			return
+		}
+
 		ctx = context.WithValue(ctx, userKey, u.ID) // set the value with key userID
+		ctx = context.WithValue(ctx, sessionKey, sessionID)
+		ctx = context.WithValue(ctx, claimsKey, claims)
 
 		h(w, r.WithContext(ctx)) // <- di mana ada WithContext(), di sana parent context nya.
 	}
 }
 
-// Creating token for use in method AuthJWT and add at header authentication.
-func (j *AuthJWT) CreateTokenJWT(ctx context.Context, userID string) (string, error) {
-	u, err := j.us.GetUserWithRolesByID(ctx, userID)
+// Creating short-lived access token for use in method AuthJWT and add at header authentication.
+// the session id (sid) bind the token into the refresh token session.
+func (j *AuthJWT) CreateTokenJWT(ctx context.Context, u *types.User, sessionID string) (string, error) {
+	key, err := j.keys.signing()
 	if err != nil {
 		return "", err
 	}
 
-	var roles string // initial first
-	for _, role := range u.Roles {
-		roles = role.Name // get the all role from user
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID":        u.ID,
-		"roles":         roles,
-		"token_version": u.TokenVersion,
-		"iat":           time.Now().Unix(),
-		"exp":           time.Now().Add(4 * time.Hour).Unix(),
-	})
-
-	tokenString, err := token.SignedString([]byte(config.Env.JWTSecret))
+	version, err := j.claimsVersion(ctx, u.ID)
 	if err != nil {
 		return "", err
 	}
 
-	// set token and save into redis storage
-	_ = j.rdb.SetEx(ctx, tokenString, u.Name, time.Duration(4*time.Hour)).Err()
+	now := time.Now()
+
+	token := jwt.NewWithClaims(key.method, &Claims{
+		UserID:        u.ID,
+		SessionID:     sessionID,
+		Roles:         u.Roles.Names(),
+		RoleIDs:       u.Roles.IDs(),
+		TwoFactor:     u.TwoFactorEnabled,
+		TokenVersion:  u.TokenVersion,
+		ClaimsVersion: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.Env.AccessTokenTTL)),
+		},
+	})
 
-	return tokenString, nil
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(key.private)
 }
 
+// the token is verified by the key of kid header, and the alg must be same as the key.
 func (j *AuthJWT) validateTokenJWT(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	return jwt.ParseWithClaims(tokenString, new(Claims), func(token *jwt.Token) (any, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no kid")
+		}
+
+		key, err := j.keys.lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return []byte(config.Env.JWTSecret), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}), jwt.WithExpirationRequired())
+}
+
+// public keys for verify the tokens, at /.well-known/jwks.json
+func (j *AuthJWT) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	// JWK Set must be the plain {"keys": [...]}, so it doesn't use utils.WriteJSON
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(JWKSMaxAge.Seconds())))
+	w.WriteHeader(http.StatusOK)
+
+	sonic.ConfigDefault.NewEncoder(w).Encode(j.keys.JWKS())
 }
 
 // get user login info from ctx.
@@ -163,33 +212,146 @@ func GetUserIDFromContext(ctx context.Context) string {
 	return ""
 }
 
+// get session id of the access token from ctx.
+func GetSessionIDFromContext(ctx context.Context) string {
+	if sessionID, ok := ctx.Value(sessionKey).(string); ok {
+		return sessionID
+	}
+
+	return ""
+}
+
 // using for blocking routes who doesn't have any roles.
-// make sure in params roles input values role at there.
+// make sure in params roles input values role at there. the roles is read from the token claims, without the db.
 func (j *AuthJWT) RoleGate(h http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaimsFromContext(r.Context())
+		if claims == nil {
+			utils.WriteJSONError(w, unauth, ua)
+			return
+		}
+
+		matched := make([]string, 0)
+
+		for _, name := range claims.Roles {
+			if slices.Contains(roles, name) {
+				matched = append(matched, name)
+			}
+		}
+
+		if len(matched) == 0 {
+			utils.WriteJSONError(w, unauth, ua)
+			return
+		}
+
+		// admin and staff must enable 2fa first, the enrol routes is not role gated so they still can do it
+		if !claims.TwoFactor && requireTwoFactor(matched) {
+			utils.WriteJSONError(w, http.StatusForbidden, errTwoFactorRequired)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// check the logged user has every permission, from any of the roles in the token claims.
+func (j *AuthJWT) RequirePermission(h http.HandlerFunc, permissions ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		userID := GetUserIDFromContext(ctx)
-		if userID == "" {
+		claims := GetClaimsFromContext(ctx)
+		if claims == nil {
 			utils.WriteJSONError(w, unauth, ua)
 			return
 		}
 
-		u, err := j.us.GetUserWithRolesByID(ctx, userID)
+		if claims.APIKeyID != "" {
+			for _, permission := range permissions {
+				if !slices.Contains(claims.Permissions, permission) {
+					utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("missing permission: %s", permission))
+					return
+				}
+			}
+
+			h(w, r)
+			return
+		}
+
+		granted, err := j.ps.GetPermissionsByRoleIDs(ctx, claims.RoleIDs)
 		if err != nil {
-			utils.WriteJSONError(w, unauth, ua)
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		for _, role := range u.Roles {
-			targetRoles := strings.Split(role.Name, ", ")
+		// roles which give at least one of the permissions
+		matched := make([]string, 0)
+
+		for _, permission := range permissions {
+			found := false
+
+			for i, roleID := range claims.RoleIDs {
+				if !slices.Contains(granted[roleID], permission) {
+					continue
+				}
+
+				found = true
+
+				if i < len(claims.Roles) && !slices.Contains(matched, claims.Roles[i]) {
+					matched = append(matched, claims.Roles[i])
+				}
+			}
 
-			if utils.CompareRole(roles, targetRoles) {
-				h(w, r)
+			if !found {
+				utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("missing permission: %s", permission))
 				return
 			}
 		}
 
-		utils.WriteJSONError(w, unauth, ua)
+		if !claims.TwoFactor && len(matched) > 0 && requireTwoFactor(matched) {
+			utils.WriteJSONError(w, http.StatusForbidden, errTwoFactorRequired)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// every permission of the caller, from the api key or any of the roles in the token claims, same as RequirePermission.
+func (j *AuthJWT) GetPermissions(ctx context.Context) ([]string, error) {
+	claims := GetClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, ua
+	}
+
+	if claims.APIKeyID != "" {
+		return claims.Permissions, nil
+	}
+
+	granted, err := j.ps.GetPermissionsByRoleIDs(ctx, claims.RoleIDs)
+	if err != nil {
+		return nil, err
 	}
+
+	permissions := make([]string, 0)
+
+	for _, roleID := range claims.RoleIDs {
+		for _, permission := range granted[roleID] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions, nil
+}
+
+// 2fa is required when every matched role is in config, so admin which is also user still can access the user routes.
+func requireTwoFactor(matched []string) bool {
+	for _, role := range matched {
+		if !slices.Contains(config.Env.TwoFactorRoles, role) {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,370 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	// the keys directory is read again after this, so the rotated key is picked up without restart.
+	keysReloadInterval = 1 * time.Minute
+
+	// the token with unknown kid read the directory again, but not more often than this.
+	// so the token signed by the new key at other replica is accepted, and the random kid can't make us read the disk at every request.
+	unknownKidReloadInterval = 10 * time.Second
+
+	// how long the consumers can cache /.well-known/jwks.json
+	JWKSMaxAge = 5 * time.Minute
+
+	// the new key is published at JWKS first, and it only sign after every replica and consumer has seen it.
+	KeyActivationDelay = JWKSMaxAge + keysReloadInterval
+
+	kidTimeFormat = "20060102150405"
+)
+
+// one key pair, the kid is the file name without .pem
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+
+	// parsed from the kid, zero when the kid has no time (the key is made by hand)
+	createdAt time.Time
+
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// all private keys at the directory. the newest key sign the tokens, and every key in the directory
+// can verify, so the token signed by the previous key still valid until it expired.
+type Keyring struct {
+	dir string
+
+	mu       sync.RWMutex
+	keys     map[string]*signingKey
+	loadedAt time.Time
+
+	// the last reload because of unknown kid
+	forcedAt time.Time
+}
+
+// load the keys directory, the first key is generated when it still empty.
+func LoadKeyring(dir, alg string) (*Keyring, error) {
+	k := &Keyring{dir: dir}
+
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+
+	if len(k.keys) == 0 {
+		if _, err := GenerateKey(dir, alg); err != nil {
+			return nil, err
+		}
+
+		if err := k.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) reload() error {
+	if err := os.MkdirAll(k.dir, 0o700); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(k.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(files))
+
+	for _, file := range files {
+		key, err := readKey(file)
+		if err != nil {
+			return err
+		}
+
+		keys[key.kid] = key
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = keys
+	k.loadedAt = time.Now()
+
+	return nil
+}
+
+func (k *Keyring) reloadIfStale() {
+	k.mu.RLock()
+	stale := time.Since(k.loadedAt) > keysReloadInterval
+	k.mu.RUnlock()
+
+	if stale {
+		_ = k.reload() // keep the loaded keys when the directory can't be read
+	}
+}
+
+// key for sign the new token, the newest key which is published long enough (KeyActivationDelay).
+// when there is no such key (ex: the first key), the newest one is used.
+func (k *Keyring) signing() (*signingKey, error) {
+	k.reloadIfStale()
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return activeKey(k.keys, time.Now())
+}
+
+func activeKey(keys map[string]*signingKey, now time.Time) (*signingKey, error) {
+	var active, newest *signingKey
+
+	for _, key := range keys {
+		// kid start with the creation time, so the greatest one is the newest
+		if newest == nil || key.kid > newest.kid {
+			newest = key
+		}
+
+		if now.Sub(key.createdAt) >= KeyActivationDelay && (active == nil || key.kid > active.kid) {
+			active = key
+		}
+	}
+
+	if active != nil {
+		return active, nil
+	}
+
+	if newest != nil {
+		return newest, nil
+	}
+
+	return nil, errors.New("no signing key")
+}
+
+// key for verify token by the kid header.
+func (k *Keyring) lookup(kid string) (*signingKey, error) {
+	k.reloadIfStale()
+
+	if key, exists := k.get(kid); exists {
+		return key, nil
+	}
+
+	// the key can be made after the last reload, ex: it's rotated and other replica sign with it
+	k.mu.Lock()
+	force := time.Since(k.forcedAt) > unknownKidReloadInterval
+	if force {
+		k.forcedAt = time.Now()
+	}
+	k.mu.Unlock()
+
+	if force {
+		_ = k.reload()
+
+		if key, exists := k.get(kid); exists {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown kid: %s", kid)
+}
+
+func (k *Keyring) get(kid string) (*signingKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, exists := k.keys[kid]
+	return key, exists
+}
+
+// public keys in JWK format (RFC 7517), for the other services verify our tokens.
+func (k *Keyring) JWKS() map[string]any {
+	k.reloadIfStale()
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+
+	// newest first
+	slices.Sort(kids)
+	slices.Reverse(kids)
+
+	jwks := make([]map[string]string, 0, len(kids))
+
+	for _, kid := range kids {
+		key := k.keys[kid]
+
+		jwk := map[string]string{
+			"kid": kid,
+			"alg": key.method.Alg(),
+			"use": "sig",
+		}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return map[string]any{"keys": jwks}
+}
+
+// make new key pair at the directory, and return the kid. alg is RS256 or EdDSA.
+func GenerateKey(dir, alg string) (string, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA, "":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("invalid jwt alg: %s", alg)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	kid := time.Now().UTC().Format(kidTimeFormat) + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	return kid, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600)
+}
+
+// remove the old keys, except the newest keep keys. the key is removed only when the newer key has signed
+// longer than retain (the access token ttl), so every token signed by it is already expired. it return the removed kid.
+func PruneKeys(dir string, keep int, retain time.Duration) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	if keep < 1 {
+		keep = 1
+	}
+
+	slices.Sort(files)
+
+	if len(files) <= keep {
+		return nil, nil
+	}
+
+	now := time.Now()
+	removed := make([]string, 0, len(files)-keep)
+
+	for i, file := range files[:len(files)-keep] {
+		// the key stop signing when the next key is activated
+		next := kidTime(strings.TrimSuffix(filepath.Base(files[i+1]), ".pem"))
+		if now.Sub(next) < KeyActivationDelay+retain {
+			continue
+		}
+
+		if err := os.Remove(file); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, strings.TrimSuffix(filepath.Base(file), ".pem"))
+	}
+
+	return removed, nil
+}
+
+// the creation time at the kid, ex: "20250101120000-a1b2c3d4". zero time when it has no time.
+func kidTime(kid string) time.Time {
+	prefix, _, _ := strings.Cut(kid, "-")
+
+	t, err := time.Parse(kidTimeFormat, prefix)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+func readKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem file: %s", file)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+	key := &signingKey{kid: kid, createdAt: kidTime(kid)}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+		key.private = private
+		key.public = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.private = private
+		key.public = private.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type at %s", file)
+	}
+
+	return key, nil
+}
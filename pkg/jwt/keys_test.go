@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// make key at dir which is created at the given time.
+func makeKey(t *testing.T, dir string, createdAt time.Time) string {
+	t.Helper()
+
+	kid, err := GenerateKey(dir, AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aged := createdAt.UTC().Format(kidTimeFormat) + kid[len(kidTimeFormat):]
+
+	if err := os.Rename(filepath.Join(dir, kid+".pem"), filepath.Join(dir, aged+".pem")); err != nil {
+		t.Fatal(err)
+	}
+
+	return aged
+}
+
+func jwksKids(k *Keyring) []string {
+	kids := make([]string, 0)
+
+	for _, jwk := range k.JWKS()["keys"].([]map[string]string) {
+		kids = append(kids, jwk["kid"])
+	}
+
+	return kids
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Now()
+
+	t.Run("it should generate the first key and sign with it", func(t *testing.T) {
+		k, err := LoadKeyring(t.TempDir(), AlgEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := k.signing()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if kids := jwksKids(k); !slices.Equal(kids, []string{key.kid}) {
+			t.Errorf("expected jwks %v, got %v", []string{key.kid}, kids)
+		}
+	})
+
+	t.Run("it should publish the new key before sign with it", func(t *testing.T) {
+		dir := t.TempDir()
+
+		old := makeKey(t, dir, now.Add(-24*time.Hour))
+		fresh := makeKey(t, dir, now)
+
+		k, err := LoadKeyring(dir, AlgEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := k.signing()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.kid != old {
+			t.Errorf("expected the old key %s still sign, got %s", old, key.kid)
+		}
+
+		if kids := jwksKids(k); !slices.Contains(kids, fresh) {
+			t.Errorf("expected the new key %s at jwks, got %v", fresh, kids)
+		}
+
+		key, err = activeKey(k.keys, now.Add(KeyActivationDelay+time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.kid != fresh {
+			t.Errorf("expected the new key %s sign after the activation delay, got %s", fresh, key.kid)
+		}
+	})
+
+	t.Run("it should reload the keys at unknown kid", func(t *testing.T) {
+		dir := t.TempDir()
+		makeKey(t, dir, now.Add(-24*time.Hour))
+
+		k, err := LoadKeyring(dir, AlgEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the key is rotated by other replica after we load the directory
+		rotated := makeKey(t, dir, now)
+
+		if _, err := k.lookup(rotated); err != nil {
+			t.Errorf("expected the rotated key is found, got %v", err)
+		}
+
+		if _, err := k.lookup("20000101000000-unknown"); err == nil {
+			t.Error("expected error for unknown kid")
+		}
+	})
+
+	t.Run("it should not reload at every unknown kid", func(t *testing.T) {
+		dir := t.TempDir()
+		makeKey(t, dir, now.Add(-24*time.Hour))
+
+		k, err := LoadKeyring(dir, AlgEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k.lookup("20000101000000-unknown")
+
+		rotated := makeKey(t, dir, now)
+
+		if _, err := k.lookup(rotated); err == nil {
+			t.Error("expected the directory isn't read again within the reload interval")
+		}
+	})
+}
+
+func TestPruneKeys(t *testing.T) {
+	now := time.Now()
+	retain := 15 * time.Minute
+
+	tests := []struct {
+		name    string
+		ages    []time.Duration // age of each key, the oldest first
+		keep    int
+		removed []int // index of the removed keys
+	}{
+		{
+			name:    "it should remove the key which tokens are expired",
+			ages:    []time.Duration{72 * time.Hour, 48 * time.Hour, 24 * time.Hour},
+			keep:    1,
+			removed: []int{0, 1},
+		},
+		{
+			name:    "it should keep the newest keys",
+			ages:    []time.Duration{72 * time.Hour, 48 * time.Hour, 24 * time.Hour},
+			keep:    3,
+			removed: []int{},
+		},
+		{
+			name:    "it should keep the key which tokens can still be valid",
+			ages:    []time.Duration{72 * time.Hour, KeyActivationDelay + retain - time.Minute},
+			keep:    1,
+			removed: []int{},
+		},
+		{
+			name:    "it should keep the key which is still signing",
+			ages:    []time.Duration{72 * time.Hour, 0},
+			keep:    1,
+			removed: []int{},
+		},
+		{
+			name:    "it should remove the key after the next one sign longer than retain",
+			ages:    []time.Duration{72 * time.Hour, KeyActivationDelay + retain + time.Minute},
+			keep:    1,
+			removed: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			kids := make([]string, 0, len(tt.ages))
+			for _, age := range tt.ages {
+				kids = append(kids, makeKey(t, dir, now.Add(-age)))
+			}
+
+			removed, err := PruneKeys(dir, tt.keep, retain)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make([]string, 0, len(tt.removed))
+			for _, i := range tt.removed {
+				want = append(want, kids[i])
+			}
+
+			if !slices.Equal(removed, want) {
+				t.Errorf("expected removed %v, got %v", want, removed)
+			}
+
+			for _, kid := range want {
+				if _, err := os.Stat(filepath.Join(dir, kid+".pem")); !os.IsNotExist(err) {
+					t.Errorf("expected %s is removed from the directory", kid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/perpus_backend/utils"
+)
+
+// who is the client for the rate limiter, ex: "user:<id>" or "ip:<ip>".
+// only the token signature is checked (no session and user lookup), so the forged token can't take the bucket of other user.
+// the api key use the ip bucket, so the random keys are limited before they are looked up at db. the invalid credential fall back to the ip too.
+// after the api key is validated, it's charged at own bucket too (see SetAPIKeyLimit).
+func (j *AuthJWT) RateLimitKey(r *http.Request) string {
+	if strings.HasPrefix(r.Header.Get("Authorization"), apiKeyScheme) {
+		return "ip:" + utils.GetClientIP(r)
+	}
+
+	if tokenString := utils.GetTokenFromRequest(r); tokenString != "" {
+		token, err := j.validateTokenJWT(tokenString)
+		if err == nil && token.Valid {
+			if claims := token.Claims.(*Claims); claims.UserID != "" {
+				return "user:" + claims.UserID
+			}
+		}
+	}
+
+	return "ip:" + utils.GetClientIP(r)
+}
+
+// limit the client "api_key:<id>", it return false after the 429 response is written.
+type APIKeyLimitFunc func(w http.ResponseWriter, r *http.Request, client string) bool
+
+// set the limit which is charged after the api key is validated, so the keys from the same ip have separate buckets.
+func (j *AuthJWT) SetAPIKeyLimit(f APIKeyLimitFunc) {
+	j.apiKeyLimit = f
+}
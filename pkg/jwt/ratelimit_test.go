@@ -0,0 +1,144 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/pkg/limiter"
+	"github.com/perpus_backend/types"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fail the test when the api key is looked up, the limiter must not hit the db.
+type noLookupAPIKeyStore struct {
+	types.MockAPIKeyStore
+
+	t *testing.T
+}
+
+func (s noLookupAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	s.t.Error("expected the api key isn't looked up")
+	return nil, nil
+}
+
+// the api keys by the hash of the raw key.
+type hashAPIKeyStore struct {
+	types.MockAPIKeyStore
+
+	keys map[string]*types.APIKey
+}
+
+func (s hashAPIKeyStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*types.APIKey, error) {
+	if k, ok := s.keys[keyHash]; ok {
+		return k, nil
+	}
+
+	return s.MockAPIKeyStore.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	keys, err := LoadKeyring(t.TempDir(), AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	j := NewAuthJWT(types.MockUserStore{}, types.MockSessionStore{}, types.MockPermissionStore{}, noLookupAPIKeyStore{t: t}, keys, rdb)
+
+	token, err := j.CreateTokenJWT(context.Background(), &types.User{ID: "user-1"}, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          string
+	}{
+		{name: "it should use the user bucket for valid token", authorization: "Bearer " + token, want: "user:user-1"},
+		{name: "it should use the ip bucket for api key", authorization: "ApiKey random-key", want: "ip:203.0.113.9"},
+		{name: "it should use the ip bucket for forged token", authorization: "Bearer " + token[:len(token)-4] + "AAAA", want: "ip:203.0.113.9"},
+		{name: "it should use the ip bucket without credential", want: "ip:203.0.113.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.9:1234"
+			req.Header.Set("Authorization", tt.authorization)
+
+			if got := j.RateLimitKey(req); got != tt.want {
+				t.Errorf("expected key %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAPIKeyLimit(t *testing.T) {
+	keys, err := LoadKeyring(t.TempDir(), AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	ks := hashAPIKeyStore{keys: map[string]*types.APIKey{
+		hash.HashToken("kiosk-key"):   {ID: "key-1"},
+		hash.HashToken("catalog-key"): {ID: "key-2"},
+	}}
+
+	j := NewAuthJWT(types.MockUserStore{}, types.MockSessionStore{}, types.MockPermissionStore{}, ks, keys, rdb)
+
+	l, err := limiter.NewLimiter(rdb, j.RateLimitKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.SetAPIKeyLimit(func(w http.ResponseWriter, r *http.Request, client string) bool {
+		return l.Limit(w, r, "api_key", client, &limiter.Rule{Limit: 1, Period: time.Minute})
+	})
+
+	handler := j.AuthWithAPIKeyOrJWT(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.9:1234"
+		req.Header.Set("Authorization", "ApiKey "+key)
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		return rr.Code
+	}
+
+	t.Run("it should limit the api key at own bucket", func(t *testing.T) {
+		if code := serve("kiosk-key"); code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, code)
+		}
+
+		if code := serve("kiosk-key"); code != http.StatusTooManyRequests {
+			t.Errorf("expected status code %d, got %d", http.StatusTooManyRequests, code)
+		}
+	})
+
+	t.Run("it should not limit other api key from the same ip", func(t *testing.T) {
+		if code := serve("catalog-key"); code != http.StatusNoContent {
+			t.Errorf("expected status code %d, got %d", http.StatusNoContent, code)
+		}
+	})
+
+	t.Run("it should not charge the bucket for invalid api key", func(t *testing.T) {
+		if code := serve("random-key"); code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, code)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// refresh token saved at redis by the hash, the raw token only live at client cookie.
+type refreshRecord struct {
+	UserID       string `json:"user_id"`
+	SessionID    string `json:"session_id"`
+	TokenVersion int    `json:"token_version"`
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was reused, the session is revoked")
+)
+
+// one login is one session (token family). every refresh rotate the refresh token inside the same session,
+// and the session key is what keep the access tokens alive. the device, ip, and user agent is taken from meta.
+func (j *AuthJWT) CreateSession(ctx context.Context, userID string, meta *types.Session) (string, string, error) {
+	u, err := j.us.GetUserWithRolesByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	meta.ID = uuid.NewString()
+	meta.UserID = u.ID
+
+	if err := j.ss.CreateSession(ctx, meta, config.Env.RefreshTokenTTL); err != nil {
+		return "", "", err
+	}
+
+	return j.issueTokens(ctx, u, meta.ID)
+}
+
+// rotate the refresh token. the used one can't be used again, when it was used twice
+// (stolen token) the whole session is revoked, so both the thief and the user must login again.
+func (j *AuthJWT) RefreshSession(ctx context.Context, refreshToken string) (string, string, error) {
+	if refreshToken == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	hashed := hash.HashToken(refreshToken)
+
+	res, err := j.rdb.Get(ctx, "refresh:"+hashed).Result()
+	if err == redis.Nil {
+		return "", "", ErrInvalidRefreshToken
+	} else if err != nil {
+		return "", "", err
+	}
+
+	record := new(refreshRecord)
+	if err := sonic.Unmarshal([]byte(res), record); err != nil {
+		return "", "", err
+	}
+
+	// only the first request can mark the token as used, so the concurrent reuse is detected too
+	ok, err := j.rdb.SetNX(ctx, "refresh_used:"+hashed, 1, config.Env.RefreshTokenTTL).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	if !ok {
+		if err := j.RevokeSession(ctx, record.SessionID); err != nil {
+			return "", "", err
+		}
+
+		return "", "", ErrRefreshTokenReused
+	}
+
+	u, err := j.us.GetUserWithRolesByID(ctx, record.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// logout from all devices (token_version bumped) also end this session
+	if u.TokenVersion != record.TokenVersion {
+		_ = j.RevokeSession(ctx, record.SessionID)
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	if err := j.ss.ExtendSession(ctx, record.SessionID, config.Env.RefreshTokenTTL); err != nil {
+		return "", "", err
+	}
+
+	return j.issueTokens(ctx, u, record.SessionID)
+}
+
+// delete the session and all of the refresh tokens, the access tokens of the session is rejected right away.
+func (j *AuthJWT) RevokeSession(ctx context.Context, sessionID string) error {
+	sessionKey, err := utils.Redis2Key("session", sessionID)
+	if err != nil {
+		return err
+	}
+
+	hashes, err := j.rdb.SMembers(ctx, sessionKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(hashes)*2+1)
+	keys = append(keys, sessionKey)
+
+	for _, h := range hashes {
+		keys = append(keys, "refresh:"+h, "refresh_used:"+h)
+	}
+
+	if err := j.rdb.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+
+	_ = j.ss.DeleteSession(ctx, sessionID) // the record can be expired already
+	return nil
+}
+
+// revoke all sessions of user at every device.
+func (j *AuthJWT) RevokeUserSessions(ctx context.Context, userID string) error {
+	sessions, err := j.ss.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := j.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *AuthJWT) issueTokens(ctx context.Context, u *types.User, sessionID string) (string, string, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := sonic.Marshal(refreshRecord{UserID: u.ID, SessionID: sessionID, TokenVersion: u.TokenVersion})
+	if err != nil {
+		return "", "", err
+	}
+
+	hashed := hash.HashToken(refreshToken)
+	sessionKey := "session:" + sessionID
+	ttl := config.Env.RefreshTokenTTL
+
+	if _, err := j.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetEx(ctx, "refresh:"+hashed, data, ttl)
+		pipe.SAdd(ctx, sessionKey, hashed)
+		pipe.Expire(ctx, sessionKey, ttl)
+		return nil
+	}); err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := j.CreateTokenJWT(ctx, u, sessionID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -1,28 +1,237 @@
 package limiter
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/perpus_backend/utils"
 
-	"golang.org/x/time/rate"
+	"github.com/redis/go-redis/v9"
 )
 
-// Limiter request get into routes. Param timer for set when request has been limit.
-// Param attempts for how many attempts you want to. ex: 10, 20, 66, and etc.
-func SetRateLimitMiddleware(timer rate.Limit, attempts int) func(http.Handler) http.Handler {
-	limiter := rate.NewLimiter(timer, attempts) // initial limiter for routes
+// GCRA (generic cell rate algorithm), the bucket is one "theoretical arrival time" at redis, so it's shared by every replica.
+// the time is from redis, so the clock of the replicas doesn't matter.
+//
+//	KEYS[1] bucket key
+//	ARGV[1] limit (burst), ARGV[2] period in seconds
+//
+// return {allowed, remaining, retry_after, reset_after}, the seconds is string because lua number is cut to integer.
+var gcraScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local interval = period / limit
 
+local t = redis.call("TIME")
+local now = (tonumber(t[1]) - 1700000000) + tonumber(t[2]) / 1000000
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local diff = now - (new_tat - period)
+
+if diff < 0 then
+	return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+redis.call("SET", KEYS[1], tostring(new_tat), "EX", math.ceil(new_tat - now))
+
+return {1, math.floor(diff / interval), "0", tostring(new_tat - now)}
+`)
+
+// get who is the client, ex: "user:<id>", "ip:<ip>".
+type KeyFunc func(r *http.Request) string
+
+// the limit of a route group, ex: 60 requests per minute.
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// parse "limit/period", ex: 60/1m, 3000/1h. empty or "none" mean no limit, so it return nil.
+func ParseRule(s string) (*Rule, error) {
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+
+	l, err := strconv.Atoi(limit)
+	if err != nil || l < 1 {
+		return nil, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+
+	p, err := time.ParseDuration(period)
+	if err != nil || p <= 0 {
+		return nil, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+
+	return &Rule{Limit: l, Period: p}, nil
+}
+
+type Limiter struct {
+	rdb *redis.Client
+	key KeyFunc
+
+	// exempted client key (user:<id>) and ip or cidr
+	exemptKeys     map[string]bool
+	exemptPrefixes []netip.Prefix
+}
+
+// the exempt entry is client key (ex: user:<id>, api_key:<id>), ip, or cidr (ex: 10.0.0.0/8).
+func NewLimiter(rdb *redis.Client, key KeyFunc, exempt []string) (*Limiter, error) {
+	l := &Limiter{rdb: rdb, key: key, exemptKeys: make(map[string]bool)}
+
+	for _, entry := range exempt {
+		ip := strings.TrimPrefix(entry, "ip:")
+
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			l.exemptPrefixes = append(l.exemptPrefixes, prefix.Masked())
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			l.exemptPrefixes = append(l.exemptPrefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		if !strings.Contains(entry, ":") {
+			return nil, fmt.Errorf("invalid rate limit exemption: %s", entry)
+		}
+
+		l.exemptKeys[entry] = true
+	}
+
+	return l, nil
+}
+
+type result struct {
+	allowed   bool
+	remaining int64
+
+	retryAfter, resetAfter time.Duration
+}
+
+// Limit each client at the route group, the group has own bucket. nil rule is no limit.
+// the response has RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset headers, and Retry-After when it's limited.
+func (l *Limiter) Middleware(group string, rule *Rule) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if rule == nil {
+			return next
+		}
+
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !limiter.Allow() {
-				utils.WriteJSONError(w, http.StatusTooManyRequests, errors.New("too many requests"))
+			// the preflight doesn't have the credential, and the browser send it before the real request
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			limiter.Wait(r.Context())
-			next.ServeHTTP(w, r)
+			if l.Limit(w, r, group, l.key(r), rule) {
+				next.ServeHTTP(w, r)
+			}
 		})
 	}
 }
+
+// Limit the client at the group bucket and write the RateLimit headers, it return false after the 429 response is written.
+// it's for the client which is known after the middleware, ex: the api key after it's validated.
+func (l *Limiter) Limit(w http.ResponseWriter, r *http.Request, group, client string, rule *Rule) bool {
+	if rule == nil || l.isExempt(r, client) {
+		return true
+	}
+
+	res, err := l.allow(r.Context(), "rate_limit:"+group+":"+client, rule)
+	if err != nil {
+		// the api still serve when redis is down
+		log.Printf("rate limit %s: %v", group, err)
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatInt(res.remaining, 10))
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.resetAfter), 10))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Period)))
+
+	if !res.allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(res.retryAfter), 10))
+		utils.WriteJSONError(w, http.StatusTooManyRequests, errors.New("too many requests"))
+		return false
+	}
+
+	return true
+}
+
+func (l *Limiter) allow(ctx context.Context, key string, rule *Rule) (*result, error) {
+	values, err := gcraScript.Run(ctx, l.rdb, []string{key}, rule.Limit, rule.Period.Seconds()).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit result: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+
+	retryAfter, err := parseSeconds(values[2])
+	if err != nil {
+		return nil, err
+	}
+
+	resetAfter, err := parseSeconds(values[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return &result{allowed: allowed == 1, remaining: remaining, retryAfter: retryAfter, resetAfter: resetAfter}, nil
+}
+
+func (l *Limiter) isExempt(r *http.Request, client string) bool {
+	if l.exemptKeys[client] {
+		return true
+	}
+
+	if len(l.exemptPrefixes) > 0 {
+		if addr, err := netip.ParseAddr(utils.GetClientIP(r)); err == nil {
+			addr = addr.Unmap()
+
+			for _, prefix := range l.exemptPrefixes {
+				if prefix.Contains(addr) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func parseSeconds(v any) (time.Duration, error) {
+	s, _ := v.(string)
+
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected rate limit seconds: %v", v)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,206 @@
+package limiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    *Rule
+		wantErr bool
+	}{
+		{name: "it should parse limit per period", rule: "60/1m", want: &Rule{Limit: 60, Period: time.Minute}},
+		{name: "it should parse the period in hours", rule: "3000/1h", want: &Rule{Limit: 3000, Period: time.Hour}},
+		{name: "it should be no limit at none", rule: "none", want: nil},
+		{name: "it should be no limit at empty", rule: "", want: nil},
+		{name: "it should reject the rule without period", rule: "60", wantErr: true},
+		{name: "it should reject zero limit", rule: "0/1m", wantErr: true},
+		{name: "it should reject negative period", rule: "60/-1m", wantErr: true},
+		{name: "it should reject invalid period", rule: "60/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("expected rule %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		exempt  []string
+		client  string
+		ip      string
+		want    bool
+		wantErr bool
+	}{
+		{name: "it should exempt the client key", exempt: []string{"user:1"}, client: "user:1", ip: "203.0.113.9", want: true},
+		{name: "it should not exempt the other client", exempt: []string{"user:1"}, client: "user:2", ip: "203.0.113.9", want: false},
+		{name: "it should exempt the ip", exempt: []string{"203.0.113.9"}, client: "ip:203.0.113.9", ip: "203.0.113.9", want: true},
+		{name: "it should exempt the ip with prefix", exempt: []string{"ip:203.0.113.9"}, client: "user:1", ip: "203.0.113.9", want: true},
+		{name: "it should exempt the ip at cidr", exempt: []string{"10.1.2.3/8"}, client: "user:1", ip: "10.200.0.1", want: true},
+		{name: "it should not exempt the ip outside cidr", exempt: []string{"10.0.0.0/8"}, client: "user:1", ip: "11.0.0.1", want: false},
+		{name: "it should exempt the ipv6", exempt: []string{"::1"}, client: "ip:::1", ip: "::1", want: true},
+		{name: "it should exempt the ipv4 mapped ipv6", exempt: []string{"127.0.0.0/8"}, client: "user:1", ip: "::ffff:127.0.0.1", want: true},
+		{name: "it should reject the invalid entry", exempt: []string{"localhost"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLimiter(nil, nil, tt.exempt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "[" + tt.ip + "]:1234"
+
+			if got := l.isExempt(req, tt.client); got != tt.want {
+				t.Errorf("expected exempt %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+
+	l, err := NewLimiter(rdb, func(r *http.Request) string { return r.Header.Get("X-Client") }, []string{"user:admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rule := &Rule{Limit: 3, Period: time.Minute}
+	h := l.Middleware("api", rule)(ok)
+
+	serve := func(h http.Handler, method, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("X-Client", client)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("it should allow the burst and count the remaining", func(t *testing.T) {
+		for _, remaining := range []string{"2", "1", "0"} {
+			w := serve(h, http.MethodGet, "user:1")
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+
+			if got := w.Header().Get("RateLimit-Remaining"); got != remaining {
+				t.Errorf("expected remaining %s, got %s", remaining, got)
+			}
+		}
+
+		if got := serve(h, http.MethodGet, "user:1").Header().Get("RateLimit-Policy"); got != "3;w=60" {
+			t.Errorf("expected policy 3;w=60, got %s", got)
+		}
+	})
+
+	t.Run("it should limit after the burst with retry after one interval", func(t *testing.T) {
+		w := serve(h, http.MethodGet, "user:1")
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+
+		if got := w.Header().Get("Retry-After"); got != "20" {
+			t.Errorf("expected retry after 20, got %s", got)
+		}
+
+		if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+			t.Errorf("expected reset 60, got %s", got)
+		}
+	})
+
+	t.Run("it should allow again after the interval", func(t *testing.T) {
+		mr.SetTime(now.Add(20 * time.Second))
+
+		if w := serve(h, http.MethodGet, "user:1"); w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if w := serve(h, http.MethodGet, "user:1"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+	})
+
+	t.Run("it should have own bucket for each client and group", func(t *testing.T) {
+		if w := serve(h, http.MethodGet, "user:2"); w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		suggest := l.Middleware("suggest", rule)(ok)
+
+		if w := serve(suggest, http.MethodGet, "user:1"); w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("it should not limit the preflight and the exempted client", func(t *testing.T) {
+		for range 5 {
+			if w := serve(h, http.MethodOptions, "user:1"); w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+
+			w := serve(h, http.MethodGet, "user:admin")
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+
+			if w.Header().Get("RateLimit-Limit") != "" {
+				t.Errorf("expected no rate limit headers for exempted client")
+			}
+		}
+	})
+
+	t.Run("it should not limit without rule", func(t *testing.T) {
+		h := l.Middleware("none", nil)(ok)
+
+		for range 5 {
+			if w := serve(h, http.MethodGet, "user:1"); w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+		}
+	})
+
+	t.Run("it should serve when redis is down", func(t *testing.T) {
+		mr.Close()
+
+		if w := serve(h, http.MethodGet, "user:1"); w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+}
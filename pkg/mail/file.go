@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/types"
+)
+
+// write every mail as .eml file at the directory, for local development without smtp server.
+type FileSender struct {
+	dir string
+}
+
+func NewFileSender(dir string) *FileSender {
+	if dir == "" {
+		dir = "./storage/mail"
+	}
+
+	return &FileSender{dir: dir}
+}
+
+func (s *FileSender) Send(ctx context.Context, m *types.Mail) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// the recipient is part of file name, so it's easy to find the mail
+	name := time.Now().Format("20060102150405.000000") + "-" + strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(m.To) + ".eml"
+
+	path := filepath.Join(s.dir, name)
+
+	if err := os.WriteFile(path, buildMessage(config.Env.MailFrom, m), 0o644); err != nil {
+		return err
+	}
+
+	log.Printf("mail to %s written at %s", m.To, path)
+	return nil
+}
+
+// the token at the link of reset password and verify email.
+var tokenPattern = regexp.MustCompile(`([?&]token=)[^\s&]+`)
+
+// only print the mail into log, the token is redacted so the log can't be used to reset the password.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, m *types.Mail) error {
+	log.Printf("mail to %s, subject: %s\n%s", m.To, m.Subject, tokenPattern.ReplaceAllString(m.Body, "${1}[redacted]"))
+	return nil
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/types"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+	DriverLog  = "log"
+)
+
+// the empty driver is only allowed outside production, the reset and verification mail must be sent for real.
+func Check() error {
+	if config.Env.MailDriver != "" {
+		return nil
+	}
+
+	if config.Env.AppENV == "production" {
+		return errors.New("MAIL_DRIVER is required at production")
+	}
+
+	log.Println("MAIL_DRIVER is empty, the mail is only printed into log")
+
+	return nil
+}
+
+// choose the mail sender by driver, empty driver use log sender for local development.
+func NewMailSender(driver string) (types.MailSender, error) {
+	switch driver {
+	case DriverSMTP:
+		if config.Env.SMTPHost == "" {
+			return nil, fmt.Errorf("smtp host is required for mail driver smtp")
+		}
+
+		return NewSMTPSender(config.Env.SMTPHost, config.Env.SMTPPort, config.Env.SMTPUsername, config.Env.SMTPPassword, config.Env.MailFrom), nil
+	case DriverFile:
+		return NewFileSender(config.Env.MailDir), nil
+	case DriverLog, "":
+		return NewLogSender(), nil
+	default:
+		return nil, fmt.Errorf("invalid mail driver: %s", driver)
+	}
+}
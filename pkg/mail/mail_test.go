@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/types"
+)
+
+func TestCheck(t *testing.T) {
+	appENV, mailDriver := config.Env.AppENV, config.Env.MailDriver
+	defer func() {
+		config.Env.AppENV, config.Env.MailDriver = appENV, mailDriver
+	}()
+
+	tests := []struct {
+		name    string
+		env     string
+		driver  string
+		wantErr bool
+	}{
+		{name: "it should require the driver at production", env: "production", driver: "", wantErr: true},
+		{name: "it should allow the driver at production", env: "production", driver: DriverSMTP, wantErr: false},
+		{name: "it should allow the empty driver at debug", env: "debug", driver: "", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Env.AppENV, config.Env.MailDriver = tt.env, tt.driver
+
+			if err := Check(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	var buf bytes.Buffer
+
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	err := NewLogSender().Send(context.Background(), &types.Mail{
+		To:      "budi@example.com",
+		Subject: "Reset Password",
+		Body:    "Buka link berikut:\nhttp://localhost:3000/reset-password?token=secret-token&lang=id\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should redact the token", func(t *testing.T) {
+		if strings.Contains(buf.String(), "secret-token") {
+			t.Errorf("expected the token is redacted, got %s", buf.String())
+		}
+
+		if !strings.Contains(buf.String(), "/reset-password?token=[redacted]&lang=id") {
+			t.Errorf("expected the link without token, got %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,265 @@
+// Package oidctest is a minimal OpenID provider, for local development and test the sso login without the real identity provider.
+// every authorization request is approved automatically as the configured user.
+package oidctest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/perpus_backend/pkg/oidc"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type User struct {
+	Subject       string
+	Email         string
+	Name          string
+	EmailVerified bool
+}
+
+type IdP struct {
+	Issuer   string
+	ClientID string
+
+	// logged in user, it can be replaced per request with sub, email and name query at authorize url
+	User User
+
+	key ed25519.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]*authRequest
+}
+
+type authRequest struct {
+	expiresAt time.Time
+
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+}
+
+const codeTTL = 1 * time.Minute
+
+func NewIdP(issuer, clientID string) (*IdP, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdP{
+		Issuer:   issuer,
+		ClientID: clientID,
+		User: User{
+			Subject:       "mock-user-1",
+			Email:         "staff@perpus.test",
+			Name:          "Mock Staff",
+			EmailVerified: true,
+		},
+		key:   key,
+		kid:   kid[:8],
+		codes: make(map[string]*authRequest),
+	}, nil
+}
+
+// start the idp at local httptest server, the issuer is the server url. close it after use.
+func NewServer(clientID string) (*httptest.Server, *IdP, error) {
+	idp, err := NewIdP("", clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srv := httptest.NewServer(idp)
+	idp.Issuer = srv.URL
+
+	return srv, idp, nil
+}
+
+func (i *IdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                i.Issuer,
+			"authorization_endpoint":                i.Issuer + "/authorize",
+			"token_endpoint":                        i.Issuer + "/token",
+			"jwks_uri":                              i.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"EdDSA"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+			"kid": i.kid,
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"alg": "EdDSA",
+			"use": "sig",
+			"x":   base64.RawURLEncoding.EncodeToString(i.key.Public().(ed25519.PublicKey)),
+		}}})
+	case "/authorize":
+		i.handleAuthorize(w, r)
+	case "/token":
+		i.handleToken(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (i *IdP) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("response_type") != "code" || q.Get("client_id") != i.ClientID || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// pkce is required
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request: pkce S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	user := i.User
+
+	if v := q.Get("sub"); v != "" {
+		user.Subject = v
+	}
+
+	if v := q.Get("email"); v != "" {
+		user.Email = v
+	}
+
+	if v := q.Get("name"); v != "" {
+		user.Name = v
+	}
+
+	code, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	i.mu.Lock()
+	i.codes[code] = &authRequest{
+		expiresAt:   time.Now().Add(codeTTL),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        user,
+	}
+	i.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (i *IdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method post only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	clientID := r.FormValue("client_id")
+	if id, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(id)
+	}
+
+	if r.FormValue("grant_type") != "authorization_code" || clientID != i.ClientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	// the code is single use
+	i.mu.Lock()
+	req, ok := i.codes[r.FormValue("code")]
+	delete(i.codes, r.FormValue("code"))
+	i.mu.Unlock()
+
+	if !ok || time.Now().After(req.expiresAt) || req.redirectURI != r.FormValue("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	if oidc.CodeChallenge(r.FormValue("code_verifier")) != req.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &oidc.Claims{
+		Email:         req.user.Email,
+		EmailVerified: req.user.EmailVerified,
+		Name:          req.user.Name,
+		Nonce:         req.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.Issuer,
+			Subject:   req.user.Subject,
+			Audience:  jwt.ClaimStrings{i.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = i.kid
+
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+
+	accessToken, err := oidc.RandomString()
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oidc.Token{
+		AccessToken: accessToken,
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   300,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	data, _ := sonic.Marshal(v)
+	w.Write(data)
+}
@@ -0,0 +1,43 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/rs/xid"
+)
+
+type contextKey string
+
+const (
+	Header = "X-Request-ID"
+
+	requestIDKey contextKey = "requestID"
+)
+
+// the id from proxy is kept, when it's safe to be written into log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// give every request an id, it's sent back at the response header so the client can report it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validRequestID.MatchString(id) {
+			id = xid.New().String()
+		}
+
+		w.Header().Set(Header, id)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// get request id from ctx, empty when the middleware isn't used.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+
+	return ""
+}
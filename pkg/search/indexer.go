@@ -0,0 +1,221 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/perpus_backend/types"
+)
+
+// searchable resource, the permission is same as the REST routes of each resource.
+type Resource struct {
+	Permission string
+
+	// attributes which go into the search index. the sensitive one (password, token_version, buku_pdf)
+	// never be indexed, so it can't leak from any search result.
+	Indexed []string
+
+	// attributes that can be retrieved by permission. the read permission which isn't listed get all indexed attributes.
+	Fields map[string][]string
+
+	// filter, sort and facet attributes, nil mean the index doesn't need settings.
+	Settings *types.SearchSettings
+
+	Docs func(ctx context.Context) any
+}
+
+// check the permissions of the caller has the read permission of the resource.
+func (res Resource) VisibleTo(permissions []string) bool {
+	return slices.Contains(permissions, res.Permission)
+}
+
+// union of attributes from all permissions of the caller.
+func (res Resource) AttributesFor(permissions []string) []string {
+	attrs := make([]string, 0, len(res.Indexed))
+
+	if !res.VisibleTo(permissions) {
+		return attrs
+	}
+
+	for _, permission := range permissions {
+		fields, exists := res.Fields[permission]
+		if !exists {
+			if permission == res.Permission {
+				return res.Indexed
+			}
+
+			continue
+		}
+
+		for _, f := range fields {
+			if !slices.Contains(attrs, f) {
+				attrs = append(attrs, f)
+			}
+		}
+	}
+
+	return attrs
+}
+
+// keep the search index in sync with the stores, and used by the websocket search and suggestion.
+type Indexer struct {
+	index     types.SearchIndex
+	resources map[string]Resource
+
+	// documents is reindexed from db when the last sync was older than this.
+	ttl time.Duration
+
+	mu     sync.Mutex
+	synced map[string]time.Time
+}
+
+func NewIndexer(index types.SearchIndex, resources map[string]Resource, ttl time.Duration) *Indexer {
+	return &Indexer{
+		index:     index,
+		resources: resources,
+		ttl:       ttl,
+		synced:    make(map[string]time.Time),
+	}
+}
+
+func (i *Indexer) Resource(name string) (Resource, bool) {
+	res, exists := i.resources[name]
+	return res, exists
+}
+
+// set the search index settings, called once at startup.
+func (i *Indexer) Configure(ctx context.Context) error {
+	for name, res := range i.resources {
+		if res.Settings == nil {
+			continue
+		}
+
+		if err := i.index.Configure(ctx, name, res.Settings); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// index the resource documents, only when the last sync was expired.
+func (i *Indexer) Sync(ctx context.Context, name string) error {
+	res, exists := i.resources[name]
+	if !exists {
+		return fmt.Errorf("resource %s not found", name)
+	}
+
+	i.mu.Lock()
+	last, done := i.synced[name]
+	i.mu.Unlock()
+
+	if done && time.Since(last) < i.ttl {
+		return nil
+	}
+
+	docs, err := ProjectDocuments(res.Docs(ctx), res.Indexed)
+	if err != nil {
+		return err
+	}
+
+	if err := i.index.AddDocuments(ctx, name, "id", docs); err != nil {
+		return err
+	}
+
+	// the documents which is deleted from db, so the deleted user or member can't be found anymore.
+	// nil docs mean the store failed to load, so nothing is deleted.
+	if docs != nil {
+		if err := i.deleteStale(ctx, name, docs); err != nil {
+			return err
+		}
+	}
+
+	i.mu.Lock()
+	i.synced[name] = time.Now()
+	i.mu.Unlock()
+
+	return nil
+}
+
+func (i *Indexer) deleteStale(ctx context.Context, name string, docs []map[string]any) error {
+	current := make(map[string]struct{}, len(docs))
+
+	for _, doc := range docs {
+		current[fmt.Sprint(doc["id"])] = struct{}{}
+	}
+
+	ids, err := i.index.DocumentIDs(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	stale := make([]string, 0)
+
+	for _, id := range ids {
+		if _, exists := current[id]; !exists {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	return i.index.DeleteDocuments(ctx, name, stale)
+}
+
+// remove the documents from the index, ex: the anonymized member. the resource is synced again at the next search.
+func (i *Indexer) Purge(ctx context.Context, name string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := i.index.DeleteDocuments(ctx, name, ids); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	delete(i.synced, name)
+	i.mu.Unlock()
+
+	return nil
+}
+
+// sync then search the resource, the hits only has attributes that the permissions can retrieve.
+func (i *Indexer) Search(ctx context.Context, name string, permissions []string, query string, req *types.SearchRequest) (*types.SearchResult, error) {
+	res, exists := i.resources[name]
+	if !exists || !res.VisibleTo(permissions) {
+		return nil, fmt.Errorf("resource %s is not allowed", name)
+	}
+
+	if err := i.Sync(ctx, name); err != nil {
+		return nil, err
+	}
+
+	allowed := res.AttributesFor(permissions)
+
+	// the requested attributes can only narrow the allowed one, never get wider
+	if len(req.Attributes) > 0 {
+		attrs := make([]string, 0, len(req.Attributes))
+
+		for _, attr := range req.Attributes {
+			if slices.Contains(allowed, attr) {
+				attrs = append(attrs, attr)
+			}
+		}
+
+		allowed = attrs
+	}
+
+	// empty attributes mean all attributes at search backend, so keep the primary key at least
+	if len(allowed) == 0 {
+		allowed = []string{"id"}
+	}
+
+	req.Attributes = allowed
+
+	return i.index.Search(ctx, name, query, req)
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/perpus_backend/helper"
+	"github.com/perpus_backend/types"
+
+	"github.com/bytedance/sonic"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+type MeiliIndex struct {
+	client meilisearch.ServiceManager
+
+	// the settings is kept, so the filter field is checked before it goes into the filter expression
+	mu       sync.RWMutex
+	settings map[string]types.SearchSettings
+}
+
+func NewMeiliIndex(client meilisearch.ServiceManager) *MeiliIndex {
+	return &MeiliIndex{client: client, settings: make(map[string]types.SearchSettings)}
+}
+
+func (m *MeiliIndex) Configure(ctx context.Context, index string, settings *types.SearchSettings) error {
+	err := helper.UpdateSettingsWithWait(m.client, index, &meilisearch.Settings{
+		FilterableAttributes: settings.FilterableAttributes,
+		SortableAttributes:   settings.SortableAttributes,
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.settings[index] = *settings
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MeiliIndex) AddDocuments(ctx context.Context, index, primaryKey string, docs any) error {
+	return helper.AddDocumentsWithWait(m.client, index, primaryKey, docs)
+}
+
+func (m *MeiliIndex) DeleteDocuments(ctx context.Context, index string, ids []string) error {
+	return helper.DeleteDocumentsWithWait(m.client, index, ids)
+}
+
+// page through the documents with only the primary key.
+func (m *MeiliIndex) DocumentIDs(ctx context.Context, index string) ([]string, error) {
+	const limit = 1000
+
+	ids := make([]string, 0)
+
+	for offset := int64(0); ; offset += limit {
+		var res meilisearch.DocumentsResult
+
+		err := m.client.Index(index).GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{Fields: []string{"id"}, Limit: limit, Offset: offset}, &res)
+		if err != nil {
+			var msErr *meilisearch.Error
+			if errors.As(err, &msErr) && msErr.MeilisearchApiError.Code == "index_not_found" {
+				return ids, nil
+			}
+
+			return nil, err
+		}
+
+		for _, hit := range res.Results {
+			var id any
+
+			if err := sonic.Unmarshal(hit["id"], &id); err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, fmt.Sprint(id))
+		}
+
+		if int64(len(res.Results)) < limit {
+			return ids, nil
+		}
+	}
+}
+
+func (m *MeiliIndex) Search(ctx context.Context, index, query string, req *types.SearchRequest) (*types.SearchResult, error) {
+	m.mu.RLock()
+	filterable := m.settings[index].FilterableAttributes
+	m.mu.RUnlock()
+
+	filter, err := buildMeiliFilter(req.Filters, filterable)
+	if err != nil {
+		return nil, err
+	}
+
+	msReq := &meilisearch.SearchRequest{
+		AttributesToRetrieve: req.Attributes,
+		Sort:                 req.Sort,
+		Facets:               req.Facets,
+		Limit:                req.Limit,
+		Offset:               req.Offset,
+	}
+
+	// meili reject empty string filter, so only set it when there is one.
+	if filter != "" {
+		msReq.Filter = filter
+	}
+
+	res, err := m.client.Index(index).SearchWithContext(ctx, query, msReq)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]map[string]any, 0, len(res.Hits))
+
+	for _, hit := range res.Hits {
+		doc := make(map[string]any, len(hit))
+
+		for key, raw := range hit {
+			var v any
+
+			if err := sonic.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+
+			doc[key] = v
+		}
+
+		hits = append(hits, doc)
+	}
+
+	var facets map[string]map[string]int64
+
+	if len(res.FacetDistribution) > 0 {
+		if err := sonic.Unmarshal(res.FacetDistribution, &facets); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.SearchResult{
+		Hits:               hits,
+		FacetDistribution:  facets,
+		Query:              res.Query,
+		Limit:              res.Limit,
+		Offset:             res.Offset,
+		EstimatedTotalHits: res.EstimatedTotalHits,
+		ProcessingTimeMs:   res.ProcessingTimeMs,
+	}, nil
+}
+
+// convert filters into meili filter expression, ex: tahun >= 2000 AND penulis = "Tere Liye".
+// the field is written as it is, so only the filterable attributes of the index is allowed.
+func buildMeiliFilter(filters []types.SearchFilter, filterable []string) (string, error) {
+	exprs := make([]string, 0, len(filters))
+
+	for _, f := range filters {
+		if !isValidFilterOperator(f.Operator) {
+			return "", fmt.Errorf("invalid filter operator: %s", f.Operator)
+		}
+
+		if !slices.Contains(filterable, f.Field) {
+			return "", fmt.Errorf("attribute %s is not filterable", f.Field)
+		}
+
+		var value string
+
+		switch v := f.Value.(type) {
+		case string:
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		case bool, int, int64, float64:
+			value = fmt.Sprint(v)
+		default:
+			return "", fmt.Errorf("invalid filter value for %s", f.Field)
+		}
+
+		exprs = append(exprs, fmt.Sprintf("%s %s %s", f.Field, f.Operator, value))
+	}
+
+	return strings.Join(exprs, " AND "), nil
+}
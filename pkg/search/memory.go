@@ -0,0 +1,484 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/perpus_backend/types"
+)
+
+// in-process inverted index, for local development and CI which has no meilisearch server.
+type MemoryIndex struct {
+	mu      sync.RWMutex
+	indexes map[string]*memoryDocs
+}
+
+type memoryDocs struct {
+	settings types.SearchSettings
+
+	docs     map[string]map[string]any
+	terms    map[string][]string            // doc id -> terms, for remove old postings when replaced
+	postings map[string]map[string]struct{} // term -> doc ids
+	order    []string                       // doc id by insertion order
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{indexes: make(map[string]*memoryDocs)}
+}
+
+func newMemoryDocs() *memoryDocs {
+	return &memoryDocs{
+		docs:     make(map[string]map[string]any),
+		terms:    make(map[string][]string),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+func (m *MemoryIndex) Configure(ctx context.Context, index string, settings *types.SearchSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx, exists := m.indexes[index]
+	if !exists {
+		idx = newMemoryDocs()
+		m.indexes[index] = idx
+	}
+
+	idx.settings = *settings
+
+	return nil
+}
+
+// add or replace documents by primary key, same as meilisearch does.
+func (m *MemoryIndex) AddDocuments(ctx context.Context, index, primaryKey string, docs any) error {
+	records, err := ProjectDocuments(docs, nil)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx, exists := m.indexes[index]
+	if !exists {
+		idx = newMemoryDocs()
+		m.indexes[index] = idx
+	}
+
+	for _, record := range records {
+		pk, ok := record[primaryKey]
+		if !ok || pk == nil {
+			return fmt.Errorf("document has no primary key: %s", primaryKey)
+		}
+
+		id := fmt.Sprint(pk)
+
+		if _, exists := idx.docs[id]; exists {
+			idx.unlink(id)
+		} else {
+			idx.order = append(idx.order, id)
+		}
+
+		terms := make([]string, 0)
+		collectTerms(record, &terms)
+
+		slices.Sort(terms)
+		terms = slices.Compact(terms)
+
+		for _, term := range terms {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[string]struct{})
+			}
+
+			idx.postings[term][id] = struct{}{}
+		}
+
+		idx.docs[id] = record
+		idx.terms[id] = terms
+	}
+
+	return nil
+}
+
+func (m *MemoryIndex) DeleteDocuments(ctx context.Context, index string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx, exists := m.indexes[index]
+	if !exists {
+		return nil
+	}
+
+	for _, id := range ids {
+		if _, exists := idx.docs[id]; !exists {
+			continue
+		}
+
+		idx.unlink(id)
+		delete(idx.docs, id)
+
+		idx.order = slices.DeleteFunc(idx.order, func(o string) bool { return o == id })
+	}
+
+	return nil
+}
+
+func (m *MemoryIndex) DocumentIDs(ctx context.Context, index string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, exists := m.indexes[index]
+	if !exists {
+		return nil, nil
+	}
+
+	return slices.Clone(idx.order), nil
+}
+
+// ranking follow meilisearch default rules: document which match more query words come first,
+// then the less typos, then the sort param, then the exact word is better than the prefix one, and the rest keep the insertion order.
+func (m *MemoryIndex) Search(ctx context.Context, index, query string, req *types.SearchRequest) (*types.SearchResult, error) {
+	start := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, exists := m.indexes[index]
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", index)
+	}
+
+	sorts, err := idx.parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range req.Filters {
+		if !isValidFilterOperator(f.Operator) {
+			return nil, fmt.Errorf("invalid filter operator: %s", f.Operator)
+		}
+
+		if !slices.Contains(idx.settings.FilterableAttributes, f.Field) {
+			return nil, fmt.Errorf("attribute %s is not filterable", f.Field)
+		}
+	}
+
+	for _, facet := range req.Facets {
+		if !slices.Contains(idx.settings.FilterableAttributes, facet) {
+			return nil, fmt.Errorf("attribute %s is not filterable, it can't be used as facet", facet)
+		}
+	}
+
+	res := &types.SearchResult{
+		Hits:   make([]map[string]any, 0),
+		Query:  query,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+
+	words := tokenize(query)
+
+	type scored struct {
+		id      string
+		matched int
+		typos   int
+		exact   int
+		rank    int
+	}
+
+	candidates := make([]scored, 0)
+
+	for rank, id := range idx.order {
+		if !matchFilters(idx.docs[id], req.Filters) {
+			continue
+		}
+
+		s := scored{id: id, rank: rank}
+
+		for _, word := range words {
+			if _, ok := idx.postings[word][id]; ok {
+				s.matched++
+				s.exact++
+				continue
+			}
+
+			// the least typos of all terms in document
+			typos := -1
+
+			for _, term := range idx.terms[id] {
+				if n, _, ok := matchWord(word, term); ok && (typos < 0 || n < typos) {
+					typos = n
+				}
+			}
+
+			if typos >= 0 {
+				s.matched++
+				s.typos += typos
+			}
+		}
+
+		if len(words) > 0 && s.matched == 0 {
+			continue
+		}
+
+		candidates = append(candidates, s)
+	}
+
+	slices.SortStableFunc(candidates, func(a, b scored) int {
+		if a.matched != b.matched {
+			return b.matched - a.matched
+		}
+
+		if a.typos != b.typos {
+			return a.typos - b.typos
+		}
+
+		for _, rule := range sorts {
+			c := compareValues(lookupField(idx.docs[a.id], rule.field), lookupField(idx.docs[b.id], rule.field))
+			if c != 0 {
+				if rule.desc {
+					return -c
+				}
+
+				return c
+			}
+		}
+
+		if a.exact != b.exact {
+			return b.exact - a.exact
+		}
+
+		return a.rank - b.rank
+	})
+
+	if len(req.Facets) > 0 {
+		res.FacetDistribution = make(map[string]map[string]int64, len(req.Facets))
+
+		for _, facet := range req.Facets {
+			res.FacetDistribution[facet] = make(map[string]int64)
+		}
+
+		for _, c := range candidates {
+			for _, facet := range req.Facets {
+				for _, v := range facetValues(lookupField(idx.docs[c.id], facet)) {
+					res.FacetDistribution[facet][v]++
+				}
+			}
+		}
+	}
+
+	res.EstimatedTotalHits = int64(len(candidates))
+
+	for i := req.Offset; i < int64(len(candidates)) && (req.Limit <= 0 || i < req.Offset+req.Limit); i++ {
+		res.Hits = append(res.Hits, Project(idx.docs[candidates[i].id], req.Attributes))
+	}
+
+	res.ProcessingTimeMs = time.Since(start).Milliseconds()
+
+	return res, nil
+}
+
+type sortRule struct {
+	field string
+	desc  bool
+}
+
+// parse sort param "tahun:desc" and check it was sortable attribute.
+func (d *memoryDocs) parseSort(sort []string) ([]sortRule, error) {
+	rules := make([]sortRule, 0, len(sort))
+
+	for _, s := range sort {
+		field, order, ok := strings.Cut(s, ":")
+		if !ok || (order != "asc" && order != "desc") {
+			return nil, fmt.Errorf("invalid sort: %s", s)
+		}
+
+		if !slices.Contains(d.settings.SortableAttributes, field) {
+			return nil, fmt.Errorf("attribute %s is not sortable", field)
+		}
+
+		rules = append(rules, sortRule{field: field, desc: order == "desc"})
+	}
+
+	return rules, nil
+}
+
+// remove the postings of document before it get replaced.
+func (d *memoryDocs) unlink(id string) {
+	for _, term := range d.terms[id] {
+		delete(d.postings[term], id)
+
+		if len(d.postings[term]) == 0 {
+			delete(d.postings, term)
+		}
+	}
+
+	delete(d.terms, id)
+}
+
+// walk all the value in document (nested too, ex: roles[].name or book.judul_buku) and tokenize it.
+func collectTerms(v any, terms *[]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for _, child := range val {
+			collectTerms(child, terms)
+		}
+	case []any:
+		for _, child := range val {
+			collectTerms(child, terms)
+		}
+	case string:
+		*terms = append(*terms, tokenize(val)...)
+	case float64, int64, int, bool:
+		*terms = append(*terms, tokenize(fmt.Sprint(val))...)
+	}
+}
+
+// split text into lowercase words by anything not letter or digit.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// get value from document by attribute name, support nested attribute with dot. ex: book.judul_buku
+func lookupField(doc map[string]any, field string) any {
+	var v any = doc
+
+	for key := range strings.SplitSeq(field, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		v = m[key]
+	}
+
+	return v
+}
+
+// every filter must be match (AND). array value is match when one of the item match, same as meili.
+func matchFilters(doc map[string]any, filters []types.SearchFilter) bool {
+	for _, f := range filters {
+		v := lookupField(doc, f.Field)
+
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+
+		if !slices.ContainsFunc(values, func(item any) bool { return matchFilter(item, f) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchFilter(v any, f types.SearchFilter) bool {
+	if v == nil {
+		return false
+	}
+
+	c := compareValues(v, f.Value)
+
+	// string and bool only can be compared with = and !=
+	_, isNumber := toFloat(v)
+	_, isNumberFilter := toFloat(f.Value)
+
+	switch f.Operator {
+	case "=":
+		return c == 0 && sameKind(v, f.Value)
+	case "!=":
+		return c != 0 || !sameKind(v, f.Value)
+	case ">":
+		return isNumber && isNumberFilter && c > 0
+	case ">=":
+		return isNumber && isNumberFilter && c >= 0
+	case "<":
+		return isNumber && isNumberFilter && c < 0
+	case "<=":
+		return isNumber && isNumberFilter && c <= 0
+	}
+
+	return false
+}
+
+// compare two value from document. number with number, the rest compared as lowercase string.
+func compareValues(a, b any) int {
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+
+	if okA && okB {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	// document which doesn't have the attribute always put at last
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+func sameKind(a, b any) bool {
+	_, numA := toFloat(a)
+	_, numB := toFloat(b)
+
+	_, boolA := a.(bool)
+	_, boolB := b.(bool)
+
+	return numA == numB && boolA == boolB
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+// facet key is always string, same as meili facetDistribution.
+func facetValues(v any) []string {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		values := make([]string, 0, len(val))
+
+		for _, item := range val {
+			values = append(values, facetValues(item)...)
+		}
+
+		return values
+	case map[string]any:
+		return nil
+	default:
+		return []string{fmt.Sprint(val)}
+	}
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"context"
+
+	"github.com/perpus_backend/types"
+)
+
+// all resources that can be searched, keyed by the index name.
+func NewResources(us types.UserStore, rs types.RoleStore, ms types.MemberStore, bs types.BookStore, cs types.CirculationStore) map[string]Resource {
+	return map[string]Resource{
+		"users": {
+			Permission: types.PermUsersAdmin,
+			Indexed:    []string{"id", "name", "email", "avatar", "roles", "created_at"},
+			Docs:       func(ctx context.Context) any { return us.GetUsersForSearch(ctx) },
+		},
+		"roles": {
+			Permission: types.PermRolesAdmin,
+			Indexed:    []string{"id", "name"},
+			Docs: func(ctx context.Context) any {
+				roles, _ := rs.GetRoles(ctx)
+				return roles
+			},
+		},
+		// the documents is redacted, no_telepon is never indexed and the name of minor is only the initials
+		"members": {
+			Permission: types.PermMembersRead,
+			Indexed:    []string{"id", "id_anggota", "nama", "jenis_kelamin", "kelas", "profil_anggota"},
+			Fields: map[string][]string{
+				types.PermMembersRead: {"id", "id_anggota", "nama", "jenis_kelamin", "kelas", "profil_anggota"},
+			},
+			Docs: func(ctx context.Context) any { return ms.GetMembersForSearch(ctx) },
+		},
+		"books": {
+			Permission: types.PermBooksRead,
+			Indexed:    []string{"id", "id_buku", "judul_buku", "cover_buku", "penulis", "pengarang", "kategori", "bahasa", "tahun", "tersedia", "created_at"},
+			Settings: &types.SearchSettings{
+				FilterableAttributes: []string{"tahun", "penulis", "pengarang", "kategori", "bahasa", "tersedia"},
+				SortableAttributes:   []string{"tahun", "judul_buku", "created_at"},
+			},
+			Docs: func(ctx context.Context) any { return bs.GetBooksForSearch(ctx) },
+		},
+		// peminjam isn't indexed, the old circulation which isn't linked still has the name
+		"circulations": {
+			Permission: types.PermCirculationsRead,
+			Indexed:    []string{"id", "id_skl", "buku_id", "member_id", "tanggal_pinjam", "jatuh_tempo", "denda", "book"},
+			Docs:       func(ctx context.Context) any { return cs.GetCirculationsForSearch(ctx) },
+		},
+	}
+}
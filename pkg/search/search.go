@@ -0,0 +1,95 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/bytedance/sonic"
+)
+
+const (
+	DriverMeilisearch = "meilisearch"
+	DriverMemory      = "memory"
+)
+
+// choose search backend from SEARCH_DRIVER. if it was empty, it use meilisearch when MEILISEARCH_URL is set, else memory.
+func NewSearchIndex(driver string) (types.SearchIndex, error) {
+	if driver == "" {
+		driver = DriverMemory
+
+		if config.Env.MeilisearchURL != "" {
+			driver = DriverMeilisearch
+		}
+	}
+
+	switch driver {
+	case DriverMeilisearch:
+		return NewMeiliIndex(utils.MSClient), nil
+	case DriverMemory:
+		return NewMemoryIndex(), nil
+	default:
+		return nil, fmt.Errorf("invalid search driver: %s", driver)
+	}
+}
+
+func isValidFilterOperator(operator string) bool {
+	operatorsMap := map[string]struct{}{
+		"=":  {},
+		"!=": {},
+		">":  {},
+		">=": {},
+		"<":  {},
+		"<=": {},
+	}
+
+	_, exist := operatorsMap[operator]
+	return exist
+}
+
+// keep only the given attributes of document. empty attributes mean keep all.
+func Project(doc map[string]any, attributes []string) map[string]any {
+	if len(attributes) == 0 {
+		return doc
+	}
+
+	projected := make(map[string]any, len(attributes))
+
+	for _, attr := range attributes {
+		if v, ok := doc[attr]; ok {
+			projected[attr] = v
+		}
+	}
+
+	return projected
+}
+
+// convert documents (slice of struct) into maps with only the given attributes,
+// so the sensitive attribute never goes to the search index.
+func ProjectDocuments(docs any, attributes []string) ([]map[string]any, error) {
+	data, err := sonic.Marshal(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]any
+
+	if err := sonic.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	// the store return nil when it failed to load, keep it nil so the caller can tell it from the empty one
+	if records == nil {
+		return nil, nil
+	}
+
+	projected := make([]map[string]any, 0, len(records))
+
+	for _, record := range records {
+		projected = append(projected, Project(record, attributes))
+	}
+
+	return projected, nil
+}
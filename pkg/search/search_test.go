@@ -0,0 +1,409 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/perpus_backend/types"
+)
+
+type testBook struct {
+	ID        string `json:"id"`
+	JudulBuku string `json:"judul_buku"`
+	Penulis   string `json:"penulis"`
+	Kategori  string `json:"kategori"`
+	Tahun     int    `json:"tahun"`
+	Tersedia  bool   `json:"tersedia"`
+}
+
+var testBooks = []testBook{
+	{ID: "1", JudulBuku: "Dasar Pemrograman Go", Penulis: "Budi", Kategori: "teknologi", Tahun: 2020, Tersedia: true},
+	{ID: "2", JudulBuku: "Laskar Pelangi", Penulis: "Andrea Hirata", Kategori: "novel", Tahun: 2005, Tersedia: true},
+	{ID: "3", JudulBuku: "Pemrograman Web Lanjut", Penulis: "Sari", Kategori: "teknologi", Tahun: 2018, Tersedia: false},
+	{ID: "4", JudulBuku: "Bumi", Penulis: "Tere Liye", Kategori: "novel", Tahun: 2014, Tersedia: true},
+}
+
+var testSettings = &types.SearchSettings{
+	FilterableAttributes: []string{"kategori", "tahun", "tersedia"},
+	SortableAttributes:   []string{"tahun", "judul_buku"},
+}
+
+func newTestIndex(t *testing.T) *MemoryIndex {
+	t.Helper()
+
+	ctx := context.Background()
+	m := NewMemoryIndex()
+
+	if err := m.Configure(ctx, "books", testSettings); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.AddDocuments(ctx, "books", "id", testBooks); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func hitIDs(res *types.SearchResult) []string {
+	ids := make([]string, 0, len(res.Hits))
+
+	for _, hit := range res.Hits {
+		ids = append(ids, hit["id"].(string))
+	}
+
+	return ids
+}
+
+func TestMemoryIndex(t *testing.T) {
+	ctx := context.Background()
+	m := newTestIndex(t)
+
+	tests := []struct {
+		name  string
+		query string
+		req   types.SearchRequest
+		want  []string
+	}{
+		{
+			name:  "it should rank the document which match more words first",
+			query: "pemrograman go",
+			want:  []string{"1", "3"},
+		},
+		{
+			name:  "it should match the word with typo",
+			query: "pemrogaman",
+			want:  []string{"1", "3"},
+		},
+		{
+			name:  "it should match the exact word",
+			query: "bumi",
+			want:  []string{"4"},
+		},
+		{
+			name:  "it should not allow typo at short word",
+			query: "bumu",
+			want:  []string{},
+		},
+		{
+			name:  "it should match the prefix of word",
+			query: "lask",
+			want:  []string{"2"},
+		},
+		{
+			name: "it should filter by string and number",
+			req: types.SearchRequest{Filters: []types.SearchFilter{
+				{Field: "kategori", Operator: "=", Value: "teknologi"},
+				{Field: "tahun", Operator: ">=", Value: 2019},
+			}},
+			want: []string{"1"},
+		},
+		{
+			name: "it should filter by bool",
+			req:  types.SearchRequest{Filters: []types.SearchFilter{{Field: "tersedia", Operator: "=", Value: false}}},
+			want: []string{"3"},
+		},
+		{
+			name: "it should sort by attribute",
+			req:  types.SearchRequest{Sort: []string{"tahun:desc"}},
+			want: []string{"1", "3", "4", "2"},
+		},
+		{
+			name: "it should limit and offset the hits by insertion order",
+			req:  types.SearchRequest{Limit: 2, Offset: 1},
+			want: []string{"2", "3"},
+		},
+		{
+			name: "it should limit and offset the hits",
+			req:  types.SearchRequest{Sort: []string{"tahun:asc"}, Limit: 2, Offset: 1},
+			want: []string{"4", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := m.Search(ctx, "books", tt.query, &tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hitIDs(res); !slices.Equal(got, tt.want) {
+				t.Errorf("expected hits %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("it should count all matched documents", func(t *testing.T) {
+		res, err := m.Search(ctx, "books", "pemrograman", &types.SearchRequest{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.EstimatedTotalHits != 2 || len(res.Hits) != 1 {
+			t.Errorf("expected 1 hit of 2, got %d of %d", len(res.Hits), res.EstimatedTotalHits)
+		}
+	})
+
+	t.Run("it should fail at unknown index", func(t *testing.T) {
+		if _, err := m.Search(ctx, "members", "budi", &types.SearchRequest{}); err == nil {
+			t.Error("expected error for unknown index")
+		}
+	})
+
+	t.Run("it should reject the document without primary key", func(t *testing.T) {
+		if err := m.AddDocuments(ctx, "books", "id", []map[string]any{{"judul_buku": "Tanpa ID"}}); err == nil {
+			t.Error("expected error for document without primary key")
+		}
+	})
+
+	t.Run("it should count the facets of the matched documents", func(t *testing.T) {
+		res, err := m.Search(ctx, "books", "", &types.SearchRequest{Facets: []string{"kategori"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := res.FacetDistribution["kategori"]; got["novel"] != 2 || got["teknologi"] != 2 {
+			t.Errorf("expected 2 novel and 2 teknologi, got %v", got)
+		}
+	})
+
+	t.Run("it should only return the requested attributes", func(t *testing.T) {
+		res, err := m.Search(ctx, "books", "bumi", &types.SearchRequest{Attributes: []string{"id", "judul_buku"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, exists := res.Hits[0]["penulis"]; exists || len(res.Hits[0]) != 2 {
+			t.Errorf("expected only id and judul_buku, got %v", res.Hits[0])
+		}
+	})
+
+	for _, req := range []types.SearchRequest{
+		{Filters: []types.SearchFilter{{Field: "penulis", Operator: "=", Value: "Budi"}}},
+		{Filters: []types.SearchFilter{{Field: "tahun", Operator: "~", Value: 2000}}},
+		{Facets: []string{"penulis"}},
+		{Sort: []string{"penulis:asc"}},
+		{Sort: []string{"tahun"}},
+	} {
+		t.Run("it should reject the attribute which isn't in settings", func(t *testing.T) {
+			if _, err := m.Search(ctx, "books", "", &req); err == nil {
+				t.Errorf("expected error for %+v", req)
+			}
+		})
+	}
+
+	t.Run("it should delete the documents", func(t *testing.T) {
+		m := newTestIndex(t)
+
+		if err := m.DeleteDocuments(ctx, "books", []string{"1", "unknown"}); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := m.Search(ctx, "books", "pemrograman", &types.SearchRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := hitIDs(res); !slices.Equal(got, []string{"3"}) {
+			t.Errorf("expected hits [3], got %v", got)
+		}
+
+		ids, err := m.DocumentIDs(ctx, "books")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(ids, []string{"2", "3", "4"}) {
+			t.Errorf("expected ids [2 3 4], got %v", ids)
+		}
+	})
+
+	t.Run("it should replace the document with same primary key", func(t *testing.T) {
+		m := newTestIndex(t)
+
+		if err := m.AddDocuments(ctx, "books", "id", []testBook{{ID: "4", JudulBuku: "Bulan", Kategori: "novel"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		for query, want := range map[string][]string{"bumi": {}, "bulan": {"4"}} {
+			res, err := m.Search(ctx, "books", query, &types.SearchRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hitIDs(res); !slices.Equal(got, want) {
+				t.Errorf("query %q expected hits %v, got %v", query, want, got)
+			}
+		}
+	})
+}
+
+func TestBuildMeiliFilter(t *testing.T) {
+	filterable := []string{"kategori", "tahun", "tersedia"}
+
+	tests := []struct {
+		name    string
+		filters []types.SearchFilter
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "it should join the filters with AND",
+			filters: []types.SearchFilter{{Field: "kategori", Operator: "=", Value: "novel"}, {Field: "tahun", Operator: ">=", Value: 2000}},
+			want:    `kategori = "novel" AND tahun >= 2000`,
+		},
+		{
+			name:    "it should escape the quote of string value",
+			filters: []types.SearchFilter{{Field: "kategori", Operator: "!=", Value: `a" OR tersedia = true OR kategori = "b\`}},
+			want:    `kategori != "a\" OR tersedia = true OR kategori = \"b\\"`,
+		},
+		{
+			name:    "it should write bool value",
+			filters: []types.SearchFilter{{Field: "tersedia", Operator: "=", Value: true}},
+			want:    `tersedia = true`,
+		},
+		{
+			name: "it should be empty without filters",
+			want: "",
+		},
+		{
+			name:    "it should reject the field which isn't filterable",
+			filters: []types.SearchFilter{{Field: "penulis", Operator: "=", Value: "Budi"}},
+			wantErr: true,
+		},
+		{
+			name:    "it should reject the field which inject expression",
+			filters: []types.SearchFilter{{Field: "tahun > 0 OR kategori", Operator: "=", Value: "novel"}},
+			wantErr: true,
+		},
+		{
+			name:    "it should reject the invalid operator",
+			filters: []types.SearchFilter{{Field: "tahun", Operator: "IN", Value: 2000}},
+			wantErr: true,
+		},
+		{
+			name:    "it should reject the invalid value",
+			filters: []types.SearchFilter{{Field: "tahun", Operator: "=", Value: []any{2000}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildMeiliFilter(tt.filters, filterable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected filter %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIndexerSync(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+
+	books := slices.Clone(testBooks)
+
+	resources := map[string]Resource{
+		"books": {
+			Permission: types.PermBooksRead,
+			Indexed:    []string{"id", "judul_buku", "kategori", "tahun"},
+			Settings:   testSettings,
+			Docs:       func(ctx context.Context) any { return books },
+		},
+	}
+
+	// ttl 0 mean every search sync from the store
+	indexer := NewIndexer(index, resources, 0)
+
+	if err := indexer.Configure(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should index only the indexed attributes", func(t *testing.T) {
+		res, err := indexer.Search(ctx, "books", []string{types.PermBooksRead}, "hirata", &types.SearchRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Hits) != 0 {
+			t.Errorf("expected penulis isn't indexed, got %v", res.Hits)
+		}
+	})
+
+	t.Run("it should delete the documents which is deleted at store", func(t *testing.T) {
+		books = slices.DeleteFunc(books, func(b testBook) bool { return b.ID == "4" })
+
+		if err := indexer.Sync(ctx, "books"); err != nil {
+			t.Fatal(err)
+		}
+
+		ids, err := index.DocumentIDs(ctx, "books")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(ids, []string{"1", "2", "3"}) {
+			t.Errorf("expected ids [1 2 3], got %v", ids)
+		}
+	})
+
+	t.Run("it should keep the documents when the store failed to load", func(t *testing.T) {
+		books = nil
+
+		if err := indexer.Sync(ctx, "books"); err != nil {
+			t.Fatal(err)
+		}
+
+		ids, err := index.DocumentIDs(ctx, "books")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ids) != 3 {
+			t.Errorf("expected 3 documents kept, got %v", ids)
+		}
+	})
+
+	t.Run("it should not allow the caller without the read permission", func(t *testing.T) {
+		if _, err := indexer.Search(ctx, "books", []string{types.PermSearch}, "", &types.SearchRequest{}); err == nil {
+			t.Error("expected error without books:read")
+		}
+	})
+}
+
+func TestResourceAttributesFor(t *testing.T) {
+	res := Resource{
+		Permission: types.PermMembersRead,
+		Indexed:    []string{"id", "nama", "kelas", "profil_anggota"},
+		Fields: map[string][]string{
+			types.PermMembersRead:  {"id", "nama"},
+			types.PermMembersWrite: {"id", "kelas"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		resource    Resource
+		permissions []string
+		want        []string
+	}{
+		{name: "it should get the fields of the read permission", resource: res, permissions: []string{types.PermSearch, types.PermMembersRead}, want: []string{"id", "nama"}},
+		{name: "it should get the union of the fields", resource: res, permissions: []string{types.PermMembersRead, types.PermMembersWrite}, want: []string{"id", "nama", "kelas"}},
+		{name: "it should get nothing without the read permission", resource: res, permissions: []string{types.PermMembersWrite}, want: []string{}},
+		{name: "it should get all indexed attributes when the read permission isn't listed", resource: Resource{Permission: types.PermMembersRead, Indexed: res.Indexed}, permissions: []string{types.PermMembersRead}, want: res.Indexed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resource.AttributesFor(tt.permissions); !slices.Equal(got, tt.want) {
+				t.Errorf("expected attributes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
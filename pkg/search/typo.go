@@ -0,0 +1,101 @@
+package search
+
+import "unicode/utf8"
+
+// number of typos allowed for a query word, same as meilisearch default:
+// word less than 5 chars must be exact, less than 9 chars allow 1 typo, and the longer one allow 2 typos.
+func allowedTypos(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 5:
+		return 0
+	case n < 9:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// match the query word against one term of document. the result is the count of typos, exact is true
+// only when the whole term is equal. prefix of term is matched too, with or without the typo.
+func matchWord(word, term string) (typos int, exact, ok bool) {
+	if word == term {
+		return 0, true, true
+	}
+
+	w, t := []rune(word), []rune(term)
+
+	if len(t) >= len(w) && string(t[:len(w)]) == word {
+		return 0, false, true
+	}
+
+	max := allowedTypos(word)
+	if max == 0 {
+		return 0, false, false
+	}
+
+	best := levenshtein(w, t)
+
+	// prefix with typo, ex: "pemrogaman" still match "pemrograman"
+	if len(t) > len(w) {
+		best = min(best, levenshtein(w, t[:len(w)]))
+	}
+
+	if best > max {
+		return 0, false, false
+	}
+
+	return best, false, true
+}
+
+// check every word of the query match one of the words in text, with typo tolerance.
+func Matches(query, text string) bool {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return false
+	}
+
+	terms := tokenize(text)
+
+	for _, word := range words {
+		found := false
+
+		for _, term := range terms {
+			if _, _, ok := matchWord(word, term); ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
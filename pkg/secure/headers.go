@@ -0,0 +1,32 @@
+package secure
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/perpus_backend/config"
+)
+
+// security headers at every response. the api only return json and files, so the CSP is strict by default.
+func HeadersMiddleware(next http.Handler) http.Handler {
+	hsts := ""
+	if config.Env.AppENV == "production" && config.Env.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(config.Env.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+
+		h.Set("Content-Security-Policy", config.Env.ContentSecurityPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", config.Env.ReferrerPolicy)
+
+		// only over https, the browser ignore it at http
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package secure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/config"
+)
+
+func TestHeadersMiddleware(t *testing.T) {
+	appENV, hstsMaxAge := config.Env.AppENV, config.Env.HSTSMaxAge
+	defer func() {
+		config.Env.AppENV, config.Env.HSTSMaxAge = appENV, hstsMaxAge
+	}()
+
+	config.Env.HSTSMaxAge = 24 * time.Hour
+
+	tests := []struct {
+		name string
+		env  string
+		hsts string
+	}{
+		{name: "it should set hsts at production", env: "production", hsts: "max-age=86400; includeSubDomains"},
+		{name: "it should not set hsts at debug", env: "debug", hsts: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Env.AppENV = tt.env
+
+			handler := HeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/books", nil))
+
+			want := map[string]string{
+				"Content-Security-Policy":   config.Env.ContentSecurityPolicy,
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           config.Env.ReferrerPolicy,
+				"Strict-Transport-Security": tt.hsts,
+			}
+
+			for header, value := range want {
+				if got := w.Header().Get(header); got != value {
+					t.Errorf("expected %s %q, got %q", header, value, got)
+				}
+			}
+		})
+	}
+}
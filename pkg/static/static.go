@@ -0,0 +1,60 @@
+package static
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/utils"
+)
+
+var errFileNotFound = errors.New("file not found")
+
+// serve the files of dir without directory listing. the response has Cache-Control and ETag,
+// so the browser revalidate the cover and avatar with If-None-Match and get 304.
+func FileServer(dir string, maxAge time.Duration) http.Handler {
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+
+		// hidden file, ex: .gitkeep
+		for segment := range strings.SplitSeq(name, "/") {
+			if strings.HasPrefix(segment, ".") {
+				utils.WriteJSONError(w, http.StatusNotFound, errFileNotFound)
+				return
+			}
+		}
+
+		ServeFile(w, r, dir+name, cacheControl)
+	})
+}
+
+// serve one file with the cache headers, the directory is not found.
+func ServeFile(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	f, err := os.Open(name)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, errFileNotFound)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		utils.WriteJSONError(w, http.StatusNotFound, errFileNotFound)
+		return
+	}
+
+	// the uploaded file is never changed (the new upload has new name), so the size and mod time is enough
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// it handle If-None-Match, If-Modified-Since, and Range
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,86 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileServer(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"covers/buku.png":     "png",
+		"covers/.gitkeep":     "",
+		".hidden/avatar.png":  "png",
+		"avatars/profile.png": "png",
+	} {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := FileServer(dir, time.Hour)
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "it should serve the file", path: "/covers/buku.png", want: http.StatusOK},
+		{name: "it should not list the directory", path: "/covers/", want: http.StatusNotFound},
+		{name: "it should not list the root directory", path: "/", want: http.StatusNotFound},
+		{name: "it should hide the dot file", path: "/covers/.gitkeep", want: http.StatusNotFound},
+		{name: "it should hide the file at dot directory", path: "/.hidden/avatar.png", want: http.StatusNotFound},
+		{name: "it should not go outside the directory", path: "/../covers/buku.png", want: http.StatusOK},
+		{name: "it should not find the missing file", path: "/covers/missing.png", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	t.Run("it should set the cache headers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/covers/buku.png", nil))
+
+		if w.Header().Get("ETag") == "" {
+			t.Error("expected ETag header")
+		}
+
+		if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+			t.Errorf("expected Cache-Control %q, got %q", "public, max-age=3600", got)
+		}
+	})
+
+	t.Run("it should return 304 for the same etag", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/covers/buku.png", nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/covers/buku.png", nil)
+		req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+
+		revalidated := httptest.NewRecorder()
+		handler.ServeHTTP(revalidated, req)
+
+		if revalidated.Code != http.StatusNotModified {
+			t.Errorf("expected status code %d, got %d", http.StatusNotModified, revalidated.Code)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/types"
+)
+
+const recoveryCodeCount = 10
+
+var ErrInvalidCode = errors.New("invalid two factor code")
+
+// verify the totp code of enabled 2fa, or the recovery code when it isn't 6 digits.
+func Verify(ctx context.Context, store types.TwoFactorStore, userID, code string) error {
+	secret, enabled, err := store.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return errors.New("two factor authentication is not enabled")
+	}
+
+	code = strings.TrimSpace(code)
+
+	if len(code) != digits {
+		if err := store.UseRecoveryCode(ctx, userID, HashRecoveryCode(code)); err != nil {
+			return ErrInvalidCode
+		}
+
+		return nil
+	}
+
+	return VerifySecret(ctx, store, userID, secret, code)
+}
+
+// verify the code against the secret, the code which was used can't be used again.
+func VerifySecret(ctx context.Context, store types.TwoFactorStore, userID, secret, code string) error {
+	step, ok := Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	fresh, err := store.MarkTOTPStepUsed(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if !fresh {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+// new recovery codes, format xxxxx-xxxxx. it return the plain codes (shown once to user) and the hashes.
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 7)
+
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(b32.EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// the recovery code is case insensitive and the dash is optional.
+func HashRecoveryCode(code string) string {
+	return hash.HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
@@ -0,0 +1,352 @@
+package upload
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// the decompressed stream is limited, so the zip bomb can't fill the memory
+	maxStreamSize = 16 << 20
+	maxTotalSize  = 64 << 20
+
+	// how far the stream dictionary is searched before the "stream" keyword
+	maxDictSize = 4 << 10
+)
+
+var (
+	// the name of javascript action (/S /JavaScript) and the script (/JS)
+	forbiddenNames = map[string]bool{"JavaScript": true, "JS": true}
+
+	startXrefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF`)
+	objPattern       = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\b`)
+	streamPattern    = regexp.MustCompile(`stream\r?\n`)
+
+	// the filter is a name or an array of names, ex: /Filter /FlateDecode, /Filter [/ASCII85Decode /LZWDecode]
+	filterKeyPattern   = regexp.MustCompile(`/Filter(?:[\s/\[\]<>()%]|$)`)
+	filterPattern      = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/[^\s/\[\]<>()]+)`)
+	namePattern        = regexp.MustCompile(`/([^\s/\[\]<>()]+)`)
+	earlyChangePattern = regexp.MustCompile(`/EarlyChange\s+([01])`)
+)
+
+// the pdf must have the header, the cross reference, and the trailer. the javascript is searched at
+// the raw bytes and at the decoded streams, because the object stream can hide the action dictionary.
+func validatePDF(data []byte) error {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return errors.New("invalid pdf: missing header")
+	}
+
+	// the last %%EOF, the file can be updated incrementally
+	tail := data[max(0, len(data)-1024):]
+
+	m := startXrefPattern.FindAllSubmatch(tail, -1)
+	if len(m) == 0 {
+		return errors.New("invalid pdf: missing startxref")
+	}
+
+	offset, err := strconv.Atoi(string(m[len(m)-1][1]))
+	if err != nil || offset <= 0 || offset >= len(data) {
+		return errors.New("invalid pdf: invalid startxref")
+	}
+
+	// it points into the xref table or the xref stream object
+	if !bytes.HasPrefix(bytes.TrimLeft(data[offset:], " \t\r\n"), []byte("xref")) && !objPattern.Match(data[offset:]) {
+		return errors.New("invalid pdf: invalid cross reference")
+	}
+
+	if !bytes.Contains(data, []byte("/Root")) {
+		return errors.New("invalid pdf: missing catalog")
+	}
+
+	if name := findForbiddenName(data); name != "" {
+		return fmt.Errorf("pdf contains javascript (/%s)", name)
+	}
+
+	total, next := 0, 0
+
+	for _, loc := range streamPattern.FindAllIndex(data, -1) {
+		// the endstream keyword, or the bytes inside the previous stream
+		if loc[0] < next || bytes.HasSuffix(data[:loc[0]], []byte("end")) {
+			continue
+		}
+
+		start := loc[1]
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			return errors.New("invalid pdf: unterminated stream")
+		}
+
+		next = start + end
+
+		dict := streamDict(data, loc[0])
+
+		content, err := decodeStream(data[start:start+end], dict, maxTotalSize-total)
+		if err != nil {
+			return fmt.Errorf("invalid pdf: %v", err)
+		}
+
+		if total += len(content); total >= maxTotalSize {
+			return errors.New("invalid pdf: the streams are too big")
+		}
+
+		if name := findForbiddenName(content); name != "" {
+			return fmt.Errorf("pdf contains javascript (/%s)", name)
+		}
+	}
+
+	return nil
+}
+
+// the dictionary of the stream, it's between the "obj" keyword and the "stream" keyword.
+func streamDict(data []byte, streamStart int) []byte {
+	from := max(0, streamStart-maxDictSize)
+
+	if i := bytes.LastIndex(data[from:streamStart], []byte("obj")); i >= 0 {
+		return data[from+i : streamStart]
+	}
+
+	return data[from:streamStart]
+}
+
+// decode the stream by the filters of its dictionary, so the javascript can't be hidden by the other encoding.
+// it stops at the image codec, and the stream with unknown filter is rejected because it can't be checked.
+func decodeStream(data, dict []byte, limit int) ([]byte, error) {
+	m := filterPattern.FindSubmatch(dict)
+	if m == nil {
+		// the indirect filter (ex: /Filter 12 0 R) can't be resolved here, so it can't be checked
+		if filterKeyPattern.Match(dict) {
+			return nil, errors.New("stream filter must be a name or an array of names")
+		}
+
+		return nil, nil // not encoded, it's checked at the raw bytes
+	}
+
+	// the array can only have the names, ex: [/FlateDecode 12 0 R] is rejected too
+	if rest := namePattern.ReplaceAll(m[1], nil); len(bytes.Trim(rest, "[] \t\r\n\f\x00")) > 0 {
+		return nil, errors.New("stream filter must be a name or an array of names")
+	}
+
+	earlyChange := 1
+	if ec := earlyChangePattern.FindSubmatch(dict); ec != nil {
+		earlyChange, _ = strconv.Atoi(string(ec[1]))
+	}
+
+	limit = min(limit, maxStreamSize)
+
+	for _, name := range namePattern.FindAllSubmatch(m[1], -1) {
+		var err error
+
+		switch string(name[1]) {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data, limit)
+		case "LZWDecode", "LZW":
+			data, err = lzwDecode(data, earlyChange, limit)
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data, limit)
+		case "RunLengthDecode", "RL":
+			data, err = runLengthDecode(data, limit)
+		case "DCTDecode", "DCT", "JPXDecode", "CCITTFaxDecode", "CCF", "JBIG2Decode":
+			return nil, nil // the image data, it can't have the pdf objects
+		default:
+			return nil, fmt.Errorf("unsupported stream filter /%s", name[1])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("can't decode /%s stream: %v", name[1], err)
+		}
+	}
+
+	return data, nil
+}
+
+func inflate(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	content, err := io.ReadAll(io.LimitReader(r, int64(min(limit, maxStreamSize))+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// the pdf variant of lzw: 9-12 bit codes, 256 is clear and 257 is the end. the code width is increased
+// one code early when EarlyChange is 1 (the default).
+func lzwDecode(data []byte, earlyChange, limit int) ([]byte, error) {
+	table := make([][]byte, 258, 4096)
+	for i := range 256 {
+		table[i] = []byte{byte(i)}
+	}
+
+	width := 9
+
+	var (
+		out, prev []byte
+
+		acc   uint32
+		nbits int
+	)
+
+	for _, b := range data {
+		acc = acc<<8 | uint32(b)
+		nbits += 8
+
+		for nbits >= width {
+			code := int(acc>>(nbits-width)) & (1<<width - 1)
+			nbits -= width
+
+			switch code {
+			case 256:
+				table, width, prev = table[:258], 9, nil
+				continue
+			case 257:
+				return out, nil
+			}
+
+			var entry []byte
+
+			switch {
+			case code < len(table):
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(bytes.Clone(prev), prev[0])
+			default:
+				return nil, fmt.Errorf("invalid lzw code %d", code)
+			}
+
+			if out = append(out, entry...); len(out) > limit {
+				return out, nil
+			}
+
+			if prev != nil && len(table) < cap(table) {
+				table = append(table, append(bytes.Clone(prev), entry[0]))
+			}
+
+			prev = entry
+
+			if len(table)+earlyChange >= 1<<width && width < 12 {
+				width++
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// two hex digits per byte, the whitespace is ignored and ">" is the end.
+func asciiHexDecode(data []byte) ([]byte, error) {
+	if end := bytes.IndexByte(data, '>'); end >= 0 {
+		data = data[:end]
+	}
+
+	digits := bytes.Join(bytes.Fields(data), nil)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0') // the missing last digit is 0
+	}
+
+	out := make([]byte, hex.DecodedLen(len(digits)))
+
+	if _, err := hex.Decode(out, digits); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// "~>" is the end of the data.
+func ascii85Decode(data []byte, limit int) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+
+	if end := bytes.Index(data, []byte("~>")); end >= 0 {
+		data = data[:end]
+	}
+
+	return io.ReadAll(io.LimitReader(ascii85.NewDecoder(bytes.NewReader(data)), int64(limit)+1))
+}
+
+// the length byte 0-127 copy the next length+1 bytes, 129-255 repeat the next byte 257-length times, and 128 is the end.
+func runLengthDecode(data []byte, limit int) ([]byte, error) {
+	var out []byte
+
+	for i := 0; i < len(data) && len(out) <= limit; {
+		length := int(data[i])
+
+		switch {
+		case length == 128:
+			return out, nil
+		case length < 128:
+			if i+1+length+1 > len(data) {
+				return nil, errors.New("truncated run length data")
+			}
+
+			out = append(out, data[i+1:i+2+length]...)
+			i += 2 + length
+		default:
+			if i+1 >= len(data) {
+				return nil, errors.New("truncated run length data")
+			}
+
+			out = append(out, bytes.Repeat(data[i+1:i+2], 257-length)...)
+			i += 2
+		}
+	}
+
+	return out, nil
+}
+
+// find the pdf name token, the "#xx" escape is decoded, ex: /J#61vaScript is /JavaScript.
+func findForbiddenName(data []byte) string {
+	for i := 0; i < len(data); i++ {
+		if data[i] != '/' {
+			continue
+		}
+
+		var name []byte
+
+		j := i + 1
+		for ; j < len(data) && isRegular(data[j]); j++ {
+			if data[j] == '#' && j+2 < len(data) {
+				if b, err := strconv.ParseUint(string(data[j+1:j+3]), 16, 8); err == nil {
+					name = append(name, byte(b))
+					j += 2
+					continue
+				}
+			}
+
+			name = append(name, data[j])
+		}
+
+		if forbiddenNames[string(name)] {
+			return string(name)
+		}
+
+		i = j - 1
+	}
+
+	return ""
+}
+
+// not the whitespace or the delimiter of pdf syntax.
+func isRegular(b byte) bool {
+	switch b {
+	case 0, '\t', '\n', '\f', '\r', ' ', '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,298 @@
+package upload
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	javascript = "<< /S /JavaScript /JS (app.alert(1)) >>"
+	content    = "BT /F1 12 Tf 72 712 Td (Halo) Tj ET"
+)
+
+// minimal pdf with the catalog, the objects are put between the catalog and the xref.
+func makePDF(objects ...string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+3, obj)
+	}
+
+	xref := b.Len()
+
+	fmt.Fprintf(&b, "xref\n0 1\n0000000000 65535 f \ntrailer\n<< /Size 1 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	return b.Bytes()
+}
+
+func streamObj(filter string, data []byte) string {
+	return fmt.Sprintf("<< /Length %d /Filter %s >>\nstream\n%s\nendstream", len(data), filter, data)
+}
+
+func flate(data string) []byte {
+	var b bytes.Buffer
+
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(data))
+	w.Close()
+
+	return b.Bytes()
+}
+
+func lzwStd(data string) []byte {
+	var b bytes.Buffer
+
+	w := lzw.NewWriter(&b, lzw.MSB, 8)
+	w.Write([]byte(data))
+	w.Close()
+
+	return b.Bytes()
+}
+
+// the pdf lzw encoder, the code width is increased in the same code as the decoder.
+func lzwEncode(data []byte, earlyChange int) []byte {
+	dict := make(map[string]int)
+	for i := range 256 {
+		dict[string(rune(i))] = i
+	}
+
+	var (
+		out   []byte
+		acc   uint64
+		nbits int
+	)
+
+	width, size, next, first := 9, 258, 258, true
+
+	write := func(code int) {
+		acc = acc<<width | uint64(code)
+		nbits += width
+
+		for nbits >= 8 {
+			out = append(out, byte(acc>>(nbits-8)))
+			nbits -= 8
+		}
+	}
+
+	emit := func(code int) {
+		write(code)
+
+		if !first {
+			size++
+		}
+
+		first = false
+
+		if size+earlyChange >= 1<<width && width < 12 {
+			width++
+		}
+	}
+
+	write(256)
+
+	w := ""
+
+	for _, c := range data {
+		wc := w + string(rune(c))
+		if _, ok := dict[wc]; ok {
+			w = wc
+			continue
+		}
+
+		emit(dict[w])
+
+		dict[wc] = next
+		next++
+
+		w = string(rune(c))
+	}
+
+	if w != "" {
+		emit(dict[w])
+	}
+
+	write(257)
+
+	if nbits > 0 {
+		out = append(out, byte(acc<<(8-nbits)))
+	}
+
+	return out
+}
+
+func runLength(data string) []byte {
+	var out []byte
+
+	for len(data) > 0 {
+		n := min(len(data), 128)
+		out = append(out, byte(n-1))
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+
+	return append(out, 128)
+}
+
+func ascii85Encode(data string) []byte {
+	out := make([]byte, ascii85.MaxEncodedLen(len(data)))
+	n := ascii85.Encode(out, []byte(data))
+
+	return append(out[:n], "~>"...)
+}
+
+func TestValidatePDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name: "it should accept the pdf with flate content",
+			data: makePDF(streamObj("/FlateDecode", flate(content))),
+		},
+		{
+			name: "it should accept the image stream",
+			data: makePDF(streamObj("/DCTDecode", []byte("\xff\xd8\xff\xe0"))),
+		},
+		{
+			name: "it should accept the image stream with flate before the codec",
+			data: makePDF(streamObj("[/FlateDecode /DCTDecode]", flate("\xff\xd8\xff\xe0"))),
+		},
+		{
+			name:    "it should reject the raw javascript",
+			data:    makePDF(javascript),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at flate stream",
+			data:    makePDF(streamObj("/FlateDecode", flate(javascript))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at ascii hex stream",
+			data:    makePDF(streamObj("/ASCIIHexDecode", []byte(hex.EncodeToString([]byte(javascript))+">"))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at ascii85 stream",
+			data:    makePDF(streamObj("/A85", ascii85Encode(javascript))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at lzw stream",
+			data:    makePDF(streamObj("/LZWDecode", lzwStd(javascript))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at run length stream",
+			data:    makePDF(streamObj("/RunLengthDecode", runLength(javascript))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the javascript at chained filters",
+			data:    makePDF(streamObj("[/ASCIIHexDecode /FlateDecode]", []byte(hex.EncodeToString(flate(javascript))+">"))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the escaped name at encoded stream",
+			data:    makePDF(streamObj("/AHx", []byte(hex.EncodeToString([]byte("<< /S /J#61vaScript >>"))))),
+			wantErr: "javascript",
+		},
+		{
+			name:    "it should reject the unknown filter",
+			data:    makePDF(streamObj("/Crypt", []byte(content))),
+			wantErr: "unsupported stream filter /Crypt",
+		},
+		{
+			name:    "it should reject the indirect filter",
+			data:    makePDF(streamObj("12 0 R", flate(javascript))),
+			wantErr: "stream filter must be a name or an array of names",
+		},
+		{
+			name:    "it should reject the indirect filter at the array",
+			data:    makePDF(streamObj("[/FlateDecode 12 0 R]", flate(javascript))),
+			wantErr: "stream filter must be a name or an array of names",
+		},
+		{
+			name:    "it should reject the stream which can't be decoded",
+			data:    makePDF(streamObj("/FlateDecode", []byte("not flate"))),
+			wantErr: "can't decode /FlateDecode",
+		},
+		{
+			name:    "it should reject the file without header",
+			data:    bytes.TrimPrefix(makePDF(), []byte("%PDF-")),
+			wantErr: "missing header",
+		},
+		{
+			name:    "it should reject the file without startxref",
+			data:    []byte("%PDF-1.7\n1 0 obj\n<< /Root 1 0 R >>\nendobj\n%%EOF\n"),
+			wantErr: "missing startxref",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePDF(tt.data)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLZWDecode(t *testing.T) {
+	// long enough to increase the code width up to 12 bit
+	var b strings.Builder
+	for i := range 3000 {
+		fmt.Fprintf(&b, "%d ", i*7919%1000)
+	}
+
+	data := b.String()
+
+	tests := []struct {
+		name        string
+		encoded     []byte
+		earlyChange int
+	}{
+		{name: "it should decode the early change code width", encoded: lzwEncode([]byte(data), 1), earlyChange: 1},
+		{name: "it should decode the late change code width", encoded: lzwEncode([]byte(data), 0), earlyChange: 0},
+		{name: "it should decode the go lzw writer as late change", encoded: lzwStd(data), earlyChange: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lzwDecode(tt.encoded, tt.earlyChange, maxStreamSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != data {
+				t.Errorf("expected %d decoded bytes, got %d", len(data), len(got))
+			}
+		})
+	}
+
+	t.Run("it should reject the invalid code", func(t *testing.T) {
+		// the clear code, then code 300 before the table has it
+		if _, err := lzwDecode([]byte{0x80, 0x4b, 0x00}, 1, maxStreamSize); err == nil {
+			t.Error("expected error for invalid code")
+		}
+	})
+}
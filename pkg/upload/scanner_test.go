@@ -0,0 +1,220 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/config"
+)
+
+// clamd stub which speak zINSTREAM, it answer the reply after the whole stream is received.
+func startClamd(t *testing.T, reply string) (string, <-chan []byte) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+			return
+		}
+
+		var stream bytes.Buffer
+
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+
+			if size == 0 {
+				break
+			}
+
+			if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+				return
+			}
+		}
+
+		received <- stream.Bytes()
+
+		conn.Write([]byte(reply + "\x00"))
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestClamAV(t *testing.T) {
+	// bigger than one chunk, so it's sent at many chunks
+	data := bytes.Repeat([]byte("perpus"), chunkSize/3)
+
+	tests := []struct {
+		name     string
+		reply    string
+		infected bool
+		wantErr  bool
+	}{
+		{name: "it should pass the clean file", reply: "stream: OK"},
+		{name: "it should return infected with the signature", reply: "stream: Eicar-Test-Signature FOUND", infected: true, wantErr: true},
+		{name: "it should return error at clamd error", reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, received := startClamd(t, tt.reply)
+
+			err := NewClamAV("tcp://"+addr, 5*time.Second).Scan(context.Background(), bytes.NewReader(data))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if errors.Is(err, ErrInfected) != tt.infected {
+				t.Errorf("expected infected %v, got %v", tt.infected, err)
+			}
+
+			if got := <-received; !bytes.Equal(got, data) {
+				t.Errorf("expected clamd receive %d bytes, got %d", len(data), len(got))
+			}
+		})
+	}
+
+	t.Run("it should return error when clamd is down", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := ln.Addr().String()
+		ln.Close()
+
+		if err := NewClamAV(addr, time.Second).Scan(context.Background(), bytes.NewReader(data)); err == nil {
+			t.Error("expected error when clamd is down")
+		}
+	})
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply    string
+		infected bool
+		wantErr  bool
+	}{
+		{reply: "stream: OK"},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", infected: true, wantErr: true},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{reply: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("it should parse "+tt.reply, func(t *testing.T) {
+			err := parseReply(tt.reply)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if errors.Is(err, ErrInfected) != tt.infected {
+				t.Errorf("expected infected %v, got %v", tt.infected, err)
+			}
+		})
+	}
+}
+
+type fakeScanner struct {
+	err error
+}
+
+func (s fakeScanner) Scan(ctx context.Context, r io.Reader) error {
+	return s.err
+}
+
+type testFile struct {
+	*bytes.Reader
+}
+
+func (f testFile) Close() error {
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	quarantineDir := config.Env.QuarantineDir
+	config.Env.QuarantineDir = t.TempDir()
+
+	defer func() {
+		config.Env.QuarantineDir = quarantineDir
+		SetScanner(nil)
+	}()
+
+	pdf := makePDF(streamObj("/FlateDecode", flate(content)))
+
+	tests := []struct {
+		name        string
+		scanErr     error
+		wantErr     error
+		status      int
+		quarantined bool
+	}{
+		{name: "it should accept the clean file", scanErr: nil},
+		{name: "it should reject and quarantine the infected file", scanErr: ErrInfected, wantErr: ErrRejected, status: http.StatusUnprocessableEntity, quarantined: true},
+		{name: "it should fail with 503 when the scanner is down", scanErr: errors.New("connection refused"), wantErr: ErrScanFailed, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetScanner(fakeScanner{err: tt.scanErr})
+
+			before, _ := os.ReadDir(config.Env.QuarantineDir)
+
+			header := &multipart.FileHeader{Filename: "buku.pdf", Size: int64(len(pdf))}
+
+			f, err := Check(context.Background(), testFile{bytes.NewReader(pdf)}, header, PDF, 10<<20)
+
+			if tt.wantErr == nil {
+				if err != nil || f.Ext != ".pdf" {
+					t.Fatalf("expected the pdf is accepted, got %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got := StatusCode(err); got != tt.status {
+				t.Errorf("expected status code %d, got %d", tt.status, got)
+			}
+
+			after, _ := os.ReadDir(config.Env.QuarantineDir)
+			if quarantined := len(after) > len(before); quarantined != tt.quarantined {
+				t.Errorf("expected quarantined %v, got %v", tt.quarantined, quarantined)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the decoder
+	_ "image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/requestid"
+
+	"github.com/rs/xid"
+)
+
+type Kind int
+
+const (
+	Image Kind = iota // png or jpeg
+	PDF
+
+	// bigger image is rejected before it's decoded, the cover and avatar is never that big
+	maxImagePixels = 40_000_000
+)
+
+var (
+	// the content of the file is invalid or infected, the file is quarantined.
+	ErrRejected = errors.New("the file is rejected")
+
+	// the scanner can't be reached, the file isn't saved.
+	ErrScanFailed = errors.New("the file can't be scanned, try again later")
+
+	// set when the server start. it's nil at the test, so the file isn't scanned.
+	scanner Scanner
+)
+
+func SetScanner(s Scanner) {
+	scanner = s
+}
+
+// the checked file, it's saved with the extension from the content, not from the client file name.
+type File struct {
+	Data []byte
+
+	Ext, ContentType string
+}
+
+// check the uploaded file by the size, the magic bytes and the structure, then scan it.
+// the rejected file is moved into quarantine dir, and it's never served.
+func Check(ctx context.Context, file multipart.File, header *multipart.FileHeader, kind Kind, maxSize int64) (*File, error) {
+	if header.Size > maxSize {
+		return nil, fmt.Errorf("%w: only serve file under %dmb", ErrRejected, maxSize>>20)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Data: data, ContentType: http.DetectContentType(data)}
+
+	if err := validate(f, kind); err != nil {
+		quarantine(ctx, header.Filename, data, err)
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	if scanner == nil {
+		return f, nil
+	}
+
+	if err := scanner.Scan(ctx, bytes.NewReader(data)); err != nil {
+		if errors.Is(err, ErrInfected) {
+			quarantine(ctx, header.Filename, data, err)
+			return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+		}
+
+		log.Printf("scan %s: %v", header.Filename, err)
+
+		return nil, ErrScanFailed
+	}
+
+	return f, nil
+}
+
+// the status code of Check error, 422 for the rejected file and 503 when the scanner is down.
+func StatusCode(err error) int {
+	if errors.Is(err, ErrScanFailed) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusUnprocessableEntity
+}
+
+// write the file, the dir is created when it doesn't exist.
+func (f *File) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, f.Data, 0o644)
+}
+
+func validate(f *File, kind Kind) error {
+	switch kind {
+	case Image:
+		switch f.ContentType {
+		case "image/png":
+			f.Ext = ".png"
+		case "image/jpeg":
+			f.Ext = ".jpg"
+		default:
+			return errors.New("only support jpg, jpeg, and png")
+		}
+
+		return validateImage(f.Data)
+	case PDF:
+		if f.ContentType != "application/pdf" {
+			return errors.New("convert to pdf first")
+		}
+
+		f.Ext = ".pdf"
+
+		return validatePDF(f.Data)
+	}
+
+	return errors.New("unknown file kind")
+}
+
+func validateImage(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid image: %v", err)
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return fmt.Errorf("invalid image size: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("invalid image: %v", err)
+	}
+
+	return nil
+}
+
+// keep the rejected file for the admin to check, the dir is outside of the served dirs.
+func quarantine(ctx context.Context, fileName string, data []byte, reason error) {
+	name := xid.New().String() + ".quarantine"
+
+	if err := os.MkdirAll(config.Env.QuarantineDir, 0o700); err != nil {
+		log.Printf("quarantine %s: %v", fileName, err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(config.Env.QuarantineDir, name), data, 0o600); err != nil {
+		log.Printf("quarantine %s: %v", fileName, err)
+		return
+	}
+
+	log.Printf("quarantine %s as %s (request %s): %v", fileName, name, requestid.FromContext(ctx), reason)
+}
@@ -0,0 +1,163 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/perpus_backend/pkg/audit"
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store types.APIKeyStore
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, s types.APIKeyStore) *Handler {
+	return &Handler{store: s, jwt: jwt}
+}
+
+const (
+	cok = http.StatusOK
+
+	keyPrefix    = "pk_"
+	prefixLength = 8 // "pk_" + 5 chars, shown at the list
+)
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	apiKeys := audit.Target{Resource: "api_keys", IDVar: "keyID"}
+
+	r.HandleFunc("/api-keys", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetAPIKeys, types.PermAPIKeysAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/api-keys", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleCreateAPIKey, "api_keys.create", apiKeys), types.PermAPIKeysAdmin))).Methods(http.MethodPost)
+
+	r.HandleFunc("/api-keys/{keyID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleRevokeAPIKey, "api_keys.revoke", apiKeys), types.PermAPIKeysAdmin))).Methods(http.MethodDelete)
+}
+
+func (h *Handler) handleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.GetAPIKeys(r.Context())
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   keys,
+		Status: http.StatusText(cok),
+	})
+}
+
+// the raw key is only returned here, save it at the client because it can't be shown again.
+func (h *Handler) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadAPIKey{
+		Name:        r.FormValue("name"),
+		Permissions: r.Form["permissions"],
+		ExpiresAt:   r.FormValue("expires_at"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	for _, permission := range payload.Permissions {
+		if !types.IsValidAPIKeyPermission(permission) {
+			utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid permission for api key: %s", permission))
+			return
+		}
+	}
+
+	k := &types.APIKey{
+		Name:        payload.Name,
+		Permissions: payload.Permissions,
+		CreatedBy:   jwt.GetUserIDFromContext(ctx),
+	}
+
+	// the key is valid until the end of expires_at date
+	if payload.ExpiresAt != "" {
+		date, err := time.ParseInLocation(time.DateOnly, payload.ExpiresAt, time.Local)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		expiresAt := date.AddDate(0, 0, 1)
+		if !expiresAt.After(time.Now()) {
+			utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("expires_at must be today or later"))
+			return
+		}
+
+		k.ExpiresAt = &expiresAt
+	}
+
+	raw, err := generateKey()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	k.Prefix = raw[:prefixLength]
+	k.KeyHash = hash.HashToken(raw)
+
+	if err := h.store.CreateAPIKey(ctx, k); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.JsonData{
+		Code:    http.StatusCreated,
+		Data:    map[string]any{"api_key": k, "key": raw},
+		Message: "API Key Created! Save the key, it only shown once.",
+		Status:  http.StatusText(http.StatusCreated),
+	})
+}
+
+func (h *Handler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["keyID"]
+
+	if err := uuid.Validate(keyID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.RevokeAPIKey(r.Context(), keyID); err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "API Key Revoked!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandlerAPIKey(t *testing.T) {
+	jwt := &jwt.AuthJWT{}
+	mockAPIKeyStore := types.MockAPIKeyStore{}
+
+	h := NewHandler(jwt, mockAPIKeyStore)
+
+	t.Run("it should correct and make api key", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Kiosk Perpustakaan")
+		form.Add("permissions", types.PermBooksRead)
+		form.Add("permissions", types.PermCirculationsWrite)
+		form.Add("expires_at", "2099-12-31")
+
+		req, err := http.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/api-keys", h.handleCreateAPIKey).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status code: %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		if !strings.Contains(w.Body.String(), `"key":"pk_`) {
+			t.Errorf("expected the raw key at response, got %s", w.Body)
+		}
+	})
+
+	t.Run("it should fail make api key, because it can't have admin permission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Integration")
+		form.Add("permissions", types.PermUsersAdmin)
+
+		req, err := http.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/api-keys", h.handleCreateAPIKey).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code: %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail make api key, because expires_at is in the past", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Integration")
+		form.Add("permissions", types.PermBooksRead)
+		form.Add("expires_at", "2000-01-01")
+
+		req, err := http.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/api-keys", h.handleCreateAPIKey).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code: %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail revoke api key, because key not found", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/api-keys/6918315b-dff4-8324-969f-e43cd434eb3e", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/api-keys/{keyID}", h.handleRevokeAPIKey).Methods(http.MethodDelete)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code: %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
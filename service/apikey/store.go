@@ -0,0 +1,196 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func NewStore(db *sql.DB, rdb *redis.Client) *Store {
+	return &Store{db: db, rdb: rdb}
+}
+
+const (
+	// the key is checked at every request of the machine client
+	apiKeyCacheTTL = 1 * time.Minute
+
+	// last_used_at is not updated more often than this
+	touchInterval = 1 * time.Minute
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *Store) GetAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT 
+	k.id, k.name, k.prefix, k.key_hash, k.permissions, k.created_by, 
+	k.expires_at, k.last_used_at, k.revoked_at, k.created_at 
+	FROM api_keys k 
+	ORDER BY k.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := make([]*types.APIKey, 0)
+
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, keyHash string) (*types.APIKey, error) {
+	apiKey, err := utils.Redis2Key("api_key", keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.rdb.Get(ctx, apiKey).Result()
+	if err == nil {
+		k := new(types.APIKey)
+
+		if err := sonic.Unmarshal([]byte(res), k); err == nil {
+			k.KeyHash = keyHash
+			return k, nil
+		}
+
+		s.rdb.Del(ctx, apiKey)
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `SELECT 
+	k.id, k.name, k.prefix, k.key_hash, k.permissions, k.created_by, 
+	k.expires_at, k.last_used_at, k.revoked_at, k.created_at 
+	FROM api_keys k 
+	WHERE k.key_hash = ?`, keyHash)
+
+	k, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("api key not found")
+		}
+
+		return nil, err
+	}
+
+	if data, err := sonic.Marshal(k); err == nil {
+		_ = s.rdb.SetEx(ctx, apiKey, data, apiKeyCacheTTL).Err()
+	}
+
+	return k, nil
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, k *types.APIKey) error {
+	if k.ID == "" {
+		k.ID = uuid.NewString()
+	}
+
+	var createdBy any
+	if k.CreatedBy != "" {
+		createdBy = k.CreatedBy
+	}
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO api_keys (id, name, prefix, key_hash, permissions, created_by, expires_at) VALUES (?,?,?,?,?,?,?)",
+		k.ID, k.Name, k.Prefix, k.KeyHash, strings.Join(k.Permissions, ","), createdBy, k.ExpiresAt)
+	return err
+}
+
+// the revoked key is rejected right away, since the cache is deleted too.
+func (s *Store) RevokeAPIKey(ctx context.Context, id string) error {
+	var keyHash string
+
+	err := s.db.QueryRowContext(ctx, "SELECT k.key_hash FROM api_keys k WHERE k.id = ?", id).Scan(&keyHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("api key not found")
+		}
+
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL", id); err != nil {
+		return err
+	}
+
+	apiKey, err := utils.Redis2Key("api_key", keyHash)
+	if err != nil {
+		return err
+	}
+
+	s.rdb.Del(ctx, apiKey)
+	return nil
+}
+
+// only the first request in the interval write into db.
+func (s *Store) TouchAPIKey(ctx context.Context, id string) error {
+	touchKey, err := utils.Redis2Key("api_key_touch", id)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.rdb.SetNX(ctx, touchKey, 1, touchInterval).Result()
+	if err != nil || !ok {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = ?", id)
+	return err
+}
+
+func scanAPIKey(row scanner) (*types.APIKey, error) {
+	var (
+		k = new(types.APIKey)
+
+		permissions string
+		createdBy   sql.NullString
+
+		expiresAt, lastUsedAt, revokedAt sql.NullTime
+	)
+
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &permissions, &createdBy, &expiresAt, &lastUsedAt, &revokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	k.CreatedBy = createdBy.String
+	k.Permissions = strings.Split(permissions, ",")
+
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+
+	return k, nil
+}
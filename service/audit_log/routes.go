@@ -0,0 +1,84 @@
+package auditlog
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store types.AuditStore
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, s types.AuditStore) *Handler {
+	return &Handler{store: s, jwt: jwt}
+}
+
+const cok = http.StatusOK
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/audit", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetAuditLogs, types.PermAuditRead))).Methods(http.MethodGet)
+}
+
+// ex: /audit?page=1&actor_id=...&action=books.update&resource=books&resource_id=...&from=2026-01-01&to=2026-01-31
+func (h *Handler) handleGetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page := utils.ParseStringToInt(query.Get("page"))
+
+	payload := types.SetPayloadAuditFilter{
+		ActorID:    query.Get("actor_id"),
+		Action:     query.Get("action"),
+		Resource:   query.Get("resource"),
+		ResourceID: query.Get("resource_id"),
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	f := &types.AuditFilter{
+		ActorID:    payload.ActorID,
+		Action:     payload.Action,
+		Resource:   payload.Resource,
+		ResourceID: payload.ResourceID,
+	}
+
+	if payload.From != "" {
+		from, _ := time.ParseInLocation(time.DateOnly, payload.From, time.Local)
+		f.From = &from
+	}
+
+	// the to date is included until the end of the day
+	if payload.To != "" {
+		to, _ := time.ParseInLocation(time.DateOnly, payload.To, time.Local)
+		to = to.AddDate(0, 0, 1)
+		f.To = &to
+	}
+
+	logs, lastPage, err := h.store.GetAuditLogs(r.Context(), f, page)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:     cok,
+		Data:     logs,
+		LastPage: lastPage,
+		Page:     page,
+		Status:   http.StatusText(cok),
+	})
+}
@@ -0,0 +1,57 @@
+package auditlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandlerAuditLog(t *testing.T) {
+	jwt := &jwt.AuthJWT{}
+	mockAuditStore := types.MockAuditStore{}
+
+	h := NewHandler(jwt, mockAuditStore)
+
+	t.Run("it should be get audit logs with filter", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/audit?page=1&resource=books&action=books.update&from=2026-01-01&to=2026-01-31", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/audit", h.handleGetAuditLogs).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != cok {
+			t.Errorf("expected status code: %d, got %d", cok, w.Code)
+		}
+	})
+
+	t.Run("it should fail get audit logs, because invalid date format", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/audit?from=01-01-2026", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/audit", h.handleGetAuditLogs).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code: %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+}
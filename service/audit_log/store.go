@@ -0,0 +1,144 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/types"
+
+	"github.com/bytedance/sonic"
+)
+
+// no update at this store, the audit log is append-only.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetAuditLogs(ctx context.Context, f *types.AuditFilter, page int) ([]*types.AuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	// set the rows limit, hardcoded
+	limit := 20
+
+	where := make([]string, 0)
+	args := make([]any, 0)
+
+	for _, filter := range []struct{ column, value string }{
+		{"a.actor_id", f.ActorID},
+		{"a.action", f.Action},
+		{"a.resource", f.Resource},
+		{"a.resource_id", f.ResourceID},
+	} {
+		if filter.value != "" {
+			where = append(where, filter.column+" = ?")
+			args = append(args, filter.value)
+		}
+	}
+
+	if f.From != nil {
+		where = append(where, "a.created_at >= ?")
+		args = append(args, *f.From)
+	}
+
+	if f.To != nil {
+		where = append(where, "a.created_at < ?")
+		args = append(args, *f.To)
+	}
+
+	query := `SELECT
+	a.id, a.actor_id, a.actor_type, a.action, a.resource, a.resource_id,
+	a.changes, a.ip, a.request_id, a.status, a.created_at,
+	COUNT(*) OVER() AS num_rows
+	FROM audit_log a`
+
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	query += fmt.Sprintf(" ORDER BY a.id DESC LIMIT %d OFFSET %d", limit, (page-1)*limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	logs := make([]*types.AuditLog, 0)
+
+	var lastPage int64
+
+	for rows.Next() {
+		var (
+			l = new(types.AuditLog)
+
+			total               int64
+			actorID, resourceID sql.NullString
+			changes             []byte
+		)
+
+		err := rows.Scan(&l.ID, &actorID, &l.ActorType, &l.Action, &l.Resource, &resourceID, &changes, &l.IP, &l.RequestID, &l.Status, &l.CreatedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		l.ActorID = actorID.String
+		l.ResourceID = resourceID.String
+
+		if len(changes) > 0 {
+			if err := sonic.Unmarshal(changes, &l.Changes); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		lastPage = int64(math.Ceil(float64(total) / float64(limit)))
+
+		logs = append(logs, l)
+	}
+
+	return logs, lastPage, rows.Err()
+}
+
+func (s *Store) CreateAuditLog(ctx context.Context, l *types.AuditLog) error {
+	var actorID, resourceID, changes any
+
+	if l.ActorID != "" {
+		actorID = l.ActorID
+	}
+
+	if l.ResourceID != "" {
+		resourceID = l.ResourceID
+	}
+
+	if len(l.Changes) > 0 {
+		data, err := sonic.Marshal(l.Changes)
+		if err != nil {
+			return err
+		}
+
+		changes = string(data)
+	}
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO audit_log (actor_id, actor_type, action, resource, resource_id, changes, ip, request_id, status) VALUES (?,?,?,?,?,?,?,?,?)",
+		actorID, l.ActorType, l.Action, l.Resource, resourceID, changes, l.IP, l.RequestID, l.Status)
+	return err
+}
+
+func (s *Store) DeleteAuditLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM audit_log WHERE created_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
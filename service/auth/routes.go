@@ -1,14 +1,26 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"log"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/audit"
+	"github.com/perpus_backend/pkg/cookie"
 	"github.com/perpus_backend/pkg/hash"
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/static"
+	"github.com/perpus_backend/pkg/totp"
+	"github.com/perpus_backend/pkg/upload"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
@@ -18,13 +30,18 @@ import (
 )
 
 type Handler struct {
-	store types.UserStore
+	store        types.UserStore
+	tokenStore   types.AuthTokenStore
+	attemptStore types.LoginAttemptStore
+	tfStore      types.TwoFactorStore
+
+	mailer types.MailSender
 
 	jwt *jwt.AuthJWT
 }
 
-func NewHandler(jwt *jwt.AuthJWT, store types.UserStore) *Handler {
-	return &Handler{store: store, jwt: jwt}
+func NewHandler(jwt *jwt.AuthJWT, store types.UserStore, tokenStore types.AuthTokenStore, attemptStore types.LoginAttemptStore, tfStore types.TwoFactorStore, mailer types.MailSender) *Handler {
+	return &Handler{store: store, tokenStore: tokenStore, attemptStore: attemptStore, tfStore: tfStore, mailer: mailer, jwt: jwt}
 }
 
 const (
@@ -34,12 +51,53 @@ const (
 	privateDir  = "./assets/private"
 
 	size1MB = 1 << 20
+
+	passwordResetTTL     = 1 * time.Hour
+	emailVerificationTTL = 24 * time.Hour
+
+	// the background mail is given up after this
+	mailTimeout = 30 * time.Second
+
+	// time to enter the 2fa code after the password is right
+	twoFactorChallengeTTL = 5 * time.Minute
+
+	// failed login is counted inside this window, per account and per ip
+	loginFailWindow = 15 * time.Minute
+	loginLockTTL    = 15 * time.Minute
+
+	// ip limit is higher, since one school network can be shared by many students
+	accountLockAt = 10
+	ipLockAt      = 100
+
+	// the response of failed login is delayed after this many failures, and doubled every next failure
+	loginDelayAfter = 3
+	maxLoginDelay   = 8 * time.Second
+)
+
+var (
+	errWrongCredential = errors.New("wrong email or password")
+	errLoginLocked     = errors.New("too many failed login attempts, try again later")
+
+	// compared when the email isn't found, so the response time is same as the wrong password
+	dummyPassword, _ = hash.HashPassword("dummy-password-for-timing")
 )
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
+	lockouts := audit.Target{Resource: "lockouts", IDVar: "value"}
+
 	r.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost)
+	r.HandleFunc("/login/2fa", h.handleLoginTwoFactor).Methods(http.MethodPost)
 	r.HandleFunc("/register", h.handleRegister).Methods(http.MethodPost)
+	r.HandleFunc("/refresh", h.handleRefresh).Methods(http.MethodPost)
 	r.HandleFunc("/logout", h.jwt.AuthWithJWTToken(h.handleLogout)).Methods(http.MethodPost)
+
+	r.HandleFunc("/password/forgot", h.handleForgotPassword).Methods(http.MethodPost)
+	r.HandleFunc("/password/reset", h.handleResetPassword).Methods(http.MethodPost)
+	r.HandleFunc("/email/verify", h.handleVerifyEmail).Methods(http.MethodPost)
+	r.HandleFunc("/email/resend", h.handleResendVerification).Methods(http.MethodPost)
+
+	r.HandleFunc("/lockouts", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetLockouts, types.PermUsersAdmin))).Methods(http.MethodGet)
+	r.HandleFunc("/lockouts/{kind}/{value}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleClearLockout, "lockouts.clear", lockouts), types.PermUsersAdmin))).Methods(http.MethodDelete)
 }
 
 // Handler auth login using JWT.
@@ -67,23 +125,124 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account := strings.ToLower(payload.Email)
+	ip := utils.GetClientIP(r)
+
+	if h.isLoginLocked(w, r, account, ip) {
+		return
+	}
+
+	// the unknown email and the wrong password get same response, so the registered email can't be guessed
 	u, err := h.store.GetUserWithRolesByEmail(ctx, payload.Email)
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("wrong email"))
+		hash.CompareHashedPassword(dummyPassword, []byte(payload.Password))
+		h.failLogin(w, r, account, ip)
 		return
 	}
 
 	if !hash.CompareHashedPassword(u.Password, []byte(payload.Password)) {
-		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("wrong password"))
+		h.failLogin(w, r, account, ip)
 		return
 	}
 
-	token, err := h.jwt.CreateTokenJWT(ctx, u.ID)
+	if config.Env.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("email is not verified"))
+		return
+	}
+
+	// the password is right, but the session only created after the 2fa code is verified at /login/2fa.
+	// the failed logins is kept until then, so the 2fa code can't be guessed by login again between the tries.
+	if u.TwoFactorEnabled {
+		challenge, err := h.tokenStore.CreateAuthToken(ctx, types.TokenTwoFactor, u.ID, twoFactorChallengeTTL)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		utils.WriteJSON(w, cok, utils.JsonData{
+			Code:    cok,
+			Data:    map[string]any{"two_factor_required": true, "challenge": challenge},
+			Message: "Two factor authentication code is required",
+			Status:  http.StatusText(cok),
+		})
+		return
+	}
+
+	_ = h.attemptStore.ClearLoginAttempts(ctx, types.LockoutAccount, account)
+
+	h.startSession(w, r, u)
+}
+
+// second step of login for user with 2fa, the challenge is from /login and the code is totp or recovery code.
+func (h *Handler) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadLoginTwoFactor{
+		Challenge: r.FormValue("challenge"),
+		Code:      r.FormValue("code"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	// challenge is single use, so the wrong code must login again from the first step
+	userID, err := h.tokenStore.ConsumeAuthToken(ctx, types.TokenTwoFactor, payload.Challenge)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge"))
+		return
+	}
+
+	u, err := h.store.GetUserWithRolesByID(ctx, userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge"))
+		return
+	}
+
+	account := strings.ToLower(u.Email)
+	ip := utils.GetClientIP(r)
+
+	if h.isLoginLocked(w, r, account, ip) {
+		return
+	}
+
+	if err := totp.Verify(ctx, h.tfStore, u.ID, payload.Code); err != nil {
+		h.failLogin(w, r, account, ip)
+		return
+	}
+
+	_ = h.attemptStore.ClearLoginAttempts(ctx, types.LockoutAccount, account)
+
+	h.startSession(w, r, u)
+}
+
+// create the session, set the refresh cookie and return the access token.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, u *types.User) {
+	// device name can be sent by client app, ex: "Laptop Perpustakaan", or guessed from user agent.
+	device := r.FormValue("device")
+	if device == "" {
+		device = utils.DeviceFromUserAgent(r.UserAgent())
+	}
+
+	token, refreshToken, err := h.jwt.CreateSession(r.Context(), u.ID, &types.Session{
+		Device:    device,
+		IP:        utils.GetClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	cookie.SetRefreshCookie(w, refreshToken, config.Env.RefreshTokenTTL)
+
 	utils.WriteJSON(w, cok, utils.JsonData{
 		Code:   cok,
 		Status: http.StatusText(cok),
@@ -91,20 +250,144 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Handle Logout and Revoke the Token using token versioning (user session ver).
-func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+// check the lockout of the account and the ip, the response is written when one of it is locked.
+func (h *Handler) isLoginLocked(w http.ResponseWriter, r *http.Request, account, ip string) bool {
+	for kind, value := range map[string]string{types.LockoutAccount: account, types.LockoutIP: ip} {
+		locked, err := h.attemptStore.GetLockout(r.Context(), kind, value)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return true
+		}
+
+		if locked > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.Seconds()))))
+			utils.WriteJSONError(w, http.StatusTooManyRequests, errLoginLocked)
+			return true
+		}
+	}
+
+	return false
+}
+
+// count the failed login, lock when it reach the limit, and slow down the response.
+func (h *Handler) failLogin(w http.ResponseWriter, r *http.Request, account, ip string) {
 	ctx := r.Context()
 
-	userID := jwt.GetUserIDFromContext(ctx)
+	accountFails, err := h.attemptStore.RecordFailedLogin(ctx, types.LockoutAccount, account, loginFailWindow)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ipFails, err := h.attemptStore.RecordFailedLogin(ctx, types.LockoutIP, ip, loginFailWindow)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
 
-	token := utils.GetTokenFromRequest(r)
+	if accountFails >= accountLockAt {
+		_ = h.attemptStore.LockLogin(ctx, types.LockoutAccount, account, loginLockTTL)
+	}
+
+	if ipFails >= ipLockAt {
+		_ = h.attemptStore.LockLogin(ctx, types.LockoutIP, ip, loginLockTTL)
+	}
+
+	if delay := loginDelay(accountFails); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	utils.WriteJSONError(w, http.StatusBadRequest, errWrongCredential)
+}
 
-	err := h.store.IncrementTokenVersion(ctx, userID, token)
+// 1s, 2s, 4s, ... after loginDelayAfter failures, up to maxLoginDelay.
+func loginDelay(fails int64) time.Duration {
+	if fails <= loginDelayAfter {
+		return 0
+	}
+
+	delay := time.Second << min(fails-loginDelayAfter-1, 10)
+
+	return min(delay, maxLoginDelay)
+}
+
+// Handle get the active lockouts, for admin.
+func (h *Handler) handleGetLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.attemptStore.GetLockouts(r.Context())
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   lockouts,
+		Status: http.StatusText(cok),
+	})
+}
+
+// Handle clear the lockout and the failed counter of account or ip, for admin.
+func (h *Handler) handleClearLockout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	kind := vars["kind"]
+	if kind != types.LockoutAccount && kind != types.LockoutIP {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid lockout kind: %s", kind))
+		return
+	}
+
+	value := vars["value"]
+	if kind == types.LockoutAccount {
+		value = strings.ToLower(value)
+	}
+
+	if err := h.attemptStore.ClearLoginAttempts(r.Context(), kind, value); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Lockout Cleared!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// Handle refresh the access token, the refresh token is read from cookie and rotated.
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token, refreshToken, err := h.jwt.RefreshSession(ctx, cookie.GetRefreshCookie(r))
+	if err != nil {
+		cookie.ClearRefreshCookie(w)
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	cookie.SetRefreshCookie(w, refreshToken, config.Env.RefreshTokenTTL)
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Status: http.StatusText(cok),
+		Token:  token,
+	})
+}
+
+// Handle Logout, only revoke the session of this device. the other devices keep logged in.
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := h.jwt.RevokeSession(ctx, jwt.GetSessionIDFromContext(ctx)); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie.ClearRefreshCookie(w)
+
 	utils.WriteJSON(w, cok, utils.JsonData{
 		Code:    cok,
 		Message: "You've been Logout!",
@@ -164,37 +447,37 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if errFile == nil {
 		defer file.Close()
 
-		randomString := xid.New().String()
-		ext := filepath.Ext(header.Filename)
-
-		fileName = randomString + ext
+		avatar, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
 
-		if size1MB <= header.Size {
-			if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
-				dst, _ := os.Create(profilePath + fileName)
-				defer dst.Close()
+		fileName = xid.New().String() + avatar.Ext
 
-				io.Copy(dst, file)
-			} else {
-				utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("only serve png, jpg, and jpeg file"))
-				return
-			}
-		} else {
-			utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("only serve file under 1 mb"))
+		if err := avatar.Save(profilePath + fileName); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
 	}
 
-	if err := h.store.CreateUser(ctx, &types.User{
+	u := &types.User{
 		Name:     payload.Name,
 		Email:    payload.Email,
 		Password: hashPass,
 		Avatar:   fileName,
-	}); err != nil {
+	}
+
+	if err := h.store.CreateUser(ctx, u); err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// the user is already registered, so the failed mail can be sent again with resend
+	if err := h.sendVerification(ctx, u); err != nil {
+		log.Println(err)
+	}
+
 	utils.WriteJSON(w, http.StatusCreated, utils.JsonData{
 		Code:    http.StatusCreated,
 		Message: "User Registered!",
@@ -202,6 +485,207 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Handle forgot password, the response is same whether the email is registered or not.
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadForgotPassword{
+		Email: r.FormValue("email"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	if u, err := h.store.GetUserWithRolesByEmail(ctx, payload.Email); err == nil {
+		h.sendInBackground(ctx, func(ctx context.Context) error { return h.sendPasswordReset(ctx, u) })
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "If the email is registered, the reset link has been sent!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// Handle reset password with the token from email. every session is revoked after it.
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadResetPassword{
+		Token:    r.FormValue("token"),
+		Password: r.FormValue("password"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	userID, err := h.tokenStore.ConsumeAuthToken(ctx, types.TokenPasswordReset, payload.Token)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// the other reset links which is sent before can't be used anymore
+	if err := h.tokenStore.RevokeAuthTokens(ctx, types.TokenPasswordReset, userID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	hashPass, err := hash.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.UpdateUserPassword(ctx, userID, hashPass); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.jwt.RevokeUserSessions(ctx, userID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the reset link prove the email is owned by the user
+	_ = h.store.VerifyUserEmail(ctx, userID)
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Password has been Reset!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// Handle verify email with the token from email.
+func (h *Handler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadVerifyEmail{
+		Token: r.FormValue("token"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	userID, err := h.tokenStore.ConsumeAuthToken(ctx, types.TokenEmailVerification, payload.Token)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.VerifyUserEmail(ctx, userID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Email Verified!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// Handle send the verification email again. the response is same whether the email is registered or not.
+func (h *Handler) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadForgotPassword{
+		Email: r.FormValue("email"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	if u, err := h.store.GetUserWithRolesByEmail(ctx, payload.Email); err == nil && u.EmailVerifiedAt == nil {
+		h.sendInBackground(ctx, func(ctx context.Context) error { return h.sendVerification(ctx, u) })
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "If the email is registered and not verified yet, the verification link has been sent!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// the mail is sent at background, so the response time doesn't tell whether the email is registered.
+func (h *Handler) sendInBackground(ctx context.Context, send func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+
+	go func() {
+		defer cancel()
+
+		if err := send(ctx); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// only the newest reset link is valid, the older one is revoked.
+func (h *Handler) sendPasswordReset(ctx context.Context, u *types.User) error {
+	if err := h.tokenStore.RevokeAuthTokens(ctx, types.TokenPasswordReset, u.ID); err != nil {
+		return err
+	}
+
+	token, err := h.tokenStore.CreateAuthToken(ctx, types.TokenPasswordReset, u.ID, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(ctx, &types.Mail{
+		To:      u.Email,
+		Subject: "Reset Password",
+		Body: fmt.Sprintf("Halo %s,\n\nBuka link berikut untuk reset password akun kamu:\n%s/reset-password?token=%s\n\nLink ini berlaku %s dan hanya bisa dipakai sekali. Abaikan email ini jika kamu tidak meminta reset password.",
+			u.Name, config.Env.LocalAddress, url.QueryEscape(token), passwordResetTTL),
+	})
+}
+
+func (h *Handler) sendVerification(ctx context.Context, u *types.User) error {
+	token, err := h.tokenStore.CreateAuthToken(ctx, types.TokenEmailVerification, u.ID, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(ctx, &types.Mail{
+		To:      u.Email,
+		Subject: "Verifikasi Email",
+		Body: fmt.Sprintf("Halo %s,\n\nBuka link berikut untuk verifikasi email kamu:\n%s/verify-email?token=%s\n\nLink ini berlaku %s dan hanya bisa dipakai sekali.",
+			u.Name, config.Env.LocalAddress, url.QueryEscape(token), emailVerificationTTL),
+	})
+}
+
 func (h *Handler) PrivateURLHandler(w http.ResponseWriter, r *http.Request) {
 	filename := mux.Vars(r)["filename"]
 
@@ -213,5 +697,6 @@ func (h *Handler) PrivateURLHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.ServeFile(w, r, cleaned)
+	// the private file is revalidated every time, so the revoked user can't use the cache of shared device
+	static.ServeFile(w, r, cleaned, "private, no-cache")
 }
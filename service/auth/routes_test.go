@@ -2,11 +2,16 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/perpus_backend/pkg/hash"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 
@@ -16,8 +21,12 @@ import (
 func TestAuthHandler(t *testing.T) {
 	jwt := &jwt.AuthJWT{}
 	userStore := &types.MockUserStore{}
+	tokenStore := types.MockAuthTokenStore{}
+	attemptStore := types.MockLoginAttemptStore{}
+	tfStore := types.MockTwoFactorStore{}
+	mailer := types.MockMailSender{}
 
-	h := NewHandler(jwt, userStore)
+	h := NewHandler(jwt, userStore, tokenStore, attemptStore, tfStore, mailer)
 
 	t.Run("it should fail register, because use wrong email format", func(t *testing.T) {
 		body := &bytes.Buffer{}
@@ -105,4 +114,285 @@ func TestAuthHandler(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
 		}
 	})
+
+	t.Run("it should fail refresh, because the refresh cookie is missing", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/refresh", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/refresh", h.handleRefresh).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("it should always success forgot password, even the email isn't registered", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("email", "notfound@mail.com")
+
+		req, err := http.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/password/forgot", h.handleForgotPassword).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != cok {
+			t.Errorf("expected status code %d, got %d", cok, w.Code)
+		}
+	})
+
+	t.Run("it should fail reset password, because the token is invalid", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("token", "asd")
+		form.Add("password", "newpassword")
+
+		req, err := http.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/password/reset", h.handleResetPassword).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail verify email, because the token is invalid", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("token", "asd")
+
+		req, err := http.NewRequest(http.MethodPost, "/email/verify", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/email/verify", h.handleVerifyEmail).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should be get lockouts", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/lockouts", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/lockouts", h.handleGetLockouts).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != cok {
+			t.Errorf("expected status code %d, got %d", cok, w.Code)
+		}
+	})
+
+	t.Run("it should fail clear lockout, because invalid kind", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/lockouts/user/asd@mail.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/lockouts/{kind}/{value}", h.handleClearLockout).Methods(http.MethodDelete)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail login with same error, because the email isn't registered", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("email", "notfound@mail.com")
+		form.Add("password", "asdasd")
+
+		req, err := http.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail 2fa login, because the challenge is invalid", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("challenge", "invalid-challenge")
+		form.Add("code", "123456")
+
+		req, err := http.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/login/2fa", h.handleLoginTwoFactor).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for check error in body
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should not clear the failed logins before the 2fa code is verified", func(t *testing.T) {
+		hashPass, err := hash.HashPassword("password123")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		attempts := &recordAttemptStore{}
+		users := twoFactorUserStore{user: &types.User{ID: "user-1", Email: "budi@mail.com", Password: hashPass, TwoFactorEnabled: true}}
+
+		h := NewHandler(jwt, users, tokenStore, attempts, tfStore, mailer)
+
+		form := url.Values{}
+		form.Add("email", "budi@mail.com")
+		form.Add("password", "password123")
+
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if len(attempts.cleared) != 0 {
+			t.Errorf("expected the failed logins is kept, got cleared %v", attempts.cleared)
+		}
+	})
+
+	t.Run("it should fail 2fa login, because the account is locked", func(t *testing.T) {
+		users := twoFactorUserStore{user: &types.User{ID: "user-1", Email: "budi@mail.com", TwoFactorEnabled: true}}
+		attempts := &recordAttemptStore{locked: map[string]bool{types.LockoutAccount + ":budi@mail.com": true}}
+
+		h := NewHandler(jwt, users, challengeTokenStore{userID: "user-1"}, attempts, tfStore, mailer)
+
+		form := url.Values{}
+		form.Add("challenge", "challenge")
+		form.Add("code", "123456")
+
+		req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/login/2fa", h.handleLoginTwoFactor).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+	})
+}
+
+// user store which has the one user, for the login flow.
+type twoFactorUserStore struct {
+	types.MockUserStore
+	user *types.User
+}
+
+func (s twoFactorUserStore) GetUserWithRolesByID(ctx context.Context, id string) (*types.User, error) {
+	return s.user, nil
+}
+
+func (s twoFactorUserStore) GetUserWithRolesByEmail(ctx context.Context, email string) (*types.User, error) {
+	return s.user, nil
+}
+
+// token store which accept any challenge as the user.
+type challengeTokenStore struct {
+	types.MockAuthTokenStore
+	userID string
+}
+
+func (s challengeTokenStore) ConsumeAuthToken(ctx context.Context, purpose, token string) (string, error) {
+	return s.userID, nil
+}
+
+// attempt store which record the cleared counters, and lock the given "kind:value".
+type recordAttemptStore struct {
+	types.MockLoginAttemptStore
+	locked  map[string]bool
+	cleared []string
+}
+
+func (s *recordAttemptStore) GetLockout(ctx context.Context, kind, value string) (time.Duration, error) {
+	if s.locked[kind+":"+value] {
+		return time.Minute, nil
+	}
+
+	return 0, nil
+}
+
+func (s *recordAttemptStore) ClearLoginAttempts(ctx context.Context, kind, value string) error {
+	s.cleared = append(s.cleared, kind+":"+value)
+	return nil
 }
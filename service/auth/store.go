@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// the token is saved by the hash, the raw one only sent at the email.
+func (s *Store) CreateAuthToken(ctx context.Context, purpose, userID string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	tokenKey, err := utils.Redis2Key(purpose, hash.HashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	userKey, err := utils.Redis2Key(purpose+"_user", userID)
+	if err != nil {
+		return "", err
+	}
+
+	// the token keys of the user is kept at a set, so all of it can be revoked
+	pipe := s.rdb.TxPipeline()
+	pipe.SetEx(ctx, tokenKey, userID, ttl)
+	pipe.SAdd(ctx, userKey, tokenKey)
+	pipe.Expire(ctx, userKey, ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GETDEL make sure the token only can be used once, even with concurrent request.
+func (s *Store) ConsumeAuthToken(ctx context.Context, purpose, token string) (string, error) {
+	tokenKey, err := utils.Redis2Key(purpose, hash.HashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.rdb.GetDel(ctx, tokenKey).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("invalid or expired token")
+	} else if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (s *Store) RevokeAuthTokens(ctx context.Context, purpose, userID string) error {
+	userKey, err := utils.Redis2Key(purpose+"_user", userID)
+	if err != nil {
+		return err
+	}
+
+	tokenKeys, err := s.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.Del(ctx, append(tokenKeys, userKey)...).Err()
+}
+
+func (s *Store) GetLockout(ctx context.Context, kind, value string) (time.Duration, error) {
+	ttl, err := s.rdb.TTL(ctx, "login_lock:"+kind+":"+value).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// -2 the key doesn't exist, -1 the key has no expiry (never set by us)
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (s *Store) GetLockouts(ctx context.Context) ([]*types.Lockout, error) {
+	lockouts := make([]*types.Lockout, 0)
+
+	iter := s.rdb.Scan(ctx, 0, "login_lock:*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		kind, value, ok := strings.Cut(strings.TrimPrefix(iter.Val(), "login_lock:"), ":")
+		if !ok {
+			continue
+		}
+
+		ttl, err := s.GetLockout(ctx, kind, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if ttl == 0 {
+			continue // expired while scanning
+		}
+
+		failures, _ := s.rdb.Get(ctx, "login_fail:"+kind+":"+value).Int64()
+
+		lockouts = append(lockouts, &types.Lockout{
+			LockedUntil: time.Now().Add(ttl).Truncate(time.Second),
+			Kind:        kind,
+			Value:       value,
+			Failures:    failures,
+		})
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return lockouts, nil
+}
+
+// the window start from the first failure, the next failures don't extend it.
+func (s *Store) RecordFailedLogin(ctx context.Context, kind, value string, window time.Duration) (int64, error) {
+	failKey := "login_fail:" + kind + ":" + value
+
+	count, err := s.rdb.Incr(ctx, failKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 {
+		s.rdb.Expire(ctx, failKey, window)
+	}
+
+	return count, nil
+}
+
+func (s *Store) LockLogin(ctx context.Context, kind, value string, ttl time.Duration) error {
+	return s.rdb.SetEx(ctx, "login_lock:"+kind+":"+value, 1, ttl).Err()
+}
+
+func (s *Store) ClearLoginAttempts(ctx context.Context, kind, value string) error {
+	return s.rdb.Del(ctx, "login_fail:"+kind+":"+value, "login_lock:"+kind+":"+value).Err()
+}
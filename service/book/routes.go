@@ -3,12 +3,14 @@ package book
 import (
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/upload"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
@@ -45,15 +47,17 @@ const (
 )
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/books", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetBooks, "admin", "staff", "user"))).Methods(http.MethodGet)
+	books := audit.Target{Resource: "books", IDVar: "bookID", Load: audit.Load(h.store.GetBookByID)}
 
-	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetBookByID, "admin", "staff", "user"))).Methods(http.MethodGet)
+	r.HandleFunc("/books", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetBooks, types.PermBooksRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/books", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleCreateBook, "admin", "staff"))).Methods(http.MethodPost)
+	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetBookByID, types.PermBooksRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleUpdateBook, "admin", "staff"))).Methods(http.MethodPut)
+	r.HandleFunc("/books", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleCreateBook, "books.create", books), types.PermBooksWrite))).Methods(http.MethodPost)
 
-	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteBook, "admin", "staff"))).Methods(http.MethodDelete)
+	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleUpdateBook, "books.update", books), types.PermBooksWrite))).Methods(http.MethodPut)
+
+	r.HandleFunc("/books/{bookID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleDeleteBook, "books.delete", books), types.PermBooksWrite))).Methods(http.MethodDelete)
 }
 
 func (h *Handler) handleGetBooks(w http.ResponseWriter, r *http.Request) {
@@ -103,10 +107,9 @@ func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
 
-		fileName, filePDF  string
-		coverPath, pdfPath string
-		extCover, extPDF   string
-		sizeCover, sizePDF int64
+		fileName, filePDF string
+		cover, pdf        *upload.File
+		err               error
 	)
 
 	r.Body = http.MaxBytesReader(w, r.Body, size10MB)
@@ -121,6 +124,8 @@ func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
 		Penulis:   r.FormValue("penulis"),
 		Pengarang: r.FormValue("pengarang"),
 		Tahun:     r.FormValue("tahun"),
+		Kategori:  r.FormValue("kategori"),
+		Bahasa:    r.FormValue("bahasa"),
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
@@ -129,6 +134,13 @@ func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if payload.Kategori == "" {
+		payload.Kategori = "-"
+	}
+	if payload.Bahasa == "" {
+		payload.Bahasa = "-"
+	}
+
 	if _, err := h.store.GetBookByJudulBuku(ctx, payload.JudulBuku); err == nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("judul_buku: %s is already exists", payload.JudulBuku))
 		return
@@ -146,75 +158,56 @@ func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
 	// fill the pdf file, if input form file of PDF is empty
 	if errPDF == http.ErrMissingFile {
 		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("required pdf"))
-		filePDF = "-"
 		return
 	}
 
-	extCover = filepath.Ext(headerCB.Filename) // get the extension in input form file cover book
-	sizeCover = headerCB.Size                  // get the actual size from input form file "cover_buku"
-
-	extPDF = filepath.Ext(headerPDF.Filename) // get the extension in input form file book pdf
-	sizePDF = headerPDF.Size                  // get the actual size from input form file "buku_pdf"
-
-	// doing some validation
-	// check if extension is not same with expected want
-	if extCover != ".png" && extCover != ".jpg" && extCover != ".jpeg" {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only support png, jpg, and jpeg"))
-		return
-	}
+	// the content is checked by the magic bytes and parsed, the extension of file name isn't trusted
+	if errCB == nil {
+		defer fileCoverBook.Close()
 
-	// check if the size file cover_buku over 1mb
-	if sizeCover > size1MB {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only serve file cover under 1mb"))
-		return
+		if cover, err = upload.Check(ctx, fileCoverBook, headerCB, upload.Image, size1MB); err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
 	}
 
-	// check if input form file PDF doesn't exist
-	if extPDF != ".pdf" {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("convert to pdf first"))
-		return
-	}
+	if errPDF == nil {
+		defer filePDFbook.Close()
 
-	// check if size file pdf over 8mb
-	if sizePDF > size8MB {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only serve file pdf under 8mb"))
-		return
+		if pdf, err = upload.Check(ctx, filePDFbook, headerPDF, upload.PDF, size8MB); err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
 	}
 
 	// if cover_buku pass all the validation, then create a file and put in at dir was it set before
-	if errCB == nil {
-		defer fileCoverBook.Close()
-
-		randomString := xid.New().String()
-
-		fileName = randomString + extCover
-		coverPath = dirCoverBookPath + fileName // make it 2 in 1
-
-		dst, _ := os.Create(coverPath)
-		defer dst.Close()
+	if cover != nil {
+		fileName = xid.New().String() + cover.Ext
 
-		io.Copy(dst, fileCoverBook)
+		if err := cover.Save(dirCoverBookPath + fileName); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
 	}
 
 	// if buku_pdf pass all the validation, then create a file and put in at dir was it set before
-	if errPDF == nil {
-		defer filePDFbook.Close()
-
-		filePDF = headerPDF.Filename
-		pdfPath = dirPDFBookPath + filePDF // make it 2 in 1
+	if pdf != nil {
+		filePDF = strings.TrimSuffix(headerPDF.Filename, filepath.Ext(headerPDF.Filename)) + pdf.Ext
 
-		dest, _ := os.Create(pdfPath)
-		defer dest.Close()
-
-		io.Copy(dest, filePDFbook)
+		if err := pdf.Save(dirPDFBookPath + filePDF); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
 	}
 
-	err := h.store.CreateBook(ctx, &types.Book{
+	err = h.store.CreateBook(ctx, &types.Book{
 		JudulBuku: payload.JudulBuku,
 		CoverBuku: fileName,
 		BukuPDF:   filePDF,
 		Penulis:   payload.Penulis,
 		Pengarang: payload.Pengarang,
+		Kategori:  payload.Kategori,
+		Bahasa:    payload.Bahasa,
 		Tahun:     utils.ParseStringToInt(payload.Tahun),
 	})
 	if err != nil {
@@ -236,10 +229,8 @@ func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
 
-		fileName, filePDF  string
-		coverPath, pdfPath string
-		extCov, extPdf     string
-		sizeCover, sizePDF int64
+		fileName, filePDF string
+		cover, pdf        *upload.File
 	)
 
 	if r.Method != http.MethodPut {
@@ -264,6 +255,8 @@ func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) {
 		Penulis:   r.FormValue("penulis"),
 		Pengarang: r.FormValue("pengarang"),
 		Tahun:     r.FormValue("tahun"),
+		Kategori:  r.FormValue("kategori"),
+		Bahasa:    r.FormValue("bahasa"),
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
@@ -290,6 +283,12 @@ func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) {
 	if payload.Tahun != "" {
 		b.Tahun = utils.ParseStringToInt(payload.Tahun)
 	}
+	if payload.Kategori != "" {
+		b.Kategori = payload.Kategori
+	}
+	if payload.Bahasa != "" {
+		b.Bahasa = payload.Bahasa
+	}
 
 	// it same goes like the upper, at handleCreateBook()
 	fileCoverBook, headerCB, errCB := r.FormFile("cover_buku")
@@ -304,76 +303,46 @@ func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) {
 		filePDF = b.BukuPDF
 	}
 
-	extCov = filepath.Ext(headerCB.Filename)
-	sizeCover = headerCB.Size
-
-	extPdf = filepath.Ext(headerPDF.Filename)
-	sizePDF = headerPDF.Size
-
-	if extCov != ".png" && extCov != ".jpg" && extCov != ".jpeg" {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only support png, jpg, and jpeg"))
-		return
-	}
-
-	if sizeCover > size1MB {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only serve file cover under 1mb"))
-		return
-	}
-
-	if extPdf != ".pdf" {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("convert to pdf first"))
-		return
-	}
-
-	if sizePDF > size8MB {
-		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only serve file pdf under 8mb"))
-		return
-	}
-
 	if errCB == nil {
 		defer fileCoverBook.Close()
 
-		fileImg := dirCoverBookPath + b.CoverBuku
-		info, err := os.Stat(fileImg)
-
-		if err == nil {
-			if !info.IsDir() {
-				os.Remove(fileImg) // remove file old first, for avoid accident stack a goddamn storage memory
-			}
+		if cover, err = upload.Check(ctx, fileCoverBook, headerCB, upload.Image, size1MB); err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
 		}
-
-		randomString := xid.New().String()
-
-		fileName = randomString + extCov
-
-		coverPath = dirCoverBookPath + fileName
-
-		dst, _ := os.Create(coverPath)
-		defer dst.Close()
-
-		io.Copy(dst, fileCoverBook)
 	}
 
 	if errPDF == nil {
 		defer filePDFBook.Close()
 
-		filePDFOld := dirPDFBookPath + b.BukuPDF
-		info, err := os.Stat(filePDFOld)
+		if pdf, err = upload.Check(ctx, filePDFBook, headerPDF, upload.PDF, size8MB); err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
+	}
 
-		if err == nil {
-			if !info.IsDir() {
-				os.Remove(filePDFOld)
-			}
+	if cover != nil {
+		fileName = xid.New().String() + cover.Ext
+
+		if err := cover.Save(dirCoverBookPath + fileName); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
 		}
 
-		filePDF = headerPDF.Filename
+		removeFile(dirCoverBookPath + b.CoverBuku) // remove file old, for avoid accident stack a goddamn storage memory
+	}
 
-		pdfPath = dirPDFBookPath + filePDF
+	if pdf != nil {
+		filePDF = strings.TrimSuffix(headerPDF.Filename, filepath.Ext(headerPDF.Filename)) + pdf.Ext
 
-		dest, _ := os.Create(pdfPath)
-		defer dest.Close()
+		if err := pdf.Save(dirPDFBookPath + filePDF); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
 
-		io.Copy(dest, fileCoverBook)
+		if filePDF != b.BukuPDF {
+			removeFile(dirPDFBookPath + b.BukuPDF)
+		}
 	}
 
 	err = h.store.UpdateBook(ctx, bookID, &types.Book{
@@ -382,6 +351,8 @@ func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) {
 		BukuPDF:   filePDF,
 		Penulis:   b.Penulis,
 		Pengarang: b.Pengarang,
+		Kategori:  b.Kategori,
+		Bahasa:    b.Bahasa,
 		Tahun:     b.Tahun,
 	})
 	if err != nil {
@@ -444,3 +415,10 @@ func (h *Handler) handleDeleteBook(w http.ResponseWriter, r *http.Request) {
 		Status:  http.StatusText(cok),
 	})
 }
+
+// for reason, to not delete the folder when file doesn't exist inside the dir
+func removeFile(path string) {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		os.Remove(path)
+	}
+}
@@ -2,6 +2,9 @@ package book
 
 import (
 	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -59,6 +62,8 @@ func TestHandlerBook(t *testing.T) {
 	})
 
 	t.Run("it should make a book", func(t *testing.T) {
+		t.Chdir(t.TempDir()) // the cover and pdf is saved at ./assets
+
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
@@ -79,14 +84,17 @@ func TestHandlerBook(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		img.Write([]byte("fake img file"))
+		// the content is checked, so it must be real image and pdf
+		if err := jpeg.Encode(img, image.NewRGBA(image.Rect(0, 0, 1, 1)), nil); err != nil {
+			t.Fatal(err)
+		}
 
 		pdf, err := writer.CreateFormFile("buku_pdf", "test.pdf")
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		pdf.Write([]byte("fake pdf file"))
+		pdf.Write(makePDF(""))
 
 		writer.Close()
 
@@ -109,4 +117,48 @@ func TestHandlerBook(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
 		}
 	})
+	t.Run("it should fail make a book, because the pdf has javascript", func(t *testing.T) {
+		t.Chdir(t.TempDir()) // the rejected pdf is quarantined at ./assets
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		writer.WriteField("judul_buku", "wleee 2")
+		writer.WriteField("penulis", "si itu")
+		writer.WriteField("pengarang", "si ini")
+		writer.WriteField("tahun", "2025")
+
+		pdf, err := writer.CreateFormFile("buku_pdf", "test.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pdf.Write(makePDF("/OpenAction << /S /J#61vaScript /JS (app.alert(1)) >>"))
+
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/books", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/books", h.handleCreateBook).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+}
+
+// the smallest pdf with the catalog, the cross reference, and the trailer.
+func makePDF(catalog string) []byte {
+	objects := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R " + catalog + " >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+
+	return fmt.Appendf(nil, "%sxref\n0 1\n0000000000 65535 f \ntrailer\n<< /Size 3 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", objects, len(objects))
 }
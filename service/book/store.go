@@ -43,7 +43,7 @@ func (s *Store) GetBooksWithPagination(ctx context.Context, page int) ([]*types.
 
 	limit := 10
 
-	query := fmt.Sprintf("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.created_at, b.updated_at, COUNT(*) OVER() AS num_rows FROM books b GROUP BY b.id ORDER BY %s %s LIMIT %d OFFSET %d", sortByColumn, sortOrder, limit, (page-1)*limit)
+	query := fmt.Sprintf("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.kategori, b.bahasa, NOT EXISTS (SELECT 1 FROM circulations c WHERE c.buku_id = b.id) AS tersedia, b.created_at, b.updated_at, COUNT(*) OVER() AS num_rows FROM books b GROUP BY b.id ORDER BY %s %s LIMIT %d OFFSET %d", sortByColumn, sortOrder, limit, (page-1)*limit)
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -78,7 +78,7 @@ func (s *Store) GetBooksWithPagination(ctx context.Context, page int) ([]*types.
 }
 
 func (s *Store) GetBooksForSearch(ctx context.Context) []*types.Book {
-	query := "SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.created_at, b.updated_at FROM books b"
+	query := "SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.kategori, b.bahasa, NOT EXISTS (SELECT 1 FROM circulations c WHERE c.buku_id = b.id) AS tersedia, b.created_at, b.updated_at FROM books b"
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -127,7 +127,7 @@ func (s *Store) GetBookByID(ctx context.Context, id string) (*types.Book, error)
 		return nil, err
 	}
 
-	stmt, err := s.db.Prepare("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.created_at, b.updated_at FROM books b WHERE b.id = ?")
+	stmt, err := s.db.Prepare("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.kategori, b.bahasa, NOT EXISTS (SELECT 1 FROM circulations c WHERE c.buku_id = b.id) AS tersedia, b.created_at, b.updated_at FROM books b WHERE b.id = ?")
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +147,7 @@ func (s *Store) GetBookByID(ctx context.Context, id string) (*types.Book, error)
 }
 
 func (s *Store) GetBookByJudulBuku(ctx context.Context, judulBuku string) (*types.Book, error) {
-	stmt, err := s.db.Prepare("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.created_at, b.updated_at FROM books b WHERE b.judul_buku = ?")
+	stmt, err := s.db.Prepare("SELECT b.id, b.id_buku, b.judul_buku, b.cover_buku, b.buku_pdf, b.penulis, b.pengarang, b.tahun, b.kategori, b.bahasa, NOT EXISTS (SELECT 1 FROM circulations c WHERE c.buku_id = b.id) AS tersedia, b.created_at, b.updated_at FROM books b WHERE b.judul_buku = ?")
 	if err != nil {
 		return nil, err
 	}
@@ -217,14 +217,14 @@ func (s *Store) CreateBook(ctx context.Context, b *types.Book) error {
 		b.IdBuku = IDBook
 	}
 
-	stmtInsert, err := tx.Prepare("INSERT INTO books (id, id_buku, judul_buku, cover_buku, buku_pdf, penulis, pengarang, tahun) VALUES (?,?,?,?,?,?,?,?)")
+	stmtInsert, err := tx.Prepare("INSERT INTO books (id, id_buku, judul_buku, cover_buku, buku_pdf, penulis, pengarang, tahun, kategori, bahasa) VALUES (?,?,?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return err
 	}
 
 	defer stmtInsert.Close()
 
-	_, err = stmtInsert.ExecContext(ctx, b.ID, b.IdBuku, b.JudulBuku, b.CoverBuku, b.BukuPDF, b.Penulis, b.Pengarang, b.Tahun)
+	_, err = stmtInsert.ExecContext(ctx, b.ID, b.IdBuku, b.JudulBuku, b.CoverBuku, b.BukuPDF, b.Penulis, b.Pengarang, b.Tahun, b.Kategori, b.Bahasa)
 	if err != nil {
 		return err
 	}
@@ -242,7 +242,7 @@ func (s *Store) UpdateBook(ctx context.Context, id string, b *types.Book) error
 		return err
 	}
 
-	stmt, err := s.db.Prepare("UPDATE books SET judul_buku = ?, cover_buku = ?, buku_pdf = ?, penulis = ?, pengarang = ?, tahun = ? WHERE id = ?")
+	stmt, err := s.db.Prepare("UPDATE books SET judul_buku = ?, cover_buku = ?, buku_pdf = ?, penulis = ?, pengarang = ?, tahun = ?, kategori = ?, bahasa = ? WHERE id = ?")
 	if err != nil {
 		return err
 	}
@@ -250,7 +250,7 @@ func (s *Store) UpdateBook(ctx context.Context, id string, b *types.Book) error
 	defer stmt.Close()
 
 	s.rdb.Del(ctx, bookKey)
-	_, err = stmt.ExecContext(ctx, b.JudulBuku, b.CoverBuku, b.BukuPDF, b.Penulis, b.Pengarang, b.Tahun, id)
+	_, err = stmt.ExecContext(ctx, b.JudulBuku, b.CoverBuku, b.BukuPDF, b.Penulis, b.Pengarang, b.Tahun, b.Kategori, b.Bahasa, id)
 	return err
 }
 
@@ -1,9 +1,12 @@
 package circulation
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
@@ -14,32 +17,37 @@ import (
 )
 
 type Handler struct {
-	store     types.CirculationStore
-	userStore types.UserStore
+	store       types.CirculationStore
+	userStore   types.UserStore
+	memberStore types.MemberStore
 
 	jwt *jwt.AuthJWT
 }
 
-func NewHandler(jwt *jwt.AuthJWT, s types.CirculationStore, us types.UserStore) *Handler {
+func NewHandler(jwt *jwt.AuthJWT, s types.CirculationStore, us types.UserStore, ms types.MemberStore) *Handler {
 	return &Handler{
-		store:     s,
-		userStore: us,
-		jwt:       jwt,
+		store:       s,
+		userStore:   us,
+		memberStore: ms,
+		jwt:         jwt,
 	}
 }
 
 const cok = http.StatusOK
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/circulations", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetCirculations, "admin", "staff"))).Methods(http.MethodGet)
+	circulations := audit.Target{Resource: "circulations", IDVar: "cID", Load: audit.Load(h.store.GetCirculationByID)}
 
-	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetCirculationByID, "admin", "staff"))).Methods(http.MethodGet)
+	r.HandleFunc("/circulations", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetCirculations, types.PermCirculationsRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/circulations", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleCreateCirculation, "admin", "staff"))).Methods(http.MethodPost)
+	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetCirculationByID, types.PermCirculationsRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleUpdateCirculation, "admin", "staff"))).Methods(http.MethodPatch)
+	r.HandleFunc("/circulations", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleCreateCirculation, "circulations.create", circulations), types.PermCirculationsWrite))).Methods(http.MethodPost)
 
-	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteCirculation, "admin", "staff"))).Methods(http.MethodDelete)
+	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleUpdateCirculation, "circulations.update", circulations), types.PermCirculationsWrite))).Methods(http.MethodPatch)
+
+	// the book is returned by delete the circulation, so it's available again
+	r.HandleFunc("/circulations/{cID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleDeleteCirculation, "circulations.return", circulations), types.PermCirculationsReturn))).Methods(http.MethodDelete)
 }
 
 func (h *Handler) handleGetCirculations(w http.ResponseWriter, r *http.Request) {
@@ -107,14 +115,21 @@ func (h *Handler) handleCreateCirculation(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if _, err := h.store.GetCirculationByPeminjam(ctx, payload.Peminjam); err == nil {
+	m, status, err := h.findMember(ctx, payload.Peminjam)
+	if err != nil {
+		utils.WriteJSONError(w, status, err)
+		return
+	}
+
+	if _, err := h.store.GetCirculationByMemberID(ctx, m.ID); err == nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("peminjam has name: %v been exist", payload.Peminjam))
 		return
 	}
 
-	err := h.store.CreateCirculation(ctx, &types.Circulation{
+	err = h.store.CreateCirculation(ctx, &types.Circulation{
 		BukuID:        payload.BukuID,
-		Peminjam:      payload.Peminjam,
+		Peminjam:      m.IdAnggota,
+		MemberID:      &m.ID,
 		TanggalPinjam: utils.ParseStringToFormatDate(payload.TanggalPinjam),
 		JatuhTempo:    utils.ParseStringToFormatDate(payload.JatuhTempo),
 		Denda:         utils.ParseStringToFloat(payload.Denda),
@@ -169,8 +184,15 @@ func (h *Handler) handleUpdateCirculation(w http.ResponseWriter, r *http.Request
 	if p.BukuID != "" {
 		c.BukuID = p.BukuID
 	}
-	if p.Peminjam != "" {
-		c.Peminjam = p.Peminjam
+	// the new borrower is checked same as the checkout
+	if p.Peminjam != "" && p.Peminjam != c.Peminjam {
+		m, status, err := h.findMember(ctx, p.Peminjam)
+		if err != nil {
+			utils.WriteJSONError(w, status, err)
+			return
+		}
+
+		c.Peminjam, c.MemberID = m.IdAnggota, &m.ID
 	}
 	if p.TanggalPinjam != "" {
 		c.TanggalPinjam = utils.ParseStringToFormatDate(p.TanggalPinjam)
@@ -185,6 +207,7 @@ func (h *Handler) handleUpdateCirculation(w http.ResponseWriter, r *http.Request
 	err = h.store.UpdateCirculation(ctx, circulationID, &types.Circulation{
 		BukuID:        c.BukuID,
 		Peminjam:      c.Peminjam,
+		MemberID:      c.MemberID,
 		TanggalPinjam: c.TanggalPinjam,
 		JatuhTempo:    c.JatuhTempo,
 		Denda:         c.Denda,
@@ -222,3 +245,28 @@ func (h *Handler) handleDeleteCirculation(w http.ResponseWriter, r *http.Request
 		Status:  http.StatusText(cok),
 	})
 }
+
+// peminjam is the id_anggota or the name of the member, the borrower must be one active member.
+// it fails closed: the unknown or the ambiguous name is rejected, and the store error is 500.
+func (h *Handler) findMember(ctx context.Context, peminjam string) (*types.Member, int, error) {
+	m, err := h.memberStore.GetMemberByIdAnggota(ctx, peminjam)
+	if errors.Is(err, types.ErrMemberNotFound) {
+		m, err = h.memberStore.GetMemberByNama(ctx, peminjam)
+	}
+
+	switch {
+	case errors.Is(err, types.ErrMemberNotFound):
+		return nil, http.StatusUnprocessableEntity, fmt.Errorf("peminjam: %v is not a member", peminjam)
+	case errors.Is(err, types.ErrAmbiguousMember):
+		return nil, http.StatusConflict, fmt.Errorf("peminjam: %w", err)
+	case err != nil:
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// the member which is suspended, expired, or graduated can't borrow
+	if !m.IsActive() {
+		return nil, http.StatusForbidden, fmt.Errorf("peminjam: %v is not an active member (status: %v)", peminjam, m.Status)
+	}
+
+	return m, 0, nil
+}
@@ -1,11 +1,14 @@
 package circulation
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
@@ -17,8 +20,12 @@ func TestHandlerCirculation(t *testing.T) {
 	jwt := &jwt.AuthJWT{}
 	mockCirculationStore := &types.MockCirculationStore{}
 	mockUserStore := &types.MockUserStore{}
+	// the borrower must be an active member
+	mockMemberStore := &lifecycleMemberStore{members: []*types.Member{
+		{ID: "a3c1e0f4-1d2b-4c5e-9f60-7a8b9c0d1e2f", IdAnggota: "ID001", Nama: "miko", Status: types.MemberStatusActive},
+	}}
 
-	h := NewHandler(jwt, mockCirculationStore, mockUserStore)
+	h := NewHandler(jwt, mockCirculationStore, mockUserStore, mockMemberStore)
 
 	t.Run("it should get circulations", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/circulations", nil)
@@ -94,3 +101,205 @@ func TestHandlerCirculation(t *testing.T) {
 		}
 	})
 }
+
+// the members is found by id_anggota or by name, same as the store. err is returned by every lookup.
+type lifecycleMemberStore struct {
+	types.MockMemberStore
+
+	members []*types.Member
+	err     error
+}
+
+func (s *lifecycleMemberStore) GetMemberByIdAnggota(ctx context.Context, idAnggota string) (*types.Member, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for _, m := range s.members {
+		if m.IdAnggota == idAnggota {
+			return m, nil
+		}
+	}
+
+	return nil, types.ErrMemberNotFound
+}
+
+func (s *lifecycleMemberStore) GetMemberByNama(ctx context.Context, nama string) (*types.Member, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var found *types.Member
+
+	for _, m := range s.members {
+		if m.Nama != nama {
+			continue
+		}
+
+		if found != nil {
+			return nil, types.ErrAmbiguousMember
+		}
+
+		found = m
+	}
+
+	if found == nil {
+		return nil, types.ErrMemberNotFound
+	}
+
+	return found, nil
+}
+
+// it record the saved circulation, GetCirculationByID return the circulation of ID001.
+type recordCirculationStore struct {
+	types.MockCirculationStore
+
+	saved *types.Circulation
+
+	// the member which has a loan, the old row has the name at peminjam
+	borrowerID string
+}
+
+func (s *recordCirculationStore) GetCirculationByMemberID(ctx context.Context, memberID string) (*types.Circulation, error) {
+	if memberID != s.borrowerID {
+		return s.MockCirculationStore.GetCirculationByMemberID(ctx, memberID)
+	}
+
+	return &types.Circulation{ID: "6e1f7a2b-9c3d-4e5f-8a6b-7c8d9e0f1a2b", Peminjam: "Budi Santoso", MemberID: &s.borrowerID}, nil
+}
+
+func (s *recordCirculationStore) GetCirculationByID(ctx context.Context, id string) (*types.Circulation, error) {
+	memberID := "a3c1e0f4-1d2b-4c5e-9f60-7a8b9c0d1e2f"
+	return &types.Circulation{ID: id, BukuID: "6918315b-dff4-8324-969f-e43cd434eb3e", Peminjam: "ID001", MemberID: &memberID}, nil
+}
+
+func (s *recordCirculationStore) CreateCirculation(ctx context.Context, c *types.Circulation) error {
+	s.saved = c
+	return nil
+}
+
+func (s *recordCirculationStore) UpdateCirculation(ctx context.Context, id string, c *types.Circulation) error {
+	s.saved = c
+	return nil
+}
+
+func TestHandlerCirculationMemberLifecycle(t *testing.T) {
+	past := time.Now().AddDate(0, 0, -1)
+	future := time.Now().AddDate(1, 0, 0)
+
+	members := []*types.Member{
+		{ID: "a3c1e0f4-1d2b-4c5e-9f60-7a8b9c0d1e2f", IdAnggota: "ID001", Nama: "Budi Santoso", Status: types.MemberStatusActive, BerlakuSampai: &future},
+		{ID: "b4d2f1a5-2e3c-4d6f-8a71-8b9c0d1e2f30", IdAnggota: "ID002", Nama: "Siti Aminah", Status: types.MemberStatusSuspended},
+		{ID: "c5e3a2b6-3f4d-4e7a-9b82-9c0d1e2f3a41", IdAnggota: "ID003", Nama: "Rina Wati", Status: types.MemberStatusExpired},
+		{ID: "d6f4b3c7-4a5e-4f8b-8c93-0d1e2f3a4b52", IdAnggota: "ID004", Nama: "Agus Salim", Status: types.MemberStatusGraduated},
+		{ID: "e7a5c4d8-5b6f-4a9c-9da4-1e2f3a4b5c63", IdAnggota: "ID005", Nama: "Dewi Lestari", Status: types.MemberStatusActive, BerlakuSampai: &past},
+		{ID: "f8b6d5e9-6c7a-4b0d-8eb5-2f3a4b5c6d74", IdAnggota: "ID006", Nama: "Andi", Status: types.MemberStatusActive},
+		{ID: "09c7e6fa-7d8b-4c1e-9fc6-3a4b5c6d7e85", IdAnggota: "ID007", Nama: "Andi", Status: types.MemberStatusActive},
+		{ID: "1ad8f7ab-8e9c-4d2f-8ad7-4b5c6d7e8f96", IdAnggota: "ID008", Nama: "anonim-ID008", Status: types.MemberStatusActive, BerlakuSampai: &future, AnonymizedAt: &past},
+	}
+
+	form := func(peminjam string) url.Values {
+		f := url.Values{}
+		f.Add("buku_id", "6918315b-dff4-8324-969f-e43cd434eb3e")
+		f.Add("peminjam", peminjam)
+		f.Add("tanggal_pinjam", "2026-10-19")
+		f.Add("jatuh_tempo", "2026-10-26")
+		f.Add("denda", "0")
+
+		return f
+	}
+
+	tests := []struct {
+		name       string
+		peminjam   string
+		borrowerID string
+		storeErr   error
+		want       int
+		idAnggota  string
+	}{
+		{name: "it should allow the active member by id_anggota", peminjam: "ID001", want: http.StatusCreated, idAnggota: "ID001"},
+		{name: "it should allow the active member by name and store the id_anggota", peminjam: "Budi Santoso", want: http.StatusCreated, idAnggota: "ID001"},
+		{name: "it should block the suspended member", peminjam: "ID002", want: http.StatusForbidden},
+		{name: "it should block the expired member", peminjam: "Rina Wati", want: http.StatusForbidden},
+		{name: "it should block the graduated member", peminjam: "ID004", want: http.StatusForbidden},
+		{name: "it should block the member which berlaku_sampai is passed", peminjam: "ID005", want: http.StatusForbidden},
+		{name: "it should block the anonymized member", peminjam: "ID008", want: http.StatusForbidden},
+		{name: "it should block the member which has the loan by name", peminjam: "ID001", borrowerID: members[0].ID, want: http.StatusBadRequest},
+		{name: "it should reject the name which isn't a member", peminjam: "Budi S.", want: http.StatusUnprocessableEntity},
+		{name: "it should reject the name of more than one member", peminjam: "Andi", want: http.StatusConflict},
+		{name: "it should fail with 500 when the member store is down", peminjam: "ID001", storeErr: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &recordCirculationStore{borrowerID: tt.borrowerID}
+			h := NewHandler(&jwt.AuthJWT{}, cs, &types.MockUserStore{}, &lifecycleMemberStore{members: members, err: tt.storeErr})
+
+			req := httptest.NewRequest(http.MethodPost, "/circulations", strings.NewReader(form(tt.peminjam).Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			w := httptest.NewRecorder()
+			r := mux.NewRouter()
+
+			r.HandleFunc("/circulations", h.handleCreateCirculation).Methods(http.MethodPost)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+
+			if tt.idAnggota == "" {
+				if cs.saved != nil {
+					t.Errorf("expected no circulation, got %+v", cs.saved)
+				}
+
+				return
+			}
+
+			if cs.saved == nil || cs.saved.Peminjam != tt.idAnggota || cs.saved.MemberID == nil || *cs.saved.MemberID != members[0].ID {
+				t.Errorf("expected the circulation of %s, got %+v", tt.idAnggota, cs.saved)
+			}
+		})
+	}
+
+	updates := []struct {
+		name     string
+		peminjam string
+		storeErr error
+		want     int
+	}{
+		{name: "it should block the update into the suspended member", peminjam: "ID002", want: http.StatusForbidden},
+		{name: "it should block the update into the graduated member", peminjam: "Agus Salim", want: http.StatusForbidden},
+		{name: "it should reject the update into the name which isn't a member", peminjam: "Budi S.", want: http.StatusUnprocessableEntity},
+		{name: "it should fail the update with 500 when the member store is down", peminjam: "ID006", storeErr: errors.New("connection refused"), want: http.StatusInternalServerError},
+		{name: "it should allow the update into the active member", peminjam: "ID006", want: http.StatusOK},
+		{name: "it should not check the same peminjam", peminjam: "ID001", storeErr: errors.New("connection refused"), want: http.StatusOK},
+	}
+
+	for _, tt := range updates {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &recordCirculationStore{}
+			h := NewHandler(&jwt.AuthJWT{}, cs, &types.MockUserStore{}, &lifecycleMemberStore{members: members, err: tt.storeErr})
+
+			f := url.Values{}
+			f.Add("peminjam", tt.peminjam)
+
+			req := httptest.NewRequest(http.MethodPatch, "/circulations/6918315b-dff4-8324-969f-e43cd434eb3e", strings.NewReader(f.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			w := httptest.NewRecorder()
+			r := mux.NewRouter()
+
+			r.HandleFunc("/circulations/{cID}", h.handleUpdateCirculation).Methods(http.MethodPatch)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+
+			if tt.want != http.StatusOK && cs.saved != nil {
+				t.Errorf("expected no update, got %+v", cs.saved)
+			}
+		})
+	}
+}
@@ -43,7 +43,7 @@ func (s *Store) GetCirculationsWithPagination(ctx context.Context, page int) ([]
 
 	limit := 10 // set the limit perPage
 
-	query := fmt.Sprintf(`SELECT c.id, c.buku_id, c.id_skl, c.peminjam, c.tanggal_pinjam, c.jatuh_tempo, c.denda, c.created_at, c.updated_at, b.id, b.judul_buku, COUNT(*) OVER() AS num_rows FROM circulations c INNER JOIN books b ON c.buku_id = b.id GROUP BY c.id, b.id ORDER BY %s %s LIMIT %d OFFSET %d`, sortByColumn, sortOrder, limit, (page-1)*limit)
+	query := fmt.Sprintf(`SELECT c.id, c.buku_id, c.id_skl, c.peminjam, c.member_id, c.tanggal_pinjam, c.jatuh_tempo, c.denda, c.created_at, c.updated_at, b.id, b.judul_buku, COUNT(*) OVER() AS num_rows FROM circulations c INNER JOIN books b ON c.buku_id = b.id GROUP BY c.id, b.id ORDER BY %s %s LIMIT %d OFFSET %d`, sortByColumn, sortOrder, limit, (page-1)*limit)
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -79,7 +79,7 @@ func (s *Store) GetCirculationsWithPagination(ctx context.Context, page int) ([]
 }
 
 func (s *Store) GetCirculationsForSearch(ctx context.Context) []*types.Circulation {
-	query := "SELECT c.id, c.buku_id, c.id_skl, c.peminjam, c.tanggal_pinjam, c.jatuh_tempo, c.denda, c.created_at, c.updated_at, b.id, b.judul_buku FROM circulations c INNER JOIN books b ON c.buku_id = b.id"
+	query := "SELECT c.id, c.buku_id, c.id_skl, c.peminjam, c.member_id, c.tanggal_pinjam, c.jatuh_tempo, c.denda, c.created_at, c.updated_at, b.id, b.judul_buku FROM circulations c INNER JOIN books b ON c.buku_id = b.id"
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -134,6 +134,7 @@ func (s *Store) GetCirculationByID(ctx context.Context, id string) (*types.Circu
 	c.buku_id,
 	c.id_skl,
 	c.peminjam,
+	c.member_id,
 	c.tanggal_pinjam,
 	c.jatuh_tempo,
 	c.denda,
@@ -164,12 +165,14 @@ func (s *Store) GetCirculationByID(ctx context.Context, id string) (*types.Circu
 	return c, nil
 }
 
-func (s *Store) GetCirculationByPeminjam(ctx context.Context, borrowerName string) (*types.Circulation, error) {
+// the loan of the member, it's matched by member_id so the old row which peminjam is the name is found too.
+func (s *Store) GetCirculationByMemberID(ctx context.Context, memberID string) (*types.Circulation, error) {
 	query := `SELECT
 	c.id,
 	c.buku_id,
 	c.id_skl,
 	c.peminjam,
+	c.member_id,
 	c.tanggal_pinjam,
 	c.jatuh_tempo,
 	c.denda,
@@ -179,7 +182,8 @@ func (s *Store) GetCirculationByPeminjam(ctx context.Context, borrowerName strin
 	b.judul_buku
 	FROM circulations c
 	INNER JOIN books b ON c.buku_id = b.id
-	WHERE c.peminjam = ?`
+	WHERE c.member_id = ?
+	LIMIT 1`
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -188,7 +192,7 @@ func (s *Store) GetCirculationByPeminjam(ctx context.Context, borrowerName strin
 
 	defer stmt.Close()
 
-	c, err := helper.ScanAndRetRowCirculation(ctx, stmt, borrowerName)
+	c, err := helper.ScanAndRetRowCirculation(ctx, stmt, memberID)
 	if err != nil {
 		return nil, err
 	}
@@ -252,14 +256,14 @@ func (s *Store) CreateCirculation(ctx context.Context, c *types.Circulation) err
 		c.IdSKL = IDSKL
 	}
 
-	stmtInsert, err := tx.Prepare("INSERT INTO circulations (id, buku_id, id_skl, peminjam, tanggal_pinjam, jatuh_tempo, denda) VALUES (?,?,?,?,?,?,?)")
+	stmtInsert, err := tx.Prepare("INSERT INTO circulations (id, buku_id, id_skl, peminjam, member_id, tanggal_pinjam, jatuh_tempo, denda) VALUES (?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return err
 	}
 
 	defer stmtInsert.Close()
 
-	_, err = stmtInsert.ExecContext(ctx, c.ID, c.BukuID, c.IdSKL, c.Peminjam, c.TanggalPinjam, c.JatuhTempo, c.Denda)
+	_, err = stmtInsert.ExecContext(ctx, c.ID, c.BukuID, c.IdSKL, c.Peminjam, c.MemberID, c.TanggalPinjam, c.JatuhTempo, c.Denda)
 	if err != nil {
 		return err
 	}
@@ -268,6 +272,7 @@ func (s *Store) CreateCirculation(ctx context.Context, c *types.Circulation) err
 		return err
 	}
 
+	s.delBookCache(ctx, c.BukuID)
 	return nil
 }
 
@@ -277,13 +282,17 @@ func (s *Store) UpdateCirculation(ctx context.Context, id string, c *types.Circu
 		return err
 	}
 
-	stmt, err := s.db.Prepare("UPDATE circulations SET buku_id = ?, peminjam = ?, tanggal_pinjam = ?, jatuh_tempo = ?, denda = ? WHERE id = ?")
+	stmt, err := s.db.Prepare("UPDATE circulations SET buku_id = ?, peminjam = ?, member_id = ?, tanggal_pinjam = ?, jatuh_tempo = ?, denda = ? WHERE id = ?")
 	if err != nil {
 		return err
 	}
 
 	s.rdb.Del(ctx, circKey)
-	_, err = stmt.ExecContext(ctx, c.BukuID, c.Peminjam, c.TanggalPinjam, c.JatuhTempo, c.Denda, id)
+	s.delBookCache(ctx, s.getBukuIDByCirculationID(ctx, id))
+
+	_, err = stmt.ExecContext(ctx, c.BukuID, c.Peminjam, c.MemberID, c.TanggalPinjam, c.JatuhTempo, c.Denda, id)
+
+	s.delBookCache(ctx, c.BukuID)
 	return err
 }
 
@@ -293,6 +302,8 @@ func (s *Store) DeleteCirculation(ctx context.Context, id string) error {
 		return err
 	}
 
+	bukuID := s.getBukuIDByCirculationID(ctx, id)
+
 	res, err := s.db.ExecContext(ctx, "DELETE FROM circulations WHERE id = ?", id)
 	if err != nil {
 		return err
@@ -308,5 +319,24 @@ func (s *Store) DeleteCirculation(ctx context.Context, id string) error {
 	}
 
 	s.rdb.Del(ctx, circKey)
+	s.delBookCache(ctx, bukuID)
 	return nil
 }
+
+func (s *Store) getBukuIDByCirculationID(ctx context.Context, id string) string {
+	var bukuID string
+
+	_ = s.db.QueryRowContext(ctx, "SELECT buku_id FROM circulations WHERE id = ?", id).Scan(&bukuID)
+
+	return bukuID
+}
+
+// the cached book has "tersedia" value, so it must be deleted when the circulation of the book was changed.
+func (s *Store) delBookCache(ctx context.Context, bukuID string) {
+	bookKey, err := utils.Redis2Key("book", bukuID)
+	if err != nil {
+		return
+	}
+
+	s.rdb.Del(ctx, bookKey)
+}
@@ -0,0 +1,32 @@
+package member
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/perpus_backend/types"
+)
+
+// expire the memberships which berlaku_sampai is passed, it's run at startup and then every interval.
+func StartExpiry(ctx context.Context, s types.MemberStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			expired, err := s.ExpireMembers(ctx)
+			if err != nil {
+				log.Printf("member expiry: %v", err)
+			} else if expired > 0 {
+				log.Printf("member expiry: %d members expired", expired)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -1,33 +1,44 @@
 package member
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
+	"github.com/perpus_backend/pkg/upload"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
+	"github.com/bytedance/sonic"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/rs/xid"
 )
 
 type Handler struct {
 	store     types.MemberStore
 	userStore types.UserStore
 
-	jwt *jwt.AuthJWT
+	jwt     *jwt.AuthJWT
+	indexer *search.Indexer
 }
 
-func NewHandler(jwt *jwt.AuthJWT, s types.MemberStore, us types.UserStore) *Handler {
+func NewHandler(jwt *jwt.AuthJWT, s types.MemberStore, us types.UserStore, indexer *search.Indexer) *Handler {
 	return &Handler{
 		store:     s,
 		userStore: us,
 		jwt:       jwt,
+		indexer:   indexer,
 	}
 }
 
@@ -40,15 +51,27 @@ const (
 )
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/members", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetMembers, "admin", "staff"))).Methods(http.MethodGet)
+	members := audit.Target{Resource: "members", IDVar: "memberID", Load: audit.Load(h.store.GetMemberByID)}
 
-	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetMemberByID, "admin", "staff"))).Methods(http.MethodGet)
+	r.HandleFunc("/members", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetMembers, types.PermMembersRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/members", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleCreateMember, "admin", "staff"))).Methods(http.MethodPost)
+	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(h.handleGetMemberByID, types.PermMembersRead))).Methods(http.MethodGet)
 
-	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleUpdateMember, "admin", "staff"))).Methods(http.MethodPut)
+	r.HandleFunc("/members", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleCreateMember, "members.create", members), types.PermMembersWrite))).Methods(http.MethodPost)
 
-	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteMember, "admin", "staff"))).Methods(http.MethodDelete)
+	// the bulk operations of the academic year, ex: graduate class XII, and renew all for the new year
+	r.HandleFunc("/members/graduate", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleGraduateMembers, "members.graduate", members), types.PermMembersWrite))).Methods(http.MethodPost)
+
+	r.HandleFunc("/members/renew", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleRenewMembers, "members.renew", members), types.PermMembersWrite))).Methods(http.MethodPost)
+
+	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleUpdateMember, "members.update", members), types.PermMembersWrite))).Methods(http.MethodPut)
+
+	r.HandleFunc("/members/{memberID}", h.jwt.AuthWithAPIKeyOrJWT(h.jwt.RequirePermission(audit.Log(h.handleDeleteMember, "members.delete", members), types.PermMembersWrite))).Methods(http.MethodDelete)
+
+	// the data subject request, only by the admin
+	r.HandleFunc("/members/{memberID}/export", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleExportMember, "members.export", members), types.PermMembersPrivacy))).Methods(http.MethodGet)
+
+	r.HandleFunc("/members/{memberID}/anonymize", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleAnonymizeMember, "members.anonymize", members), types.PermMembersPrivacy))).Methods(http.MethodPost)
 }
 
 func (h *Handler) handleGetMembers(w http.ResponseWriter, r *http.Request) {
@@ -98,8 +121,7 @@ func (h *Handler) handleCreateMember(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
 
-		fileName, avatarPath, extFile string
-		sizeFile                      int64
+		fileName string
 	)
 
 	r.Body = http.MaxBytesReader(w, r.Body, size1MB)
@@ -110,10 +132,12 @@ func (h *Handler) handleCreateMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := types.SetPayloadMember{
-		Nama:         r.FormValue("nama"),
-		JenisKelamin: r.FormValue("jenis_kelamin"),
-		Kelas:        r.FormValue("kelas"),
-		NoTelepon:    r.FormValue("no_telepon"),
+		Nama:          r.FormValue("nama"),
+		JenisKelamin:  r.FormValue("jenis_kelamin"),
+		Kelas:         r.FormValue("kelas"),
+		NoTelepon:     r.FormValue("no_telepon"),
+		TanggalLahir:  r.FormValue("tanggal_lahir"),
+		BerlakuSampai: r.FormValue("berlaku_sampai"),
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
@@ -140,35 +164,41 @@ func (h *Handler) handleCreateMember(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		defer file.Close()
 
-		sizeFile = header.Size
-		extFile = filepath.Ext(header.Filename)
-
-		if extFile != ".png" && extFile != ".jpeg" && extFile != ".jpg" {
-			utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("only support jpg, jpeg, and png"))
+		avatar, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
 			return
 		}
 
-		if sizeFile > size1MB {
-			utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("serve file under 1mb"))
+		fileName = xid.New().String() + avatar.Ext
+
+		if err := avatar.Save(dirAvatarPath + fileName); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
-
-		fileName = header.Filename
-		avatarPath = dirAvatarPath + fileName
-
-		dst, _ := os.Create(avatarPath)
-		defer dst.Close()
-
-		io.Copy(dst, file)
 	}
 
-	err = h.store.CreateMember(ctx, &types.Member{
+	m := &types.Member{
 		Nama:          payload.Nama,
 		JenisKelamin:  payload.JenisKelamin,
 		Kelas:         payload.Kelas,
 		NoTelepon:     payload.NoTelepon,
 		ProfilAnggota: fileName,
-	})
+	}
+
+	// without tanggal_lahir the member is treated as minor, so the name is encrypted
+	if payload.TanggalLahir != "" {
+		tanggalLahir := utils.ParseStringToFormatDate(payload.TanggalLahir)
+		m.TanggalLahir = &tanggalLahir
+	}
+
+	// without berlaku_sampai the membership has no end
+	if payload.BerlakuSampai != "" {
+		berlakuSampai := utils.ParseStringToFormatDate(payload.BerlakuSampai)
+		m.BerlakuSampai = &berlakuSampai
+	}
+
+	err = h.store.CreateMember(ctx, m)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
@@ -187,8 +217,7 @@ func (h *Handler) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
 
-		fileName, avatarPath, extFile string
-		sizeFile                      int64
+		fileName string
 	)
 
 	if r.Method != http.MethodPut {
@@ -209,10 +238,13 @@ func (h *Handler) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := types.SetPayloadUpdateMember{
-		Nama:         r.FormValue("nama"),
-		JenisKelamin: r.FormValue("jenis_kelamin"),
-		Kelas:        r.FormValue("kelas"),
-		NoTelepon:    r.FormValue("no_telepon"),
+		Nama:          r.FormValue("nama"),
+		JenisKelamin:  r.FormValue("jenis_kelamin"),
+		Kelas:         r.FormValue("kelas"),
+		NoTelepon:     r.FormValue("no_telepon"),
+		TanggalLahir:  r.FormValue("tanggal_lahir"),
+		BerlakuSampai: r.FormValue("berlaku_sampai"),
+		Status:        r.FormValue("status"),
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
@@ -239,6 +271,18 @@ func (h *Handler) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 	if payload.NoTelepon != "" {
 		m.NoTelepon = payload.NoTelepon
 	}
+	if payload.TanggalLahir != "" {
+		tanggalLahir := utils.ParseStringToFormatDate(payload.TanggalLahir)
+		m.TanggalLahir = &tanggalLahir
+	}
+	if payload.BerlakuSampai != "" {
+		berlakuSampai := utils.ParseStringToFormatDate(payload.BerlakuSampai)
+		m.BerlakuSampai = &berlakuSampai
+	}
+	// the suspension and the reactivation of a member
+	if payload.Status != "" {
+		m.Status = payload.Status
+	}
 
 	file, header, err := r.FormFile("profil")
 	if err == http.ErrMissingFile {
@@ -248,35 +292,24 @@ func (h *Handler) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		defer file.Close()
 
-		sizeFile = header.Size
-		extFile = filepath.Ext(header.Filename)
-
-		if extFile != ".png" && extFile != ".jpg" && extFile != ".jpeg" {
-			utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only supports jpg, jpeg, and png"))
+		avatar, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
 			return
 		}
 
-		if sizeFile > size1MB {
-			utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("only serve file under 1mb"))
+		fileName = xid.New().String() + avatar.Ext
+
+		if err := avatar.Save(dirAvatarPath + fileName); err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
 
 		avatarPathOld := dirAvatarPath + m.ProfilAnggota
-		info, err := os.Stat(avatarPathOld)
 
-		if err == nil {
-			if !info.IsDir() {
-				os.Remove(avatarPathOld)
-			}
+		if info, err := os.Stat(avatarPathOld); err == nil && !info.IsDir() {
+			os.Remove(avatarPathOld)
 		}
-
-		fileName = header.Filename
-		avatarPath = dirAvatarPath + fileName
-
-		dst, _ := os.Create(avatarPath)
-		defer dst.Close()
-
-		io.Copy(dst, file)
 	}
 
 	err = h.store.UpdateMember(ctx, memberID, &types.Member{
@@ -285,6 +318,9 @@ func (h *Handler) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 		Kelas:         m.Kelas,
 		NoTelepon:     m.NoTelepon,
 		ProfilAnggota: fileName,
+		TanggalLahir:  m.TanggalLahir,
+		Status:        m.Status,
+		BerlakuSampai: m.BerlakuSampai,
 	})
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
@@ -334,3 +370,226 @@ func (h *Handler) handleDeleteMember(w http.ResponseWriter, r *http.Request) {
 		Status:  http.StatusText(cok),
 	})
 }
+
+func (h *Handler) handleGraduateMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	payload := types.SetPayloadGraduateMembers{
+		Kelas: r.FormValue("kelas"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	updated, err := h.store.GraduateMembers(ctx, payload.Kelas)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Data:    map[string]int64{"updated": updated},
+		Message: fmt.Sprintf("%d Members Graduated!", updated),
+		Status:  http.StatusText(cok),
+	})
+}
+
+func (h *Handler) handleRenewMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	payload := types.SetPayloadRenewMembers{
+		Kelas:         r.FormValue("kelas"),
+		BerlakuSampai: r.FormValue("berlaku_sampai"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	berlakuSampai := utils.ParseStringToFormatDate(payload.BerlakuSampai)
+
+	if berlakuSampai.Format(time.DateOnly) < time.Now().Format(time.DateOnly) {
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("berlaku_sampai: %v is in the past", payload.BerlakuSampai))
+		return
+	}
+
+	updated, err := h.store.RenewMembers(ctx, payload.Kelas, berlakuSampai)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Data:    map[string]int64{"updated": updated},
+		Message: fmt.Sprintf("%d Members Renewed!", updated),
+		Status:  http.StatusText(cok),
+	})
+}
+
+// download everything about the member as zip: profile, loans, fines, and the avatar file.
+func (h *Handler) handleExportMember(w http.ResponseWriter, r *http.Request) {
+	memberID := mux.Vars(r)["memberID"]
+	ctx := r.Context()
+
+	if err := uuid.Validate(memberID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := h.store.GetMemberByID(ctx, memberID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	loans, err := h.store.GetMemberLoans(ctx, m)
+	if errors.Is(err, types.ErrUnlinkedLoans) {
+		utils.WriteJSONError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fines := &types.MemberFines{Items: make([]*types.Circulation, 0)}
+
+	for _, c := range loans {
+		if c.Denda > 0 {
+			fines.Items = append(fines.Items, c)
+			fines.Total += c.Denda
+		}
+	}
+
+	archive, err := exportArchive(m, loans, fines)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="member-%s.zip"`, m.IdAnggota))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(cok)
+
+	w.Write(archive)
+}
+
+// scrub the personal data of the member which is left, the loans is kept for the statistics.
+func (h *Handler) handleAnonymizeMember(w http.ResponseWriter, r *http.Request) {
+	memberID := mux.Vars(r)["memberID"]
+	ctx := r.Context()
+
+	if err := uuid.Validate(memberID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := h.store.GetMemberByID(ctx, memberID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if m.AnonymizedAt != nil {
+		utils.WriteJSONError(w, http.StatusConflict, fmt.Errorf("member is already anonymized"))
+		return
+	}
+
+	circulationIDs, err := h.store.AnonymizeMember(ctx, m)
+	if errors.Is(err, types.ErrUnlinkedLoans) {
+		utils.WriteJSONError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if m.ProfilAnggota != "" && m.ProfilAnggota != "-" {
+		avatarPath := dirAvatarPath + filepath.Base(m.ProfilAnggota)
+
+		if info, err := os.Stat(avatarPath); err == nil && !info.IsDir() {
+			os.Remove(avatarPath)
+		}
+	}
+
+	// the search copies, the anonymized one is indexed again at the next sync
+	if err := h.indexer.Purge(ctx, "members", []string{m.ID}); err != nil {
+		log.Printf("purge member %s from search: %v", m.ID, err)
+	}
+
+	if err := h.indexer.Purge(ctx, "circulations", circulationIDs); err != nil {
+		log.Printf("purge circulations of member %s from search: %v", m.ID, err)
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Member Anonymized!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+func exportArchive(m *types.Member, loans []*types.Circulation, fines *types.MemberFines) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", m},
+		{"loans.json", loans},
+		{"fines.json", fines},
+	} {
+		data, err := sonic.ConfigStd.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := f.Write(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.ProfilAnggota != "" && m.ProfilAnggota != "-" {
+		name := filepath.Base(m.ProfilAnggota)
+
+		if avatar, err := os.ReadFile(dirAvatarPath + name); err == nil {
+			f, err := zw.Create("avatar/" + name)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := f.Write(avatar); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
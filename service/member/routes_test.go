@@ -1,13 +1,25 @@
 package member
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
 	"github.com/perpus_backend/types"
 
 	"github.com/gorilla/mux"
@@ -18,7 +30,10 @@ func TestHandlerMember(t *testing.T) {
 	mockMemberStore := &types.MockMemberStore{}
 	mockUserStore := &types.MockUserStore{}
 
-	h := NewHandler(jwt, mockMemberStore, mockUserStore)
+	resources := search.NewResources(mockUserStore, types.MockRoleStore{}, mockMemberStore, types.MockBookStore{}, types.MockCirculationStore{})
+	indexer := search.NewIndexer(search.NewMemoryIndex(), resources, time.Minute)
+
+	h := NewHandler(jwt, mockMemberStore, mockUserStore, indexer)
 
 	t.Run("it should be get members", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/members", nil)
@@ -59,6 +74,8 @@ func TestHandlerMember(t *testing.T) {
 	})
 
 	t.Run("it should make member", func(t *testing.T) {
+		t.Chdir(t.TempDir()) // the avatar is saved at ./assets
+
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
@@ -79,7 +96,10 @@ func TestHandlerMember(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		file.Write([]byte("fake img content"))
+		// the content is checked, so it must be real image
+		if err := jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 1, 1)), nil); err != nil {
+			t.Fatal(err)
+		}
 
 		writer.Close()
 
@@ -98,4 +118,289 @@ func TestHandlerMember(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
 		}
 	})
+	t.Run("it should fail export member, because the member id is invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/members/not-uuid/export", nil)
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/{memberID}/export", h.handleExportMember).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail anonymize member, because the member id is invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/members/not-uuid/anonymize", nil)
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/{memberID}/anonymize", h.handleAnonymizeMember).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should graduate members of class", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("kelas", "XII")
+
+		req := httptest.NewRequest(http.MethodPost, "/members/graduate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/graduate", h.handleGraduateMembers).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("it should fail graduate members, because the kelas is empty", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/members/graduate", nil)
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/graduate", h.handleGraduateMembers).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("it should renew members for new academic year", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("berlaku_sampai", time.Now().AddDate(1, 0, 0).Format(time.DateOnly))
+
+		req := httptest.NewRequest(http.MethodPost, "/members/renew", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/renew", h.handleRenewMembers).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("it should fail renew members, because the berlaku_sampai is past", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("berlaku_sampai", "2020-06-30")
+
+		req := httptest.NewRequest(http.MethodPost, "/members/renew", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/members/renew", h.handleRenewMembers).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+}
+
+// the member with the loans, it record the anonymized member.
+type privacyMemberStore struct {
+	types.MockMemberStore
+
+	member     *types.Member
+	loans      []*types.Circulation
+	loansErr   error
+	anonymized []*types.Member
+}
+
+func (s *privacyMemberStore) GetMemberByID(ctx context.Context, id string) (*types.Member, error) {
+	if id != s.member.ID {
+		return nil, types.ErrMemberNotFound
+	}
+
+	m := *s.member
+	return &m, nil
+}
+
+func (s *privacyMemberStore) GetMemberLoans(ctx context.Context, m *types.Member) ([]*types.Circulation, error) {
+	return s.loans, s.loansErr
+}
+
+func (s *privacyMemberStore) AnonymizeMember(ctx context.Context, m *types.Member) ([]string, error) {
+	if s.loansErr != nil {
+		return nil, s.loansErr
+	}
+
+	s.anonymized = append(s.anonymized, m)
+
+	ids := make([]string, 0, len(s.loans))
+	for _, c := range s.loans {
+		ids = append(ids, c.ID)
+	}
+
+	return ids, nil
+}
+
+func TestHandlerMemberPrivacy(t *testing.T) {
+	t.Chdir(t.TempDir()) // the avatar is read from ./assets
+
+	if err := os.MkdirAll(dirAvatarPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	avatar := []byte("\xff\xd8\xff\xe0avatar")
+	if err := os.WriteFile(dirAvatarPath+"budi.jpg", avatar, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	memberID := "6918315b-dff4-8324-969f-e43cd434eb3e"
+	otherID := "0b6f2f52-6f3c-4c43-9f0b-3b1f0e6f9d11"
+
+	newStore := func() *privacyMemberStore {
+		return &privacyMemberStore{
+			member: &types.Member{ID: memberID, IdAnggota: "ID001", Nama: "Budi Santoso", NoTelepon: "08123456789", ProfilAnggota: "budi.jpg", Status: types.MemberStatusActive},
+			loans: []*types.Circulation{
+				{ID: "c-1", IdSKL: "SKL001", Peminjam: "ID001", MemberID: &memberID, Denda: 0},
+				{ID: "c-2", IdSKL: "SKL002", Peminjam: "ID001", MemberID: &memberID, Denda: 5000},
+				{ID: "c-3", IdSKL: "SKL003", Peminjam: "ID001", MemberID: &memberID, Denda: 2500},
+			},
+		}
+	}
+
+	serve := func(h *Handler, method, path string, handler http.HandlerFunc, route string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc(route, handler).Methods(method)
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+
+		return w
+	}
+
+	t.Run("it should export the profile, loans, fines, and avatar", func(t *testing.T) {
+		h := NewHandler(&jwt.AuthJWT{}, newStore(), &types.MockUserStore{}, nil)
+
+		w := serve(h, http.MethodGet, "/members/"+memberID+"/export", h.handleExportMember, "/members/{memberID}/export")
+		if w.Code != cok {
+			t.Fatalf("expected status code %d, got %d: %s", cok, w.Code, w.Body)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := make(map[string][]byte)
+
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files[f.Name], _ = io.ReadAll(rc)
+			rc.Close()
+		}
+
+		var profile types.Member
+		if err := json.Unmarshal(files["profile.json"], &profile); err != nil || profile.ID != memberID || profile.NoTelepon != "08123456789" {
+			t.Errorf("expected the profile of the member, got %s", files["profile.json"])
+		}
+
+		var loans []*types.Circulation
+		if err := json.Unmarshal(files["loans.json"], &loans); err != nil || len(loans) != 3 {
+			t.Errorf("expected 3 loans, got %s", files["loans.json"])
+		}
+
+		var fines types.MemberFines
+		if err := json.Unmarshal(files["fines.json"], &fines); err != nil || fines.Total != 7500 || len(fines.Items) != 2 {
+			t.Errorf("expected 2 fines of 7500, got %s", files["fines.json"])
+		}
+
+		if !bytes.Equal(files["avatar/budi.jpg"], avatar) {
+			t.Errorf("expected the avatar file, got %q", files["avatar/budi.jpg"])
+		}
+	})
+
+	t.Run("it should refuse export when the loans by name isn't linked", func(t *testing.T) {
+		store := newStore()
+		store.loansErr = types.ErrUnlinkedLoans
+
+		h := NewHandler(&jwt.AuthJWT{}, store, &types.MockUserStore{}, nil)
+
+		w := serve(h, http.MethodGet, "/members/"+memberID+"/export", h.handleExportMember, "/members/{memberID}/export")
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("it should anonymize the member and purge the search copies", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore()
+
+		index := search.NewMemoryIndex()
+		index.AddDocuments(ctx, "members", "id", []map[string]any{{"id": memberID, "nama": "B. S."}, {"id": otherID, "nama": "S. A."}})
+		index.AddDocuments(ctx, "circulations", "id", []map[string]any{{"id": "c-1"}, {"id": "c-2"}, {"id": "c-3"}, {"id": "c-4"}})
+
+		resources := search.NewResources(&types.MockUserStore{}, types.MockRoleStore{}, store, types.MockBookStore{}, types.MockCirculationStore{})
+		h := NewHandler(&jwt.AuthJWT{}, store, &types.MockUserStore{}, search.NewIndexer(index, resources, time.Minute))
+
+		w := serve(h, http.MethodPost, "/members/"+memberID+"/anonymize", h.handleAnonymizeMember, "/members/{memberID}/anonymize")
+		if w.Code != cok {
+			t.Fatalf("expected status code %d, got %d: %s", cok, w.Code, w.Body)
+		}
+
+		if len(store.anonymized) != 1 || store.anonymized[0].ID != memberID {
+			t.Fatalf("expected AnonymizeMember is called with the member, got %v", store.anonymized)
+		}
+
+		if ids, _ := index.DocumentIDs(ctx, "members"); !slices.Equal(ids, []string{otherID}) {
+			t.Errorf("expected only the other member is left at the index, got %v", ids)
+		}
+
+		if ids, _ := index.DocumentIDs(ctx, "circulations"); !slices.Equal(ids, []string{"c-4"}) {
+			t.Errorf("expected only the other circulation is left at the index, got %v", ids)
+		}
+
+		if _, err := os.Stat(dirAvatarPath + "budi.jpg"); !os.IsNotExist(err) {
+			t.Errorf("expected the avatar is removed, got %v", err)
+		}
+	})
+
+	t.Run("it should refuse anonymize the member which is already anonymized", func(t *testing.T) {
+		store := newStore()
+		anonymizedAt := time.Now()
+		store.member.AnonymizedAt = &anonymizedAt
+
+		h := NewHandler(&jwt.AuthJWT{}, store, &types.MockUserStore{}, nil)
+
+		w := serve(h, http.MethodPost, "/members/"+memberID+"/anonymize", h.handleAnonymizeMember, "/members/{memberID}/anonymize")
+		if w.Code != http.StatusConflict || len(store.anonymized) != 0 {
+			t.Errorf("expected status code %d without anonymize, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("it should refuse anonymize when the loans by name isn't linked", func(t *testing.T) {
+		store := newStore()
+		store.loansErr = types.ErrUnlinkedLoans
+
+		h := NewHandler(&jwt.AuthJWT{}, store, &types.MockUserStore{}, nil)
+
+		w := serve(h, http.MethodPost, "/members/"+memberID+"/anonymize", h.handleAnonymizeMember, "/members/{memberID}/anonymize")
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
 }
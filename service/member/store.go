@@ -3,11 +3,15 @@ package member
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/perpus_backend/helper"
+	"github.com/perpus_backend/pkg/encrypt"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
@@ -16,6 +20,11 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// *sql.DB or *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Store struct {
 	db  *sql.DB
 	rdb *redis.Client
@@ -44,7 +53,7 @@ func (s *Store) GetMembersWithPagination(ctx context.Context, page int) ([]*type
 
 	limitPage := 10 // set the limit perPage
 
-	query := fmt.Sprintf("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.created_at, m.updated_at, COUNT(*) OVER() AS num_rows FROM members m GROUP BY m.id ORDER BY %s %s LIMIT %d OFFSET %d", sortByColumn, sortOrder, limitPage, (page-1)*limitPage)
+	query := fmt.Sprintf("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at, COUNT(*) OVER() AS num_rows FROM members m GROUP BY m.id ORDER BY %s %s LIMIT %d OFFSET %d", sortByColumn, sortOrder, limitPage, (page-1)*limitPage)
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -71,6 +80,10 @@ func (s *Store) GetMembersWithPagination(ctx context.Context, page int) ([]*type
 			return nil, 0, err
 		}
 
+		if err := openMember(m); err != nil {
+			return nil, 0, err
+		}
+
 		lastPage = int64(math.Ceil(float64(count) / float64(limitPage)))
 
 		members = append(members, m)
@@ -79,8 +92,9 @@ func (s *Store) GetMembersWithPagination(ctx context.Context, page int) ([]*type
 	return members, lastPage, nil
 }
 
+// the search index only has the redacted copy, see redactMember.
 func (s *Store) GetMembersForSearch(ctx context.Context) []*types.Member {
-	query := "SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.created_at, m.updated_at FROM members m"
+	query := "SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at FROM members m"
 
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
@@ -104,7 +118,11 @@ func (s *Store) GetMembersForSearch(ctx context.Context) []*types.Member {
 			return nil
 		}
 
-		members = append(members, m)
+		if err := openMember(m); err != nil {
+			return nil
+		}
+
+		members = append(members, redactMember(m))
 	}
 
 	return members
@@ -120,7 +138,7 @@ func (s *Store) GetMemberByID(ctx context.Context, id string) (*types.Member, er
 	if err == nil {
 		member := new(types.Member)
 
-		if err := sonic.Unmarshal([]byte(res), member); err == nil {
+		if err := sonic.Unmarshal([]byte(res), member); err == nil && openMember(member) == nil {
 			return member, nil
 		}
 
@@ -129,7 +147,7 @@ func (s *Store) GetMemberByID(ctx context.Context, id string) (*types.Member, er
 		return nil, err
 	}
 
-	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.created_at, m.updated_at FROM members m WHERE m.id = ?")
+	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at FROM members m WHERE m.id = ?")
 	if err != nil {
 		return nil, err
 	}
@@ -141,42 +159,102 @@ func (s *Store) GetMemberByID(ctx context.Context, id string) (*types.Member, er
 		return nil, err
 	}
 
+	// the cache has the encrypted columns as it is at db
 	if data, err := sonic.Marshal(m); err == nil {
 		_ = s.rdb.SetEx(ctx, memberKey, data, 5*time.Minute)
 	}
 
+	if err := openMember(m); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
+// the column is encrypted, so it's found by the blind index. the name isn't unique, so the second match is an error.
 func (s *Store) GetMemberByNama(ctx context.Context, nama string) (*types.Member, error) {
-	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.created_at, m.updated_at FROM members m WHERE m.nama = ?")
+	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at FROM members m WHERE m.nama_bidx = ? LIMIT 2")
 	if err != nil {
 		return nil, err
 	}
 
 	defer stmt.Close()
 
-	m, err := helper.ScanAndRetRowMember(ctx, stmt, nama)
+	rows, err := stmt.QueryContext(ctx, encrypt.BlindIndex(fieldNama, normalizeNama(nama)))
 	if err != nil {
 		return nil, err
 	}
 
-	return m, nil
+	defer rows.Close()
+
+	members := make([]*types.Member, 0, 2)
+
+	for rows.Next() {
+		m, err := helper.ScanRowsMember(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(members) {
+	case 0:
+		return nil, types.ErrMemberNotFound
+	case 1:
+	default:
+		return nil, types.ErrAmbiguousMember
+	}
+
+	if err := openMember(members[0]); err != nil {
+		return nil, err
+	}
+
+	return members[0], nil
 }
 
 func (s *Store) GetMemberByNoTelepon(ctx context.Context, no_phone string) (*types.Member, error) {
-	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.created_at, m.updated_at FROM members m WHERE m.no_telepon = ?")
+	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at FROM members m WHERE m.no_telepon_bidx = ?")
 	if err != nil {
 		return nil, err
 	}
 
 	defer stmt.Close()
 
-	m, err := helper.ScanAndRetRowMember(ctx, stmt, no_phone)
+	m, err := helper.ScanAndRetRowMember(ctx, stmt, encrypt.BlindIndex(fieldNoTelepon, normalizeNoTelepon(no_phone)))
 	if err != nil {
 		return nil, err
 	}
 
+	if err := openMember(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// id_anggota is plaintext, ex: "ID001".
+func (s *Store) GetMemberByIdAnggota(ctx context.Context, idAnggota string) (*types.Member, error) {
+	stmt, err := s.db.Prepare("SELECT m.id, m.id_anggota, m.nama, m.jenis_kelamin, m.kelas, m.no_telepon, m.profil_anggota, m.tanggal_lahir, m.anonymized_at, m.status, m.berlaku_sampai, m.created_at, m.updated_at FROM members m WHERE m.id_anggota = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	m, err := helper.ScanAndRetRowMember(ctx, stmt, idAnggota)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := openMember(m); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
@@ -235,14 +313,23 @@ func (s *Store) CreateMember(ctx context.Context, m *types.Member) error {
 		m.IdAnggota = IDMember
 	}
 
-	stmtInsert, err := tx.Prepare("INSERT INTO members (id, id_anggota, nama, jenis_kelamin, kelas, no_telepon, profil_anggota) VALUES (?,?,?,?,?,?,?)")
+	if m.Status == "" {
+		m.Status = types.MemberStatusActive
+	}
+
+	sm, err := sealMember(m)
+	if err != nil {
+		return err
+	}
+
+	stmtInsert, err := tx.Prepare("INSERT INTO members (id, id_anggota, nama, nama_bidx, jenis_kelamin, kelas, no_telepon, no_telepon_bidx, profil_anggota, tanggal_lahir, status, berlaku_sampai) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")
 	if err != nil {
 		return err
 	}
 
 	defer stmtInsert.Close()
 
-	_, err = stmtInsert.ExecContext(ctx, m.ID, m.IdAnggota, m.Nama, m.JenisKelamin, m.Kelas, m.NoTelepon, m.ProfilAnggota)
+	_, err = stmtInsert.ExecContext(ctx, m.ID, m.IdAnggota, sm.nama, sm.namaBidx, m.JenisKelamin, m.Kelas, sm.noTelepon, sm.noTeleponBidx, m.ProfilAnggota, m.TanggalLahir, m.Status, m.BerlakuSampai)
 	if err != nil {
 		return err
 	}
@@ -260,7 +347,15 @@ func (s *Store) UpdateMember(ctx context.Context, id string, m *types.Member) er
 		return err
 	}
 
-	stmt, err := s.db.Prepare("UPDATE members SET nama = ?, jenis_kelamin = ?, kelas = ?, no_telepon = ?, profil_anggota = ? WHERE id = ?")
+	// the id is the additional data of the encrypted columns
+	m.ID = id
+
+	sm, err := sealMember(m)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := s.db.Prepare("UPDATE members SET nama = ?, nama_bidx = ?, jenis_kelamin = ?, kelas = ?, no_telepon = ?, no_telepon_bidx = ?, profil_anggota = ?, tanggal_lahir = ?, status = ?, berlaku_sampai = ? WHERE id = ?")
 	if err != nil {
 		return err
 	}
@@ -268,7 +363,7 @@ func (s *Store) UpdateMember(ctx context.Context, id string, m *types.Member) er
 	defer stmt.Close()
 
 	s.rdb.Del(ctx, memberKey)
-	_, err = stmt.ExecContext(ctx, m.Nama, m.JenisKelamin, m.Kelas, m.NoTelepon, m.ProfilAnggota, id)
+	_, err = stmt.ExecContext(ctx, sm.nama, sm.namaBidx, m.JenisKelamin, m.Kelas, sm.noTelepon, sm.noTeleponBidx, m.ProfilAnggota, m.TanggalLahir, m.Status, m.BerlakuSampai, id)
 	return err
 }
 
@@ -289,9 +384,443 @@ func (s *Store) DeleteMember(ctx context.Context, id string) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("member not found")
+		return types.ErrMemberNotFound
 	}
 
 	s.rdb.Del(ctx, memberKey)
 	return nil
 }
+
+// encrypt the rows which is plaintext or sealed by the old key, and fill the blind indexes.
+// it's run at startup, so the rotated key and the rows before the encryption is handled.
+func (s *Store) RekeyMembers(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT m.id, m.nama, m.nama_bidx, m.no_telepon, m.no_telepon_bidx, m.tanggal_lahir FROM members m")
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	type row struct {
+		id, nama, noTelepon     string
+		namaBidx, noTeleponBidx sql.NullString
+		tanggalLahir            *time.Time
+	}
+
+	stale := make([]row, 0)
+
+	for rows.Next() {
+		var r row
+
+		if err := rows.Scan(&r.id, &r.nama, &r.namaBidx, &r.noTelepon, &r.noTeleponBidx, &r.tanggalLahir); err != nil {
+			return 0, err
+		}
+
+		m := &types.Member{ID: r.id, Nama: r.nama, NoTelepon: r.noTelepon, TanggalLahir: r.tanggalLahir}
+		if err := openMember(m); err != nil {
+			log.Printf("rekey member %s: %v", r.id, err)
+			continue
+		}
+
+		if needsRekey(m, r.nama, r.namaBidx.String, r.noTelepon, r.noTeleponBidx.String) {
+			r.nama, r.noTelepon = m.Nama, m.NoTelepon
+			stale = append(stale, r)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var updated int64
+
+	for _, r := range stale {
+		sm, err := sealMember(&types.Member{ID: r.id, Nama: r.nama, NoTelepon: r.noTelepon, TanggalLahir: r.tanggalLahir})
+		if err != nil {
+			return updated, err
+		}
+
+		_, err = s.db.ExecContext(ctx, "UPDATE members SET nama = ?, nama_bidx = ?, no_telepon = ?, no_telepon_bidx = ? WHERE id = ?", sm.nama, sm.namaBidx, sm.noTelepon, sm.noTeleponBidx, r.id)
+		if err != nil {
+			return updated, err
+		}
+
+		if memberKey, err := utils.Redis2Key("member", r.id); err == nil {
+			s.rdb.Del(ctx, memberKey)
+		}
+
+		updated++
+	}
+
+	return updated, nil
+}
+
+// link the circulations before member_id to the member, and replace the name at peminjam with id_anggota.
+// the name which is matched by more than one member is skipped, it's left to the staff. it's run after RekeyMembers, so the blind index is filled.
+func (s *Store) LinkCirculations(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT c.id, c.peminjam FROM circulations c WHERE c.member_id IS NULL")
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	unlinked := make(map[string]string)
+
+	for rows.Next() {
+		var id, peminjam string
+
+		if err := rows.Scan(&id, &peminjam); err != nil {
+			return 0, err
+		}
+
+		unlinked[id] = peminjam
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var linked int64
+
+	for id, peminjam := range unlinked {
+		m, err := s.GetMemberByIdAnggota(ctx, peminjam)
+		if err != nil {
+			m, err = s.GetMemberByNama(ctx, peminjam)
+		}
+
+		if errors.Is(err, types.ErrMemberNotFound) {
+			continue
+		} else if err != nil {
+			log.Printf("link circulation %s: %v", id, err)
+			continue
+		}
+
+		if _, err := s.db.ExecContext(ctx, "UPDATE circulations SET member_id = ?, peminjam = ? WHERE id = ? AND member_id IS NULL", m.ID, m.IdAnggota, id); err != nil {
+			return linked, err
+		}
+
+		if circKey, err := utils.Redis2Key("circulation", id); err == nil {
+			s.rdb.Del(ctx, circKey)
+		}
+
+		linked++
+	}
+
+	return linked, nil
+}
+
+// the opened member is stale when the stored columns aren't the same as sealMember would store.
+func needsRekey(m *types.Member, nama, namaBidx, noTelepon, noTeleponBidx string) bool {
+	return encrypt.NeedsRotation(noTelepon) ||
+		(m.IsMinor() && encrypt.NeedsRotation(nama)) ||
+		(!m.IsMinor() && encrypt.IsSealed(nama)) ||
+		namaBidx != encrypt.BlindIndex(fieldNama, normalizeNama(m.Nama)) ||
+		noTeleponBidx != encrypt.BlindIndex(fieldNoTelepon, normalizeNoTelepon(m.NoTelepon))
+}
+
+const (
+	// the additional data of the encryption and the blind index, so the value can't be moved to other column
+	fieldNama      = "members.nama"
+	fieldNoTelepon = "members.no_telepon"
+)
+
+// the columns as it's stored at db.
+type sealedMember struct {
+	nama, namaBidx, noTelepon, noTeleponBidx string
+}
+
+// no_telepon is always encrypted, and the name only when the member is minor. m.ID must be set, it's bound to the ciphertext.
+func sealMember(m *types.Member) (*sealedMember, error) {
+	sm := &sealedMember{
+		nama:          m.Nama,
+		namaBidx:      encrypt.BlindIndex(fieldNama, normalizeNama(m.Nama)),
+		noTeleponBidx: encrypt.BlindIndex(fieldNoTelepon, normalizeNoTelepon(m.NoTelepon)),
+	}
+
+	var err error
+
+	if sm.noTelepon, err = encrypt.Seal(fieldNoTelepon, m.ID, m.NoTelepon); err != nil {
+		return nil, err
+	}
+
+	if m.IsMinor() {
+		if sm.nama, err = encrypt.Seal(fieldNama, m.ID, m.Nama); err != nil {
+			return nil, err
+		}
+	}
+
+	return sm, nil
+}
+
+// decrypt the scanned member, the plaintext column is kept as it is.
+func openMember(m *types.Member) error {
+	var err error
+
+	if m.Nama, err = encrypt.Open(fieldNama, m.ID, m.Nama); err != nil {
+		return err
+	}
+
+	if m.NoTelepon, err = encrypt.Open(fieldNoTelepon, m.ID, m.NoTelepon); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// the copy for the search index. no_telepon is removed, and the name of minor is only the initials, ex: "B. S.".
+func redactMember(m *types.Member) *types.Member {
+	r := *m
+	r.NoTelepon = ""
+	r.TanggalLahir = nil
+
+	if m.IsMinor() {
+		initials := make([]string, 0)
+
+		for word := range strings.FieldsSeq(m.Nama) {
+			initials = append(initials, strings.ToUpper(string([]rune(word)[0]))+".")
+		}
+
+		r.Nama = strings.Join(initials, " ")
+	}
+
+	return &r
+}
+
+// same as the case insensitive collation of db, ex: "Budi  santoso" is "budi santoso".
+func normalizeNama(nama string) string {
+	return strings.ToLower(strings.Join(strings.Fields(nama), " "))
+}
+
+// only the digits and the plus, ex: "0812-3456 789" is "08123456789".
+func normalizeNoTelepon(noTelepon string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '+' {
+			return r
+		}
+
+		return -1
+	}, noTelepon)
+}
+
+func (s *Store) GetMemberLoans(ctx context.Context, m *types.Member) ([]*types.Circulation, error) {
+	if err := checkUnlinkedLoans(ctx, s.db, m); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT c.id, c.buku_id, c.id_skl, c.peminjam, c.member_id, c.tanggal_pinjam, c.jatuh_tempo, c.denda, c.created_at, c.updated_at, b.id, b.judul_buku FROM circulations c INNER JOIN books b ON c.buku_id = b.id WHERE c.member_id = ? ORDER BY c.tanggal_pinjam"
+
+	rows, err := s.db.QueryContext(ctx, query, m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	loans := make([]*types.Circulation, 0)
+
+	for rows.Next() {
+		c, b, err := helper.ScanRowsCirculation(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Book = b
+		loans = append(loans, c)
+	}
+
+	return loans, rows.Err()
+}
+
+// the circulations before member_id which has the name or id_anggota of the member, but LinkCirculations can't link them.
+// they can be the loans of other member with the same name, so the export and the anonymization is refused until the staff link them.
+func checkUnlinkedLoans(ctx context.Context, q queryRower, m *types.Member) error {
+	var unlinked int
+
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM circulations c WHERE c.member_id IS NULL AND c.peminjam IN (?, ?)", m.Nama, m.IdAnggota).Scan(&unlinked); err != nil {
+		return err
+	}
+
+	if unlinked > 0 {
+		return fmt.Errorf("%w: %d circulations", types.ErrUnlinkedLoans, unlinked)
+	}
+
+	return nil
+}
+
+// the circulations is kept for the statistics with member_id, but the peminjam is changed into the pseudonym ("anonim-<id_anggota>").
+// the redis copies of the member, the circulations, and the suggestions is deleted.
+func (s *Store) AnonymizeMember(ctx context.Context, m *types.Member) ([]string, error) {
+	pseudonym := "anonim-" + m.IdAnggota
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if err := checkUnlinkedLoans(ctx, tx, m); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT c.id FROM circulations c WHERE c.member_id = ? FOR UPDATE", m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	circulationIDs := make([]string, 0)
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		circulationIDs = append(circulationIDs, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE circulations SET peminjam = ? WHERE member_id = ?", pseudonym, m.ID); err != nil {
+		return nil, err
+	}
+
+	// jenis_kelamin and kelas is kept for the statistics
+	_, err = tx.ExecContext(ctx, "UPDATE members SET nama = ?, nama_bidx = NULL, no_telepon = '', no_telepon_bidx = NULL, profil_anggota = '-', tanggal_lahir = NULL, anonymized_at = CURRENT_TIMESTAMP WHERE id = ?", pseudonym, m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(circulationIDs)+1)
+
+	if memberKey, err := utils.Redis2Key("member", m.ID); err == nil {
+		keys = append(keys, memberKey)
+	}
+
+	for _, id := range circulationIDs {
+		if circKey, err := utils.Redis2Key("circulation", id); err == nil {
+			keys = append(keys, circKey)
+		}
+	}
+
+	s.rdb.Del(ctx, keys...)
+
+	// the suggestions can have the name, it's only cached shortly so delete all of it.
+	// the member is already anonymized at db, so the error is only logged.
+	iter := s.rdb.Scan(ctx, 0, "suggest:*", 100).Iterator()
+	for iter.Next(ctx) {
+		s.rdb.Del(ctx, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		log.Printf("delete suggestions of member %s: %v", m.ID, err)
+	}
+
+	return circulationIDs, nil
+}
+
+func (s *Store) GraduateMembers(ctx context.Context, kelas string) (int64, error) {
+	where, args := kelasCondition(kelas)
+
+	return s.updateMembers(ctx, "status = ?", []any{types.MemberStatusGraduated}, "m.status <> ? AND m.anonymized_at IS NULL AND "+where, append([]any{types.MemberStatusGraduated}, args...))
+}
+
+func (s *Store) RenewMembers(ctx context.Context, kelas string, berlakuSampai time.Time) (int64, error) {
+	where, args := "m.status IN (?, ?) AND m.anonymized_at IS NULL", []any{types.MemberStatusActive, types.MemberStatusExpired}
+
+	if kelas != "" {
+		kelasWhere, kelasArgs := kelasCondition(kelas)
+
+		where += " AND " + kelasWhere
+		args = append(args, kelasArgs...)
+	}
+
+	return s.updateMembers(ctx, "status = ?, berlaku_sampai = ?", []any{types.MemberStatusActive, berlakuSampai}, where, args)
+}
+
+func (s *Store) ExpireMembers(ctx context.Context) (int64, error) {
+	return s.updateMembers(ctx, "status = ?", []any{types.MemberStatusExpired}, "m.status = ? AND m.anonymized_at IS NULL AND m.berlaku_sampai < CURDATE()", []any{types.MemberStatusActive})
+}
+
+// update the members which is matched by where, and delete the redis copies of them.
+func (s *Store) updateMembers(ctx context.Context, set string, setArgs []any, where string, whereArgs []any) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT m.id FROM members m WHERE "+where+" FOR UPDATE", whereArgs...)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]string, 0)
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE members m SET "+set+" WHERE "+where, append(setArgs, whereArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		if memberKey, err := utils.Redis2Key("member", id); err == nil {
+			keys = append(keys, memberKey)
+		}
+	}
+
+	s.rdb.Del(ctx, keys...)
+
+	return updated, nil
+}
+
+// the class and its parallel classes, ex: "XII" is "XII", "XII IPA 1", and "XII IPS 2".
+func kelasCondition(kelas string) (string, []any) {
+	kelas = strings.Join(strings.Fields(kelas), " ")
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(kelas)
+
+	return "(m.kelas = ? OR m.kelas LIKE ?)", []any{kelas, escaped + " %"}
+}
@@ -0,0 +1,173 @@
+package member
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/pkg/encrypt"
+	"github.com/perpus_backend/types"
+)
+
+func useKeyring(t *testing.T, ids ...string) {
+	t.Helper()
+
+	list := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		key := make([]byte, 32)
+		rand.Read(key)
+
+		list = append(list, id+":"+base64.StdEncoding.EncodeToString(key))
+	}
+
+	k, err := encrypt.NewKeyring(list, "index-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt.SetKeyring(k)
+	t.Cleanup(func() { encrypt.SetKeyring(nil) })
+}
+
+func birthDate(age int) *time.Time {
+	d := time.Now().AddDate(-age, 0, -1)
+	return &d
+}
+
+func TestSealMember(t *testing.T) {
+	useKeyring(t, "2026a")
+
+	tests := []struct {
+		name       string
+		member     *types.Member
+		sealedNama bool
+	}{
+		{name: "it should seal the name of minor", member: &types.Member{ID: "member-1", Nama: "Budi Santoso", NoTelepon: "0812-3456 789", TanggalLahir: birthDate(15)}, sealedNama: true},
+		{name: "it should seal the name without tanggal_lahir", member: &types.Member{ID: "member-2", Nama: "Siti Aminah", NoTelepon: "08123456789"}, sealedNama: true},
+		{name: "it should keep the name of adult", member: &types.Member{ID: "member-3", Nama: "Ahmad Fauzi", NoTelepon: "08123456789", TanggalLahir: birthDate(30)}, sealedNama: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, err := sealMember(tt.member)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !encrypt.IsSealed(sm.noTelepon) {
+				t.Errorf("expected sealed no_telepon, got %s", sm.noTelepon)
+			}
+
+			if encrypt.IsSealed(sm.nama) != tt.sealedNama {
+				t.Errorf("expected sealed nama %v, got %s", tt.sealedNama, sm.nama)
+			}
+
+			if sm.namaBidx == "" || sm.noTeleponBidx == "" {
+				t.Error("expected the blind indexes")
+			}
+
+			opened := &types.Member{ID: tt.member.ID, Nama: sm.nama, NoTelepon: sm.noTelepon, TanggalLahir: tt.member.TanggalLahir}
+			if err := openMember(opened); err != nil {
+				t.Fatal(err)
+			}
+
+			if opened.Nama != tt.member.Nama || opened.NoTelepon != tt.member.NoTelepon {
+				t.Errorf("expected %s %s, got %s %s", tt.member.Nama, tt.member.NoTelepon, opened.Nama, opened.NoTelepon)
+			}
+
+			if needsRekey(opened, sm.nama, sm.namaBidx, sm.noTelepon, sm.noTeleponBidx) {
+				t.Error("expected the sealed member isn't rekeyed")
+			}
+		})
+	}
+
+	t.Run("it should not open the columns copied into other member", func(t *testing.T) {
+		sm, err := sealMember(&types.Member{ID: "member-1", Nama: "Budi Santoso", NoTelepon: "08123456789"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := openMember(&types.Member{ID: "member-2", Nama: sm.nama, NoTelepon: sm.noTelepon}); err == nil {
+			t.Error("expected error for the columns of other member")
+		}
+	})
+
+	t.Run("it should find the same blind index for the normalized value", func(t *testing.T) {
+		a, _ := sealMember(&types.Member{ID: "member-1", Nama: "Budi  Santoso", NoTelepon: "0812-3456 789"})
+		b, _ := sealMember(&types.Member{ID: "member-2", Nama: "budi santoso ", NoTelepon: "08123456789"})
+
+		if a.namaBidx != b.namaBidx || a.noTeleponBidx != b.noTeleponBidx {
+			t.Error("expected the same blind indexes")
+		}
+	})
+}
+
+func TestNeedsRekey(t *testing.T) {
+	useKeyring(t, "2025a")
+
+	minor := &types.Member{ID: "member-1", Nama: "Budi Santoso", NoTelepon: "08123456789", TanggalLahir: birthDate(15)}
+	adult := &types.Member{ID: "member-2", Nama: "Ahmad Fauzi", NoTelepon: "08123456789", TanggalLahir: birthDate(30)}
+
+	oldMinor, _ := sealMember(minor)
+	sealedAdultNama, _ := encrypt.Seal(fieldNama, adult.ID, adult.Nama)
+
+	useKeyring(t, "2026a", "2025a")
+
+	newMinor, _ := sealMember(minor)
+	newAdult, _ := sealMember(adult)
+
+	tests := []struct {
+		name                                     string
+		member                                   *types.Member
+		nama, namaBidx, noTelepon, noTeleponBidx string
+		want                                     bool
+	}{
+		{name: "it should keep the member of the active key", member: minor, nama: newMinor.nama, namaBidx: newMinor.namaBidx, noTelepon: newMinor.noTelepon, noTeleponBidx: newMinor.noTeleponBidx, want: false},
+		{name: "it should rekey the member of the old key", member: minor, nama: oldMinor.nama, namaBidx: oldMinor.namaBidx, noTelepon: oldMinor.noTelepon, noTeleponBidx: oldMinor.noTeleponBidx, want: true},
+		{name: "it should rekey the plaintext member", member: minor, nama: minor.Nama, namaBidx: newMinor.namaBidx, noTelepon: minor.NoTelepon, noTeleponBidx: newMinor.noTeleponBidx, want: true},
+		{name: "it should rekey the member without blind index", member: minor, nama: newMinor.nama, noTelepon: newMinor.noTelepon, want: true},
+		{name: "it should open the name of the member which is adult now", member: adult, nama: sealedAdultNama, namaBidx: newAdult.namaBidx, noTelepon: newAdult.noTelepon, noTeleponBidx: newAdult.noTeleponBidx, want: true},
+		{name: "it should keep the plaintext name of adult", member: adult, nama: newAdult.nama, namaBidx: newAdult.namaBidx, noTelepon: newAdult.noTelepon, noTeleponBidx: newAdult.noTeleponBidx, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsRekey(tt.member, tt.nama, tt.namaBidx, tt.noTelepon, tt.noTeleponBidx); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRedactMember(t *testing.T) {
+	tests := []struct {
+		name   string
+		member *types.Member
+		want   string
+	}{
+		{name: "it should redact the name of minor to the initials", member: &types.Member{Nama: "budi santoso", NoTelepon: "08123456789", TanggalLahir: birthDate(15)}, want: "B. S."},
+		{name: "it should redact the name without tanggal_lahir", member: &types.Member{Nama: "Élise  Putri", NoTelepon: "08123456789"}, want: "É. P."},
+		{name: "it should keep the name of adult", member: &types.Member{Nama: "Ahmad Fauzi", NoTelepon: "08123456789", TanggalLahir: birthDate(30)}, want: "Ahmad Fauzi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := redactMember(tt.member)
+
+			if r.Nama != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, r.Nama)
+			}
+
+			if r.NoTelepon != "" || r.TanggalLahir != nil {
+				t.Errorf("expected no_telepon and tanggal_lahir are removed, got %q %v", r.NoTelepon, r.TanggalLahir)
+			}
+
+			if tt.member.NoTelepon == "" || strings.HasSuffix(tt.member.Nama, ".") {
+				t.Error("expected the member isn't changed")
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package role
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"slices"
 
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
@@ -14,32 +17,45 @@ import (
 )
 
 type Handler struct {
-	store     types.RoleStore
-	userStore types.UserStore
+	store           types.RoleStore
+	permissionStore types.PermissionStore
+	userStore       types.UserStore
 
 	jwt *jwt.AuthJWT
 }
 
-func NewHandler(jwt *jwt.AuthJWT, store types.RoleStore, userStore types.UserStore) *Handler {
+func NewHandler(jwt *jwt.AuthJWT, store types.RoleStore, permissionStore types.PermissionStore, userStore types.UserStore) *Handler {
 	return &Handler{
-		store:     store,
-		userStore: userStore,
-		jwt:       jwt,
+		store:           store,
+		permissionStore: permissionStore,
+		userStore:       userStore,
+		jwt:             jwt,
 	}
 }
 
 const cok = http.StatusOK
 
+var errInvalidRoleName = fmt.Errorf("invalid role name; only lowercase letter, number, - and _ with 3-32 characters")
+
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/roles", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetRoles, "admin"))).Methods(http.MethodGet)
+	roles := audit.Target{Resource: "roles", IDVar: "roleID", Load: audit.Load(h.store.GetRoleByID)}
+	rolePermissions := audit.Target{Resource: "role_permissions", IDVar: "roleID", Load: h.loadRolePermissions}
+
+	r.HandleFunc("/roles", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetRoles, types.PermRolesAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetRoleByID, types.PermRolesAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/roles", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleCreateRole, "roles.create", roles), types.PermRolesAdmin))).Methods(http.MethodPost)
 
-	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetRoleByID, "admin"))).Methods(http.MethodGet)
+	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleUpdateRole, "roles.update", roles), types.PermRolesAdmin))).Methods(http.MethodPatch)
 
-	r.HandleFunc("/roles", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleCreateRole, "admin"))).Methods(http.MethodPost)
+	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleDeleteRole, "roles.delete", roles), types.PermRolesAdmin))).Methods(http.MethodDelete)
 
-	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleUpdateRole, "admin"))).Methods(http.MethodPatch)
+	r.HandleFunc("/permissions", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetPermissions, types.PermRolesAdmin))).Methods(http.MethodGet)
 
-	r.HandleFunc("/roles/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteRole, "admin"))).Methods(http.MethodDelete)
+	r.HandleFunc("/roles/{roleID}/permissions", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetRolePermissions, types.PermRolesAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/roles/{roleID}/permissions", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleSetRolePermissions, "roles.set_permissions", rolePermissions), types.PermRolesAdmin))).Methods(http.MethodPut)
 }
 
 func (h *Handler) handleGetRoles(w http.ResponseWriter, r *http.Request) {
@@ -104,16 +120,32 @@ func (h *Handler) handleCreateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// if the role name was out of the box, it should be triggered
 	if !utils.IsInputRoleNameWasValid(payload.Name) {
-		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid role name; only admin, staff, and user can be valid"))
+		utils.WriteJSONError(w, http.StatusBadRequest, errInvalidRoleName)
+		return
+	}
+
+	// the built-in role without permissions get the default one
+	permissions := r.Form["permissions"]
+	if len(permissions) == 0 {
+		permissions = types.DefaultRolePermissions[payload.Name]
+	}
+
+	if err := validatePermissions(permissions); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	err := h.store.CreateRole(ctx, &types.Role{
+	role := &types.Role{
 		Name: payload.Name,
-	})
-	if err != nil {
+	}
+
+	if err := h.store.CreateRole(ctx, role); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.permissionStore.SetRolePermissions(ctx, role.ID, permissions); err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -156,12 +188,18 @@ func (h *Handler) handleUpdateRole(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// the built-in roles are used by name (default sso role, 2fa roles, the admin guard), so they can't be renamed
+	if _, builtIn := types.DefaultRolePermissions[r.Name]; builtIn && payload.Name != "" && payload.Name != r.Name {
+		utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("you can't rename built-in role %s", r.Name))
+		return
+	}
+
 	if payload.Name != "" {
 		r.Name = payload.Name
 	}
 
 	if !utils.IsInputRoleNameWasValid(r.Name) {
-		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid role name; only admin, staff, and user can be valid"))
+		utils.WriteJSONError(w, http.StatusBadRequest, errInvalidRoleName)
 		return
 	}
 
@@ -212,3 +250,115 @@ func (h *Handler) handleDeleteRole(w http.ResponseWriter, req *http.Request) {
 		Status:  http.StatusText(cok),
 	})
 }
+
+// all permissions which can be given to the roles.
+func (h *Handler) handleGetPermissions(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   types.Permissions,
+		Status: http.StatusText(cok),
+	})
+}
+
+func (h *Handler) handleGetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	roleID := mux.Vars(r)["roleID"]
+
+	ctx := r.Context()
+
+	if err := uuid.Validate(roleID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.store.GetRoleByID(ctx, roleID); err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	permissions, err := h.permissionStore.GetPermissionsByRoleIDs(ctx, []string{roleID})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   permissions[roleID],
+		Status: http.StatusText(cok),
+	})
+}
+
+// replace the permissions of role, send "permissions" field many times for each permission.
+func (h *Handler) handleSetRolePermissions(w http.ResponseWriter, req *http.Request) {
+	roleID := mux.Vars(req)["roleID"]
+
+	ctx := req.Context()
+
+	if err := uuid.Validate(roleID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadRolePermissions{
+		Permissions: req.Form["permissions"],
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	if err := validatePermissions(payload.Permissions); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	r, err := h.store.GetRoleByID(ctx, roleID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// so there is always a role which can manage the roles
+	if r.Name == "admin" && !slices.Contains(payload.Permissions, types.PermRolesAdmin) {
+		utils.WriteJSONError(w, http.StatusForbidden, fmt.Errorf("you can't remove %s from role admin", types.PermRolesAdmin))
+		return
+	}
+
+	if err := h.permissionStore.SetRolePermissions(ctx, roleID, payload.Permissions); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Role Permissions Updated!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// permissions of the role for the audit log diff.
+func (h *Handler) loadRolePermissions(ctx context.Context, roleID string) (any, error) {
+	granted, err := h.permissionStore.GetPermissionsByRoleIDs(ctx, []string{roleID})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]string{"permissions": granted[roleID]}, nil
+}
+
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !types.IsValidPermission(permission) {
+			return fmt.Errorf("invalid permission: %s", permission)
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package role
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
@@ -18,7 +20,7 @@ func TestHandlerRole(t *testing.T) {
 	mockRoleStore := types.MockRoleStore{}
 	mockUserStore := types.MockUserStore{}
 
-	h := NewHandler(jwt, mockRoleStore, mockUserStore)
+	h := NewHandler(jwt, mockRoleStore, types.MockPermissionStore{}, mockUserStore)
 
 	t.Run("it should be get roles data", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/roles", nil)
@@ -85,4 +87,117 @@ func TestHandlerRole(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
 		}
 	})
+
+	t.Run("it should correct and make custom role with permissions", func(t *testing.T) {
+		form := &url.Values{}
+
+		form.Add("name", "librarian-assistant")
+		form.Add("permissions", types.PermBooksRead)
+		form.Add("permissions", types.PermCirculationsReturn)
+
+		req, err := http.NewRequest(http.MethodPost, "/roles", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/roles", h.handleCreateRole).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
+		}
+	})
+
+	t.Run("it should fail make role, because the permission is unknown", func(t *testing.T) {
+		form := &url.Values{}
+
+		form.Add("name", "guest")
+		form.Add("permissions", "books:burn")
+
+		req, err := http.NewRequest(http.MethodPost, "/roles", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/roles", h.handleCreateRole).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail update role permissions, because invalid role id", func(t *testing.T) {
+		form := &url.Values{}
+
+		form.Add("permissions", types.PermBooksRead)
+
+		req, err := http.NewRequest(http.MethodPut, "/roles/asd/permissions", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/roles/{roleID}/permissions", h.handleSetRolePermissions).Methods(http.MethodPut)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail rename built-in role", func(t *testing.T) {
+		h := NewHandler(jwt, builtInRoleStore{}, types.MockPermissionStore{}, mockUserStore)
+
+		form := &url.Values{}
+
+		form.Add("name", "superuser")
+
+		req, err := http.NewRequest(http.MethodPatch, "/roles/"+uuid.NewString(), strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/roles/{roleID}", h.handleUpdateRole).Methods(http.MethodPatch)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+type builtInRoleStore struct {
+	types.MockRoleStore
+}
+
+func (s builtInRoleStore) GetRoleByID(ctx context.Context, id string) (*types.Role, error) {
+	return &types.Role{ID: id, Name: "admin"}, nil
 }
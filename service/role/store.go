@@ -178,6 +178,110 @@ func (s *Store) DeleteRole(ctx context.Context, id string) error {
 		return fmt.Errorf("role not found")
 	}
 
-	s.rdb.Del(ctx, roleKey)
+	// the permissions is deleted by cascade
+	permKey, err := utils.Redis2Key("role_permissions", id)
+	if err != nil {
+		return err
+	}
+
+	s.rdb.Del(ctx, roleKey, permKey)
+	return nil
+}
+
+// permissions of each role is cached, since it's checked at every request.
+func (s *Store) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []string) (map[string][]string, error) {
+	permissions := make(map[string][]string, len(roleIDs))
+
+	for _, roleID := range roleIDs {
+		if roleID == "" {
+			continue
+		}
+
+		permKey, err := utils.Redis2Key("role_permissions", roleID)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := s.rdb.Get(ctx, permKey).Result()
+		if err == nil {
+			perms := make([]string, 0)
+
+			if err := sonic.Unmarshal([]byte(res), &perms); err == nil {
+				permissions[roleID] = perms
+				continue
+			}
+
+			s.rdb.Del(ctx, permKey)
+		} else if err != redis.Nil {
+			return nil, err
+		}
+
+		perms, err := s.getRolePermissions(ctx, roleID)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err := sonic.Marshal(perms); err == nil {
+			_ = s.rdb.SetEx(ctx, permKey, data, 5*time.Minute).Err()
+		}
+
+		permissions[roleID] = perms
+	}
+
+	return permissions, nil
+}
+
+// replace all permissions of the role.
+func (s *Store) SetRolePermissions(ctx context.Context, roleID string, permissions []string) error {
+	permKey, err := utils.Redis2Key("role_permissions", roleID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = ?", roleID); err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO role_permissions (role_id, permission) VALUES (?,?)", roleID, permission); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.rdb.Del(ctx, permKey)
 	return nil
 }
+
+func (s *Store) getRolePermissions(ctx context.Context, roleID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT rp.permission FROM role_permissions rp WHERE rp.role_id = ? ORDER BY rp.permission", roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	perms := make([]string, 0)
+
+	for rows.Next() {
+		var permission string
+
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+
+		perms = append(perms, permission)
+	}
+
+	return perms, rows.Err()
+}
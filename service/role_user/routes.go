@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/perpus_backend/pkg/audit"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
@@ -34,11 +35,15 @@ func NewHandler(jwt *jwt.AuthJWT, store types.RoleUserStore, userStore types.Use
 const cok = http.StatusOK
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/role_user/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetUserWithRoleByUserID, "admin"))).Methods(http.MethodGet)
+	// the roles of the user before and after
+	assign := audit.Target{Resource: "role_user", IDVar: "user_id", Load: audit.Load(h.store.GetUserWithRoleByUserID)}
+	remove := audit.Target{Resource: "role_user", IDVar: "userID", Load: audit.Load(h.store.GetUserWithRoleByUserID)}
 
-	r.HandleFunc("/role_user", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleAssignRoleIntoUser, "admin"))).Methods(http.MethodPost)
+	r.HandleFunc("/role_user/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetUserWithRoleByUserID, types.PermUsersAdmin))).Methods(http.MethodGet)
 
-	r.HandleFunc("/user/{userID}/role/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteRoleFromUser, "admin"))).Methods(http.MethodDelete)
+	r.HandleFunc("/role_user", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleAssignRoleIntoUser, "role_user.assign", assign), types.PermUsersAdmin))).Methods(http.MethodPost)
+
+	r.HandleFunc("/user/{userID}/role/{roleID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleDeleteRoleFromUser, "role_user.delete", remove), types.PermUsersAdmin))).Methods(http.MethodDelete)
 }
 
 func (h *Handler) handleGetUserWithRoleByUserID(w http.ResponseWriter, r *http.Request) {
@@ -52,8 +52,8 @@ func (s *Store) GetUserWithRoleByUserID(ctx context.Context, userID string) (*ty
 	u.token_version AS user_token_version,
 	u.created_at,
 	u.updated_at,
-	GROUP_CONCAT(r.id SEPARATOR ', ') AS role_id,
-	GROUP_CONCAT(r.name SEPARATOR ', ') AS role_name
+	GROUP_CONCAT(r.id ORDER BY r.id SEPARATOR ', ') AS role_id,
+	GROUP_CONCAT(r.name ORDER BY r.id SEPARATOR ', ') AS role_name
 	FROM users u
 	LEFT JOIN role_user ru ON u.id = ru.user_id 
 	LEFT JOIN roles r ON ru.role_id = r.id
@@ -87,16 +87,14 @@ func (s *Store) AssignRoleIntoUser(ctx context.Context, userID, roleID string) e
 
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, userID, roleID)
-	return err
-}
-
-func (s *Store) DeleteRoleFromUser(ctx context.Context, userID, roleID string) error {
-	userKey, err := utils.Redis2Key("user", userID)
-	if err != nil {
+	if _, err := stmt.ExecContext(ctx, userID, roleID); err != nil {
 		return err
 	}
 
+	return s.invalidateClaims(ctx, userID)
+}
+
+func (s *Store) DeleteRoleFromUser(ctx context.Context, userID, roleID string) error {
 	res, err := s.db.ExecContext(ctx, "DELETE FROM role_user WHERE user_id = ? AND role_id = ?", userID, roleID)
 	if err != nil {
 		return err
@@ -111,6 +109,22 @@ func (s *Store) DeleteRoleFromUser(ctx context.Context, userID, roleID string) e
 		return fmt.Errorf("user or role not found")
 	}
 
+	return s.invalidateClaims(ctx, userID)
+}
+
+// the roles is inside the access token, so bump the claims version to reject the old tokens.
+// the refresh token still works, and the new access token has the new roles.
+func (s *Store) invalidateClaims(ctx context.Context, userID string) error {
+	userKey, err := utils.Redis2Key("user", userID)
+	if err != nil {
+		return err
+	}
+
+	versionKey, err := utils.Redis2Key("claims_version", userID)
+	if err != nil {
+		return err
+	}
+
 	s.rdb.Del(ctx, userKey)
-	return nil
+	return s.rdb.Incr(ctx, versionKey).Err()
 }
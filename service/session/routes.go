@@ -0,0 +1,121 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/perpus_backend/pkg/audit"
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store     types.SessionStore
+	userStore types.UserStore
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, s types.SessionStore, us types.UserStore) *Handler {
+	return &Handler{
+		store:     s,
+		userStore: us,
+		jwt:       jwt,
+	}
+}
+
+const cok = http.StatusOK
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	sessions := audit.Target{Resource: "sessions", IDVar: "userID"}
+
+	r.HandleFunc("/me/sessions", h.jwt.AuthWithJWTToken(h.handleGetMySessions)).Methods(http.MethodGet)
+
+	r.HandleFunc("/me/sessions/{sessionID}", h.jwt.AuthWithJWTToken(h.handleDeleteMySession)).Methods(http.MethodDelete)
+
+	// force logout the user from every device
+	r.HandleFunc("/users/{userID}/sessions", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleDeleteUserSessions, "sessions.revoke_all", sessions), types.PermUsersAdmin))).Methods(http.MethodDelete)
+}
+
+func (h *Handler) handleGetMySessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessions, err := h.store.GetSessionsByUserID(ctx, jwt.GetUserIDFromContext(ctx))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	currentID := jwt.GetSessionIDFromContext(ctx)
+
+	for _, s := range sessions {
+		s.Current = s.ID == currentID
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   sessions,
+		Status: http.StatusText(cok),
+	})
+}
+
+// revoke one device, the session must be owned by the logged user.
+func (h *Handler) handleDeleteMySession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionID := mux.Vars(r)["sessionID"]
+
+	s, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || s.UserID != jwt.GetUserIDFromContext(ctx) {
+		utils.WriteJSONError(w, http.StatusNotFound, fmt.Errorf("session not found"))
+		return
+	}
+
+	if err := h.jwt.RevokeSession(ctx, s.ID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Session Revoked!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// revoke all sessions, and bump the token version so the token which is still alive get rejected too.
+func (h *Handler) handleDeleteUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := mux.Vars(r)["userID"]
+
+	if err := uuid.Validate(userID); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid uuid format"))
+		return
+	}
+
+	if _, err := h.userStore.GetUserWithRolesByID(ctx, userID); err != nil {
+		utils.WriteJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.userStore.IncrementTokenVersion(ctx, userID, ""); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.jwt.RevokeUserSessions(ctx, userID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "User Logged Out From All Devices!",
+		Status:  http.StatusText(cok),
+	})
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandlerSession(t *testing.T) {
+	jwt := &jwt.AuthJWT{}
+	mockSessionStore := types.MockSessionStore{}
+	mockUserStore := types.MockUserStore{}
+
+	h := NewHandler(jwt, mockSessionStore, mockUserStore)
+
+	t.Run("it should be get sessions of logged user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/me/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/sessions", h.handleGetMySessions).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != cok {
+			t.Errorf("expected status code: %d, got %d", cok, w.Code)
+		}
+	})
+
+	t.Run("it should fail revoke session, because session not found", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/me/sessions/6918315b-dff4-8324-969f-e43cd434eb3e", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/sessions/{sessionID}", h.handleDeleteMySession).Methods(http.MethodDelete)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code: %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("it should fail force logout, because invalid user id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/users/asd/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/users/{userID}/sessions", h.handleDeleteUserSessions).Methods(http.MethodDelete)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code: %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
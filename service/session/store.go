@@ -0,0 +1,196 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// session record at redis hash, the time is saved as unix.
+type sessionRecord struct {
+	UserID     string `redis:"user_id"`
+	Device     string `redis:"device"`
+	IP         string `redis:"ip"`
+	UserAgent  string `redis:"user_agent"`
+	CreatedAt  int64  `redis:"created_at"`
+	LastUsedAt int64  `redis:"last_used_at"`
+}
+
+// only update last used when the session still exists, so the expired one doesn't come back without ttl.
+var touchScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("HSET", KEYS[1], "last_used_at", ARGV[1])
+end
+return 0
+`)
+
+func (s *Store) GetSessionsByUserID(ctx context.Context, userID string) ([]*types.Session, error) {
+	userSessionsKey, err := utils.Redis2Key("user_sessions", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.rdb.SMembers(ctx, userSessionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*types.Session, 0, len(ids))
+
+	for _, id := range ids {
+		session, err := s.GetSessionByID(ctx, id)
+		if err != nil {
+			// the session was expired, remove it from the list
+			s.rdb.SRem(ctx, userSessionsKey, id)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	// the last used come first
+	slices.SortFunc(sessions, func(a, b *types.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *Store) GetSessionByID(ctx context.Context, id string) (*types.Session, error) {
+	sessionInfoKey, err := utils.Redis2Key("session_info", id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := s.rdb.HGetAll(ctx, sessionInfoKey)
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(res.Val()) == 0 {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	record := new(sessionRecord)
+	if err := res.Scan(record); err != nil {
+		return nil, err
+	}
+
+	return &types.Session{
+		CreatedAt:  time.Unix(record.CreatedAt, 0),
+		LastUsedAt: time.Unix(record.LastUsedAt, 0),
+		ID:         id,
+		UserID:     record.UserID,
+		Device:     record.Device,
+		IP:         record.IP,
+		UserAgent:  record.UserAgent,
+	}, nil
+}
+
+func (s *Store) CreateSession(ctx context.Context, session *types.Session, ttl time.Duration) error {
+	sessionInfoKey, err := utils.Redis2Key("session_info", session.ID)
+	if err != nil {
+		return err
+	}
+
+	userSessionsKey, err := utils.Redis2Key("user_sessions", session.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	session.CreatedAt = now
+	session.LastUsedAt = now
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionInfoKey, &sessionRecord{
+			UserID:     session.UserID,
+			Device:     session.Device,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  now.Unix(),
+			LastUsedAt: now.Unix(),
+		})
+		pipe.Expire(ctx, sessionInfoKey, ttl)
+		pipe.SAdd(ctx, userSessionsKey, session.ID)
+		pipe.Expire(ctx, userSessionsKey, ttl)
+		return nil
+	})
+
+	return err
+}
+
+// extend the session expiry, called when the refresh token is rotated.
+func (s *Store) ExtendSession(ctx context.Context, id string, ttl time.Duration) error {
+	session, err := s.GetSessionByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	sessionInfoKey, err := utils.Redis2Key("session_info", id)
+	if err != nil {
+		return err
+	}
+
+	userSessionsKey, err := utils.Redis2Key("user_sessions", session.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(ctx, sessionInfoKey, ttl)
+		pipe.Expire(ctx, userSessionsKey, ttl)
+		return nil
+	})
+
+	return err
+}
+
+func (s *Store) TouchSession(ctx context.Context, id string) error {
+	sessionInfoKey, err := utils.Redis2Key("session_info", id)
+	if err != nil {
+		return err
+	}
+
+	return touchScript.Run(ctx, s.rdb, []string{sessionInfoKey}, time.Now().Unix()).Err()
+}
+
+func (s *Store) DeleteSession(ctx context.Context, id string) error {
+	session, err := s.GetSessionByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	sessionInfoKey, err := utils.Redis2Key("session_info", id)
+	if err != nil {
+		return err
+	}
+
+	userSessionsKey, err := utils.Redis2Key("user_sessions", session.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionInfoKey)
+		pipe.SRem(ctx, userSessionsKey, id)
+		return nil
+	})
+
+	return err
+}
@@ -0,0 +1,315 @@
+package sso
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/cookie"
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/oidc"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store         types.SSOStore
+	userStore     types.UserStore
+	roleStore     types.RoleStore
+	roleUserStore types.RoleUserStore
+	tokenStore    types.AuthTokenStore
+
+	provider *oidc.Provider
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, provider *oidc.Provider, s types.SSOStore, us types.UserStore, rs types.RoleStore, rus types.RoleUserStore, tokenStore types.AuthTokenStore) *Handler {
+	return &Handler{
+		store:         s,
+		userStore:     us,
+		roleStore:     rs,
+		roleUserStore: rus,
+		tokenStore:    tokenStore,
+		provider:      provider,
+		jwt:           jwt,
+	}
+}
+
+const (
+	cok = http.StatusOK
+
+	// time for the user to login at identity provider
+	loginStateTTL = 10 * time.Minute
+
+	// same as the password login
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+var errSSOFailed = errors.New("sso login failed")
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/sso/login", h.handleLogin).Methods(http.MethodGet)
+	r.HandleFunc("/sso/callback", h.handleCallback).Methods(http.MethodGet)
+}
+
+// redirect to the identity provider, with pkce challenge and the state for the callback.
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	authURL, err := h.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Println(err)
+		utils.WriteJSONError(w, http.StatusBadGateway, fmt.Errorf("identity provider is unavailable"))
+		return
+	}
+
+	// device name is kept until the callback, same as the "device" field of password login
+	device := r.URL.Query().Get("device")
+	if device == "" {
+		device = utils.DeviceFromUserAgent(r.UserAgent())
+	}
+
+	if err := h.store.SaveLoginState(ctx, state, &types.SSOLoginState{Verifier: verifier, Nonce: nonce, Device: device}, loginStateTTL); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie.SetSSOStateCookie(w, hash.HashToken(state), loginStateTTL)
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// the identity provider redirect back here with the code, then it exchanged into our tokens.
+// it's a top level browser navigation, so the result is sent by redirect to the client app.
+func (h *Handler) handleCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := r.URL.Query()
+
+	// the state is single use, the cookie is removed whatever the result
+	stateCookie := cookie.GetSSOStateCookie(r)
+	cookie.ClearSSOStateCookie(w)
+
+	// ex: the user cancel the login at identity provider
+	if e := q.Get("error"); e != "" {
+		redirectToClient(w, r, url.Values{"error": {fmt.Sprintf("sso login failed: %s", e)}})
+		return
+	}
+
+	if q.Get("code") == "" || q.Get("state") == "" {
+		redirectToClient(w, r, url.Values{"error": {"code and state is required"}})
+		return
+	}
+
+	// the state must come from this browser, or the attacker can make the victim login into the attacker account
+	if stateCookie == "" || subtle.ConstantTimeCompare([]byte(stateCookie), []byte(hash.HashToken(q.Get("state")))) != 1 {
+		redirectToClient(w, r, url.Values{"error": {"invalid or expired state"}})
+		return
+	}
+
+	ls, err := h.store.ConsumeLoginState(ctx, q.Get("state"))
+	if err != nil {
+		redirectToClient(w, r, url.Values{"error": {err.Error()}})
+		return
+	}
+
+	token, err := h.provider.Exchange(ctx, q.Get("code"), ls.Verifier)
+	if err != nil {
+		log.Println(err)
+		redirectToClient(w, r, url.Values{"error": {errSSOFailed.Error()}})
+		return
+	}
+
+	claims, err := h.provider.VerifyIDToken(ctx, token.IDToken, ls.Nonce)
+	if err != nil {
+		log.Println(err)
+		redirectToClient(w, r, url.Values{"error": {errSSOFailed.Error()}})
+		return
+	}
+
+	u, status, err := h.resolveUser(ctx, claims)
+	if err != nil {
+		if status >= http.StatusInternalServerError {
+			log.Println(err)
+			err = errSSOFailed
+		}
+
+		redirectToClient(w, r, url.Values{"error": {err.Error()}})
+		return
+	}
+
+	if claims.EmailVerified && u.EmailVerifiedAt == nil && strings.EqualFold(u.Email, claims.Email) {
+		if err := h.userStore.VerifyUserEmail(ctx, u.ID); err == nil {
+			now := time.Now()
+			u.EmailVerifiedAt = &now
+		}
+	}
+
+	if config.Env.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		redirectToClient(w, r, url.Values{"error": {"email is not verified"}})
+		return
+	}
+
+	// continue with /login/2fa, same as the password login
+	if u.TwoFactorEnabled {
+		challenge, err := h.tokenStore.CreateAuthToken(ctx, types.TokenTwoFactor, u.ID, twoFactorChallengeTTL)
+		if err != nil {
+			log.Println(err)
+			redirectToClient(w, r, url.Values{"error": {errSSOFailed.Error()}})
+			return
+		}
+
+		redirectToClient(w, r, url.Values{"two_factor_required": {"true"}, "challenge": {challenge}})
+		return
+	}
+
+	_, refreshToken, err := h.jwt.CreateSession(ctx, u.ID, &types.Session{
+		Device:    ls.Device,
+		IP:        utils.GetClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		log.Println(err)
+		redirectToClient(w, r, url.Values{"error": {errSSOFailed.Error()}})
+		return
+	}
+
+	// the client get the access token from /api/refresh, so no token is put at the url
+	cookie.SetRefreshCookie(w, refreshToken, config.Env.RefreshTokenTTL)
+
+	redirectToClient(w, r, nil)
+}
+
+// the result is put at the fragment, so it isn't sent to the server of client app or at the referer header.
+func redirectToClient(w http.ResponseWriter, r *http.Request, result url.Values) {
+	target := config.Env.OIDCClientRedirectURL
+	if len(result) > 0 {
+		target += "#" + result.Encode()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// find the user of the external identity. the first login link it by verified email, or make new user with the default role.
+func (h *Handler) resolveUser(ctx context.Context, claims *oidc.Claims) (*types.User, int, error) {
+	provider := h.provider.Issuer()
+
+	if identity, err := h.store.GetIdentity(ctx, provider, claims.Subject); err == nil {
+		u, err := h.userStore.GetUserWithRolesByID(ctx, identity.UserID)
+		if err != nil || u == nil {
+			return nil, http.StatusUnauthorized, errSSOFailed
+		}
+
+		return u, cok, nil
+	}
+
+	if claims.Email == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("identity provider didn't send the email")
+	}
+
+	identity := &types.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	// unverified email can't be linked, or anyone can take the account by register the email at identity provider
+	if u, err := h.userStore.GetUserWithRolesByEmail(ctx, claims.Email); err == nil {
+		if !claims.EmailVerified {
+			return nil, http.StatusConflict, fmt.Errorf("email is already registered, login with password")
+		}
+
+		identity.UserID = u.ID
+
+		if err := h.store.CreateIdentity(ctx, identity); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		return u, cok, nil
+	}
+
+	role, err := h.roleStore.GetRoleByName(ctx, config.Env.OIDCDefaultRole)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("default role %s not found", config.Env.OIDCDefaultRole)
+	}
+
+	// sso user has no password, it only can be set with password reset
+	unusable, err := oidc.RandomString()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	hashPass, err := hash.HashPassword(unusable)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	u := &types.User{
+		Name:     name,
+		Email:    claims.Email,
+		Password: hashPass,
+		Avatar:   "-",
+	}
+
+	if err := h.userStore.CreateUser(ctx, u); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err := h.roleUserStore.AssignRoleIntoUser(ctx, u.ID, role.ID); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if claims.EmailVerified {
+		if err := h.userStore.VerifyUserEmail(ctx, u.ID); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	identity.UserID = u.ID
+
+	if err := h.store.CreateIdentity(ctx, identity); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	created, err := h.userStore.GetUserWithRolesByID(ctx, u.ID)
+	if err != nil || created == nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get the new user")
+	}
+
+	return created, cok, nil
+}
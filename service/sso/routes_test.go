@@ -0,0 +1,379 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/oidc"
+	"github.com/perpus_backend/pkg/oidc/oidctest"
+	"github.com/perpus_backend/types"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestHandlerSSO(t *testing.T) {
+	srv, idp, err := oidctest.NewServer("perpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer srv.Close()
+
+	jwt := &jwt.AuthJWT{}
+	provider := oidc.NewProvider(idp.Issuer, "perpus", "", "http://localhost/api/sso/callback", []string{"openid", "email", "profile"})
+
+	h := NewHandler(jwt, provider, types.MockSSOStore{}, types.MockUserStore{}, types.MockRoleStore{}, types.MockRoleUserStore{}, types.MockAuthTokenStore{})
+
+	t.Run("it should redirect to identity provider with pkce challenge", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/sso/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/sso/login", h.handleLogin).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected status code: %d, got %d", http.StatusFound, w.Code)
+		}
+
+		location := w.Header().Get("Location")
+		if !strings.HasPrefix(location, idp.Issuer+"/authorize?") {
+			t.Fatalf("expected redirect to identity provider, got %s", location)
+		}
+
+		u, err := url.Parse(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Query().Get("code_challenge_method") != "S256" || u.Query().Get("code_challenge") == "" {
+			t.Errorf("expected pkce S256 challenge, got %s", u.RawQuery)
+		}
+
+		if u.Query().Get("state") == "" || u.Query().Get("nonce") == "" {
+			t.Errorf("expected state and nonce, got %s", u.RawQuery)
+		}
+	})
+
+	t.Run("it should fail callback, because the state cookie is missing", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/sso/callback?code=asd&state=asd", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/sso/callback", h.handleCallback).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		expectClientError(t, w)
+	})
+
+	t.Run("it should fail callback, because state is unknown", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/sso/callback?code=asd&state=asd", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(&http.Cookie{Name: "sso_state", Value: hash.HashToken("asd")})
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/sso/callback", h.handleCallback).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		expectClientError(t, w)
+	})
+
+	t.Run("it should fail callback, because user cancel the login at identity provider", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/sso/callback?error=access_denied&state=asd", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/sso/callback", h.handleCallback).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		expectClientError(t, w)
+	})
+
+	t.Run("it should login the new user and redirect to client app", func(t *testing.T) {
+		store := &loginStateStore{states: make(map[string]*types.SSOLoginState)}
+		users := &provisionUserStore{}
+		roleUsers := &assignRoleStore{assigned: make(map[string]string)}
+
+		h := NewHandler(newTestAuthJWT(t, users), provider, store, users, defaultRoleStore{}, roleUsers, types.MockAuthTokenStore{})
+
+		stateCookie, query := loginAtIdP(t, h)
+
+		w := callback(t, h, query, stateCookie)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected status code: %d, got %d", http.StatusFound, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != config.Env.OIDCClientRedirectURL {
+			t.Errorf("expected redirect to %s without fragment, got %s", config.Env.OIDCClientRedirectURL, location)
+		}
+
+		cookies := make(map[string]*http.Cookie)
+		for _, c := range w.Result().Cookies() {
+			cookies[c.Name+c.Path] = c
+		}
+
+		if c := cookies[config.Env.RefreshCookieName+"/api/refresh"]; c == nil || c.Value == "" || !c.HttpOnly {
+			t.Errorf("expected the refresh cookie is set, got %v", c)
+		}
+
+		if c := cookies["sso_state/api/sso"]; c == nil || c.MaxAge >= 0 {
+			t.Errorf("expected the state cookie is cleared, got %v", c)
+		}
+
+		if users.created == nil || users.created.Email != idp.User.Email || users.created.Name != idp.User.Name {
+			t.Fatalf("expected user %s is created, got %+v", idp.User.Email, users.created)
+		}
+
+		if roleUsers.assigned[users.created.ID] != "role-user" {
+			t.Errorf("expected the default role is assigned, got %v", roleUsers.assigned)
+		}
+
+		if len(store.identities) != 1 || store.identities[0].Subject != idp.User.Subject || store.identities[0].UserID != users.created.ID {
+			t.Errorf("expected the identity is linked to the new user, got %+v", store.identities)
+		}
+	})
+
+	t.Run("it should fail callback, because the state cookie is from other browser", func(t *testing.T) {
+		store := &loginStateStore{states: make(map[string]*types.SSOLoginState)}
+		users := &provisionUserStore{}
+
+		h := NewHandler(newTestAuthJWT(t, users), provider, store, users, defaultRoleStore{}, &assignRoleStore{assigned: make(map[string]string)}, types.MockAuthTokenStore{})
+
+		// the attacker start the login, and send the callback url to the victim which has own login
+		_, query := loginAtIdP(t, h)
+		victimCookie, _ := loginAtIdP(t, h)
+
+		w := callback(t, h, query, victimCookie)
+
+		expectClientError(t, w)
+
+		if users.created != nil {
+			t.Errorf("expected no user is created, got %+v", users.created)
+		}
+
+		if len(store.states) != 2 {
+			t.Errorf("expected the state isn't consumed, got %d states", len(store.states))
+		}
+	})
+
+	t.Run("it should fail callback, because the nonce of id token is different", func(t *testing.T) {
+		store := &loginStateStore{states: make(map[string]*types.SSOLoginState)}
+		users := &provisionUserStore{}
+
+		h := NewHandler(newTestAuthJWT(t, users), provider, store, users, defaultRoleStore{}, &assignRoleStore{assigned: make(map[string]string)}, types.MockAuthTokenStore{})
+
+		stateCookie, query := loginAtIdP(t, h)
+
+		// the saved nonce is changed, so the id token which has the nonce of the authorize request is rejected
+		for _, ls := range store.states {
+			ls.Nonce = "other"
+		}
+
+		w := callback(t, h, query, stateCookie)
+
+		expectClientError(t, w)
+
+		if users.created != nil {
+			t.Errorf("expected no user is created, got %+v", users.created)
+		}
+	})
+}
+
+// start the login, and approve it at identity provider. it return the state cookie and the callback query.
+func loginAtIdP(t *testing.T, h *Handler) (*http.Cookie, url.Values) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := mux.NewRouter()
+
+	r.HandleFunc("/sso/login", h.handleLogin).Methods(http.MethodGet)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sso/login", nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status code: %d, got %d", http.StatusFound, w.Code)
+	}
+
+	var stateCookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "sso_state" {
+			stateCookie = c
+		}
+	}
+
+	if stateCookie == nil || !stateCookie.HttpOnly || stateCookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected HttpOnly and SameSite Lax state cookie, got %v", stateCookie)
+	}
+
+	// the identity provider redirect to the callback, it isn't followed
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stateCookie, location.Query()
+}
+
+func callback(t *testing.T, h *Handler, query url.Values, stateCookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/sso/callback?"+query.Encode(), nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie.Name, Value: stateCookie.Value})
+
+	w := httptest.NewRecorder()
+	r := mux.NewRouter()
+
+	r.HandleFunc("/sso/callback", h.handleCallback).Methods(http.MethodGet)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func expectClientError(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status code: %d, got %d", http.StatusFound, w.Code)
+	}
+
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, config.Env.OIDCClientRedirectURL+"#error=") {
+		t.Errorf("expected redirect to client app with error, got %s", location)
+	}
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == config.Env.RefreshCookieName && c.MaxAge > 0 {
+			t.Error("expected no refresh cookie")
+		}
+	}
+}
+
+func newTestAuthJWT(t *testing.T, us types.UserStore) *jwt.AuthJWT {
+	t.Helper()
+
+	keys, err := jwt.LoadKeyring(t.TempDir(), jwt.AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	return jwt.NewAuthJWT(us, types.MockSessionStore{}, types.MockPermissionStore{}, types.MockAPIKeyStore{}, keys, rdb)
+}
+
+type loginStateStore struct {
+	types.MockSSOStore
+
+	states     map[string]*types.SSOLoginState
+	identities []*types.Identity
+}
+
+func (s *loginStateStore) SaveLoginState(ctx context.Context, state string, ls *types.SSOLoginState, ttl time.Duration) error {
+	s.states[state] = ls
+	return nil
+}
+
+func (s *loginStateStore) ConsumeLoginState(ctx context.Context, state string) (*types.SSOLoginState, error) {
+	ls, ok := s.states[state]
+	if !ok {
+		return nil, fmt.Errorf("invalid or expired state")
+	}
+
+	delete(s.states, state)
+
+	return ls, nil
+}
+
+func (s *loginStateStore) CreateIdentity(ctx context.Context, i *types.Identity) error {
+	s.identities = append(s.identities, i)
+	return nil
+}
+
+type provisionUserStore struct {
+	types.MockUserStore
+
+	created *types.User
+}
+
+func (s *provisionUserStore) CreateUser(ctx context.Context, u *types.User) error {
+	u.ID = "user-1"
+	s.created = u
+	return nil
+}
+
+func (s *provisionUserStore) GetUserWithRolesByID(ctx context.Context, id string) (*types.User, error) {
+	if s.created == nil || s.created.ID != id {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	u := *s.created
+	u.Roles = types.Roles{{ID: "role-user", Name: "user"}}
+
+	return &u, nil
+}
+
+type defaultRoleStore struct {
+	types.MockRoleStore
+}
+
+func (s defaultRoleStore) GetRoleByName(ctx context.Context, name string) (*types.Role, error) {
+	return &types.Role{ID: "role-" + name, Name: name}, nil
+}
+
+type assignRoleStore struct {
+	types.MockRoleUserStore
+
+	assigned map[string]string
+}
+
+func (s *assignRoleStore) AssignRoleIntoUser(ctx context.Context, userID, roleID string) error {
+	s.assigned[userID] = roleID
+	return nil
+}
@@ -0,0 +1,90 @@
+package sso
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func NewStore(db *sql.DB, rdb *redis.Client) *Store {
+	return &Store{db: db, rdb: rdb}
+}
+
+func (s *Store) GetIdentity(ctx context.Context, provider, subject string) (*types.Identity, error) {
+	i := new(types.Identity)
+
+	err := s.db.QueryRowContext(ctx, "SELECT ui.id, ui.user_id, ui.provider, ui.subject, ui.email, ui.created_at FROM user_identities ui WHERE ui.provider = ? AND ui.subject = ?", provider, subject).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("identity not found")
+		}
+
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (s *Store) CreateIdentity(ctx context.Context, i *types.Identity) error {
+	if i.ID == "" {
+		i.ID = uuid.NewString()
+	}
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO user_identities (id, user_id, provider, subject, email) VALUES (?,?,?,?,?)", i.ID, i.UserID, i.Provider, i.Subject, i.Email)
+	return err
+}
+
+// the state is kept by its hash. the browser which start the login has the same hash at the sso_state cookie,
+// the callback check it before consume the state.
+func (s *Store) SaveLoginState(ctx context.Context, state string, ls *types.SSOLoginState, ttl time.Duration) error {
+	stateKey, err := utils.Redis2Key("sso_state", hash.HashToken(state))
+	if err != nil {
+		return err
+	}
+
+	data, err := sonic.Marshal(ls)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, stateKey, data, ttl).Err()
+}
+
+// single use, same as the auth tokens.
+func (s *Store) ConsumeLoginState(ctx context.Context, state string) (*types.SSOLoginState, error) {
+	stateKey, err := utils.Redis2Key("sso_state", hash.HashToken(state))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.rdb.GetDel(ctx, stateKey).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("invalid or expired state")
+	} else if err != nil {
+		return nil, err
+	}
+
+	ls := new(types.SSOLoginState)
+
+	if err := sonic.Unmarshal([]byte(res), ls); err != nil {
+		return nil, err
+	}
+
+	return ls, nil
+}
@@ -0,0 +1,149 @@
+package suggest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store types.SuggestStore
+
+	indexer *search.Indexer
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, s types.SuggestStore, indexer *search.Indexer) *Handler {
+	return &Handler{
+		store:   s,
+		indexer: indexer,
+		jwt:     jwt,
+	}
+}
+
+const (
+	cok = http.StatusOK
+
+	defaultLimit = 10
+	maxLimit     = 20
+)
+
+// attributes which are used as suggestion text, by resource. the order is the order of the result.
+var sources = []struct {
+	resource   string
+	attributes []string
+}{
+	{resource: "books", attributes: []string{"judul_buku", "penulis"}},
+	{resource: "members", attributes: []string{"nama"}},
+}
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/suggest", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleSuggest, types.PermSearch))).Methods(http.MethodGet)
+}
+
+// autocomplete for search box, typo tolerant. ex: /api/suggest?q=pemrogaman&limit=5
+func (h *Handler) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, errors.New("query q is required"))
+		return
+	}
+
+	limit := utils.ParseStringToInt(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = defaultLimit
+	}
+
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	permissions, err := h.jwt.GetPermissions(ctx)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	// the resources which can be seen is part of the key, so the result never shared to the caller without the permission
+	visible := make([]string, 0, len(sources))
+
+	for _, src := range sources {
+		if res, exists := h.indexer.Resource(src.resource); exists && res.VisibleTo(permissions) {
+			visible = append(visible, src.resource)
+		}
+	}
+
+	key, err := utils.Redis2Key("suggest", fmt.Sprintf("%s:%d:%s", strings.Join(visible, ","), limit, strings.ToLower(q)))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if suggestions, err := h.store.GetSuggestions(ctx, key); err == nil {
+		utils.WriteJSON(w, cok, utils.JsonData{
+			Code:   cok,
+			Data:   suggestions,
+			Status: http.StatusText(cok),
+		})
+		return
+	}
+
+	suggestions := make([]*types.Suggestion, 0, limit)
+	seen := make(map[string]bool)
+
+	for _, src := range sources {
+		if len(suggestions) >= limit {
+			break
+		}
+
+		if !slices.Contains(visible, src.resource) {
+			continue
+		}
+
+		res, err := h.indexer.Search(ctx, src.resource, permissions, q, &types.SearchRequest{
+			Attributes: src.attributes,
+			Limit:      int64(limit),
+		})
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, hit := range res.Hits {
+			for _, attr := range src.attributes {
+				text, ok := hit[attr].(string)
+				if !ok || text == "" || text == "-" || !search.Matches(q, text) {
+					continue
+				}
+
+				id := src.resource + ":" + attr + ":" + strings.ToLower(text)
+				if seen[id] || len(suggestions) >= limit {
+					continue
+				}
+
+				seen[id] = true
+				suggestions = append(suggestions, &types.Suggestion{Text: text, Attribute: attr, Resource: src.resource})
+			}
+		}
+	}
+
+	_ = h.store.SetSuggestions(ctx, key, suggestions)
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   suggestions,
+		Status: http.StatusText(cok),
+	})
+}
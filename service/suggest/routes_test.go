@@ -0,0 +1,103 @@
+package suggest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+// permission store which give the default permissions of the built-in roles, the role id is the name.
+type defaultPermissionStore struct {
+	types.MockPermissionStore
+}
+
+func (s defaultPermissionStore) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []string) (map[string][]string, error) {
+	granted := make(map[string][]string)
+
+	for _, id := range roleIDs {
+		granted[id] = types.DefaultRolePermissions[id]
+	}
+
+	return granted, nil
+}
+
+// the logged user has role user, it can only see the books.
+func asUser(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jwt.WithClaims(r.Context(), &jwt.Claims{UserID: "user-1", Roles: []string{"user"}, RoleIDs: []string{"user"}})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func TestHandlerSuggest(t *testing.T) {
+	j := jwt.NewAuthJWT(types.MockUserStore{}, types.MockSessionStore{}, defaultPermissionStore{}, types.MockAPIKeyStore{}, nil, nil)
+	mockSuggestStore := types.MockSuggestStore{}
+
+	resources := search.NewResources(types.MockUserStore{}, types.MockRoleStore{}, types.MockMemberStore{}, types.MockBookStore{}, types.MockCirculationStore{})
+	indexer := search.NewIndexer(search.NewMemoryIndex(), resources, time.Minute)
+
+	h := NewHandler(j, mockSuggestStore, indexer)
+
+	t.Run("it should be fail when query is empty", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/suggest?q=", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/suggest", h.handleSuggest).Methods(http.MethodGet)
+		asUser(r).ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code: %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should be get suggestions", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/suggest?q=pemrogaman&limit=5", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/suggest", h.handleSuggest).Methods(http.MethodGet)
+		asUser(r).ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != cok {
+			t.Errorf("expected status code: %d, got %d", cok, w.Code)
+		}
+	})
+
+	t.Run("it should be unauthorized without the claims", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/suggest?q=pemrogaman", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/suggest", h.handleSuggest).Methods(http.MethodGet)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code: %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
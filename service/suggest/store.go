@@ -0,0 +1,47 @@
+package suggest
+
+import (
+	"context"
+	"time"
+
+	"github.com/perpus_backend/types"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// suggestions is cached shortly, the index get synced every minute anyway.
+const suggestTTL = 1 * time.Minute
+
+func (s *Store) GetSuggestions(ctx context.Context, key string) ([]*types.Suggestion, error) {
+	res, err := s.rdb.Get(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]*types.Suggestion, 0)
+
+	if err := sonic.Unmarshal([]byte(res), &suggestions); err != nil {
+		s.rdb.Del(ctx, key)
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+func (s *Store) SetSuggestions(ctx context.Context, key string, suggestions []*types.Suggestion) error {
+	data, err := sonic.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, key, data, suggestTTL).Err()
+}
@@ -0,0 +1,243 @@
+package twofactor
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/perpus_backend/pkg/hash"
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/totp"
+	"github.com/perpus_backend/types"
+	"github.com/perpus_backend/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store     types.TwoFactorStore
+	userStore types.UserStore
+
+	jwt *jwt.AuthJWT
+}
+
+func NewHandler(jwt *jwt.AuthJWT, s types.TwoFactorStore, us types.UserStore) *Handler {
+	return &Handler{
+		store:     s,
+		userStore: us,
+		jwt:       jwt,
+	}
+}
+
+const (
+	cok = http.StatusOK
+
+	issuer = "Perpus" // name shown at authenticator app
+)
+
+// the routes is not role gated, so admin and staff without 2fa still can enrol.
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/me/2fa/setup", h.jwt.AuthWithJWTToken(h.handleSetup)).Methods(http.MethodPost)
+
+	r.HandleFunc("/me/2fa/enable", h.jwt.AuthWithJWTToken(h.handleEnable)).Methods(http.MethodPost)
+
+	r.HandleFunc("/me/2fa/disable", h.jwt.AuthWithJWTToken(h.handleDisable)).Methods(http.MethodPost)
+
+	r.HandleFunc("/me/2fa/recovery-codes", h.jwt.AuthWithJWTToken(h.handleRegenerateRecoveryCodes)).Methods(http.MethodPost)
+}
+
+// make new pending secret, and return it with the qr code for authenticator app.
+func (h *Handler) handleSetup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	u, err := h.userStore.GetUserWithRolesByID(ctx, jwt.GetUserIDFromContext(ctx))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if u.TwoFactorEnabled {
+		utils.WriteJSONError(w, http.StatusConflict, fmt.Errorf("two factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.SetPendingTOTPSecret(ctx, u.ID, secret); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	uri := totp.ProvisioningURI(issuer, u.Email, secret)
+
+	qr, err := totp.QRCodeDataURL(uri)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:   cok,
+		Data:   types.TOTPSetup{Secret: secret, URI: uri, QRCode: qr},
+		Status: http.StatusText(cok),
+	})
+}
+
+// confirm the pending secret with the code from authenticator app. the recovery codes only shown here.
+func (h *Handler) handleEnable(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	payload, ok := parseCode(w, r)
+	if !ok {
+		return
+	}
+
+	userID := jwt.GetUserIDFromContext(ctx)
+
+	secret, enabled, err := h.store.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if enabled {
+		utils.WriteJSONError(w, http.StatusConflict, fmt.Errorf("two factor authentication is already enabled"))
+		return
+	}
+
+	if secret == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("two factor authentication is not set up yet"))
+		return
+	}
+
+	if err := totp.VerifySecret(ctx, h.store, userID, secret, payload.Code); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.EnableTOTP(ctx, userID, hashes); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Data:    codes,
+		Message: "Two Factor Authentication Enabled! Save the recovery codes, it only shown once. Refresh the access token to use it.",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// disable need both password and the 2fa code, so the stolen session can't remove it.
+func (h *Handler) handleDisable(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadDisableTwoFactor{
+		Password: r.FormValue("password"),
+		Code:     r.FormValue("code"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	u, err := h.userStore.GetUserWithRolesByID(ctx, jwt.GetUserIDFromContext(ctx))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	// the cached user has no password, so get it by email
+	withPassword, err := h.userStore.GetUserWithRolesByEmail(ctx, u.Email)
+	if err != nil || !hash.CompareHashedPassword(withPassword.Password, []byte(payload.Password)) {
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("wrong password"))
+		return
+	}
+
+	if err := totp.Verify(ctx, h.store, u.ID, payload.Code); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DisableTOTP(ctx, u.ID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Two Factor Authentication Disabled!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// replace all recovery codes, the old one can't be used anymore.
+func (h *Handler) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	payload, ok := parseCode(w, r)
+	if !ok {
+		return
+	}
+
+	userID := jwt.GetUserIDFromContext(ctx)
+
+	if err := totp.Verify(ctx, h.store, userID, payload.Code); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Data:    codes,
+		Message: "Recovery Codes Regenerated!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+func parseCode(w http.ResponseWriter, r *http.Request) (types.SetPayloadTwoFactorCode, bool) {
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return types.SetPayloadTwoFactorCode{}, false
+	}
+
+	payload := types.SetPayloadTwoFactorCode{
+		Code: r.FormValue("code"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return payload, false
+	}
+
+	return payload, true
+}
@@ -0,0 +1,94 @@
+package twofactor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandlerTwoFactor(t *testing.T) {
+	jwt := &jwt.AuthJWT{}
+	mockTwoFactorStore := types.MockTwoFactorStore{}
+	mockUserStore := types.MockUserStore{}
+
+	h := NewHandler(jwt, mockTwoFactorStore, mockUserStore)
+
+	t.Run("it should fail enable 2fa, because setup is not done yet", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("code", "123456")
+
+		req, err := http.NewRequest(http.MethodPost, "/me/2fa/enable", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/2fa/enable", h.handleEnable).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code: %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("it should fail disable 2fa, because password is empty", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("code", "123456")
+
+		req, err := http.NewRequest(http.MethodPost, "/me/2fa/disable", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/2fa/disable", h.handleDisable).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code: %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("it should fail regenerate recovery codes, because code is empty", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("code", "")
+
+		req, err := http.NewRequest(http.MethodPost, "/me/2fa/recovery-codes", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/2fa/recovery-codes", h.handleRegenerateRecoveryCodes).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body) // for debug if error
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code: %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+}
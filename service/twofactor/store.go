@@ -0,0 +1,197 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/perpus_backend/pkg/encrypt"
+	"github.com/perpus_backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func NewStore(db *sql.DB, rdb *redis.Client) *Store {
+	return &Store{db: db, rdb: rdb}
+}
+
+// the totp secret is sealed with the user id, so it can't be copied into other user.
+const fieldTOTPSecret = "users.totp_secret"
+
+func (s *Store) GetTOTPSecret(ctx context.Context, userID string) (string, bool, error) {
+	var (
+		secret    sql.NullString
+		enabledAt sql.NullTime
+	)
+
+	err := s.db.QueryRowContext(ctx, "SELECT u.totp_secret, u.totp_enabled_at FROM users u WHERE u.id = ?", userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, fmt.Errorf("user not found")
+		}
+
+		return "", false, err
+	}
+
+	if !secret.Valid {
+		return "", false, nil
+	}
+
+	plaintext, err := encrypt.Open(fieldTOTPSecret, userID, secret.String)
+	if err != nil {
+		return "", false, err
+	}
+
+	// the plaintext secret or the secret of the rotated key is sealed again with the active key
+	if encrypt.NeedsRotation(secret.String) {
+		if sealed, err := encrypt.Seal(fieldTOTPSecret, userID, plaintext); err == nil {
+			_, _ = s.db.ExecContext(ctx, "UPDATE users SET totp_secret = ? WHERE id = ? AND totp_secret = ?", sealed, userID, secret.String)
+		}
+	}
+
+	return plaintext, enabledAt.Valid, nil
+}
+
+// save new secret which isn't enabled yet, it replace the previous pending secret.
+func (s *Store) SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	sealed, err := encrypt.Seal(fieldTOTPSecret, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled_at IS NULL", sealed, userID)
+	return err
+}
+
+func (s *Store) EnableTOTP(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled_at = NOW() WHERE id = ? AND totp_secret IS NOT NULL", userID); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.delUserCache(ctx, userID)
+	return nil
+}
+
+func (s *Store) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL WHERE id = ?", userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.delUserCache(ctx, userID)
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// the recovery code only can be used once.
+func (s *Store) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("invalid recovery code")
+	}
+
+	return nil
+}
+
+// the key live longer than the valid window of the code (3 steps of 30 seconds).
+func (s *Store) MarkTOTPStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	stepKey, err := utils.Redis2Key("totp_used", userID+":"+strconv.FormatInt(step, 10))
+	if err != nil {
+		return false, err
+	}
+
+	return s.rdb.SetNX(ctx, stepKey, 1, 2*time.Minute).Result()
+}
+
+// the 2fa status is inside the access token too, so bump the claims version and the client refresh it.
+func (s *Store) delUserCache(ctx context.Context, userID string) {
+	if userKey, err := utils.Redis2Key("user", userID); err == nil {
+		s.rdb.Del(ctx, userKey)
+	}
+
+	if versionKey, err := utils.Redis2Key("claims_version", userID); err == nil {
+		s.rdb.Incr(ctx, versionKey)
+	}
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO recovery_codes (id, user_id, code_hash) VALUES (?,?,?)")
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, h := range codeHashes {
+		if _, err := stmt.ExecContext(ctx, uuid.NewString(), userID, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,14 +3,19 @@ package user
 import (
 	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/audit"
+	"github.com/perpus_backend/pkg/cookie"
 	"github.com/perpus_backend/pkg/hash"
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/upload"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
@@ -21,13 +26,14 @@ import (
 )
 
 type Handler struct {
-	store types.UserStore
+	store        types.UserStore
+	attemptStore types.LoginAttemptStore
 
 	jwt *jwt.AuthJWT
 }
 
-func NewHandler(jwt *jwt.AuthJWT, store types.UserStore) *Handler {
-	return &Handler{store: store, jwt: jwt}
+func NewHandler(jwt *jwt.AuthJWT, store types.UserStore, attemptStore types.LoginAttemptStore) *Handler {
+	return &Handler{store: store, attemptStore: attemptStore, jwt: jwt}
 }
 
 const (
@@ -36,18 +42,33 @@ const (
 	filePublicPath = "./assets/public/images/profile/"
 
 	size1MB = 1 << 20
+
+	// the wrong current password is counted at the failed login of the account, so the stolen session
+	// can't guess the password here after the login is locked
+	passwordFailWindow = 15 * time.Minute
+	passwordLockTTL    = 15 * time.Minute
+	passwordLockAt     = 10
 )
 
+var errPasswordLocked = errors.New("too many wrong current password, try again later")
+
 func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/users", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetUsers, "admin"))).Methods(http.MethodGet)
+	users := audit.Target{Resource: "users", IDVar: "userID", Load: audit.Load(h.store.GetUserWithRolesByID)}
+
+	r.HandleFunc("/users", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetUsers, types.PermUsersAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleGetUserWithRolesByID, types.PermUsersAdmin))).Methods(http.MethodGet)
+
+	r.HandleFunc("/users", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleCreateUser, "users.create", users), types.PermUsersAdmin))).Methods(http.MethodPost)
 
-	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetUserWithRolesByID, "admin"))).Methods(http.MethodGet)
+	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleUpdateUser, "users.update", users), types.PermUsersAdmin))).Methods(http.MethodPut)
 
-	r.HandleFunc("/users", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleCreateUser, "admin"))).Methods(http.MethodPost)
+	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(audit.Log(h.handleDeleteUser, "users.delete", users), types.PermUsersAdmin))).Methods(http.MethodDelete)
 
-	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleUpdateUser, "admin"))).Methods(http.MethodPut)
+	// self-service, for every logged user
+	r.HandleFunc("/me", h.jwt.AuthWithJWTToken(h.handleUpdateProfile)).Methods(http.MethodPatch)
 
-	r.HandleFunc("/users/{userID}", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleDeleteUser, "admin"))).Methods(http.MethodDelete)
+	r.HandleFunc("/me/password", h.jwt.AuthWithJWTToken(h.handleChangePassword)).Methods(http.MethodPost)
 }
 
 func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
@@ -113,8 +134,7 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx = r.Context()
 
-		fileName, filePath string
-		sizeFile           int64
+		fileName string
 	)
 
 	r.Body = http.MaxBytesReader(w, r.Body, size1MB)
@@ -151,26 +171,15 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		defer file.Close()
 
-		randomString := xid.New().String()
-
-		ext := filepath.Ext(header.Filename)
-		sizeFile = header.Size
-
-		if sizeFile <= size1MB {
-			if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
-				fileName = randomString + ext
-				filePath = filePublicPath + fileName
-
-				dst, _ := os.Create(filePath)
-				defer dst.Close()
+		avatar, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
 
-				io.Copy(dst, file)
-			} else {
-				utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("only support jpg, jpeg, and png"))
-				return
-			}
-		} else {
-			utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("only serve file under 1mb"))
+		fileName, err = saveAvatar(avatar, "")
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
 	}
@@ -207,10 +216,7 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		userID = mux.Vars(r)["userID"]
 	)
 
-	var (
-		fileName, filePath string
-		sizeFile           int64
-	)
+	var fileName string
 
 	if r.Method != http.MethodPut {
 		utils.WriteJSONError(w, http.StatusMethodNotAllowed, errors.New("method doesn't allowed"))
@@ -281,36 +287,15 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		defer file.Close()
 
-		randomString := xid.New().String()
-
-		ext := filepath.Ext(header.Filename)
-		sizeFile = header.Size
-
-		if sizeFile <= size1MB {
-			if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
-
-				fileImgOld := filePublicPath + u.Avatar
-
-				info, err := os.Stat(fileImgOld)
-				if err == nil {
-					if !info.IsDir() {
-						os.Remove(fileImgOld) // for reason, to not delete the folder when file doesn't exist inside the dir
-					}
-				}
-
-				fileName = randomString + ext
-				filePath = filePublicPath + fileName
-
-				dst, _ := os.Create(filePath)
-				defer dst.Close()
+		avatar, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
 
-				io.Copy(dst, file)
-			} else {
-				utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("only support jpg, jpeg, and png"))
-				return
-			}
-		} else {
-			utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("only serve file under 1mb"))
+		fileName, err = saveAvatar(avatar, u.Avatar)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
 			return
 		}
 	}
@@ -377,3 +362,188 @@ func (h *Handler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		Status:  http.StatusText(cok),
 	})
 }
+
+// update name and avatar of the logged user, the email and roles only can be changed by admin.
+func (h *Handler) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, size1MB+size1MB/2) // the avatar, and the other fields
+
+	// the avatar is optional, so the urlencoded form is accepted too
+	if err := r.ParseMultipartForm(size1MB); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadUpdateProfile{
+		Name: strings.TrimSpace(r.FormValue("name")),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	u, err := h.store.GetUserWithRolesByID(ctx, jwt.GetUserIDFromContext(ctx))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	name, avatar := u.Name, u.Avatar
+
+	if payload.Name != "" {
+		name = payload.Name
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err == nil {
+		defer file.Close()
+
+		f, err := upload.Check(ctx, file, header, upload.Image, size1MB)
+		if err != nil {
+			utils.WriteJSONError(w, upload.StatusCode(err), err)
+			return
+		}
+
+		avatar, err = saveAvatar(f, u.Avatar)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if err := h.store.UpdateUserProfile(ctx, u.ID, name, avatar); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Data:    map[string]string{"name": name, "avatar": avatar},
+		Message: "Profile Updated!",
+		Status:  http.StatusText(cok),
+	})
+}
+
+// change the password of logged user. the token_version is bumped and every session is revoked,
+// then the new session is made for this device, so only the other devices must login again.
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload := types.SetPayloadChangePassword{
+		CurrentPassword: r.FormValue("current_password"),
+		Password:        r.FormValue("password"),
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteJSONError(w, http.StatusUnprocessableEntity, errors)
+		return
+	}
+
+	u, err := h.store.GetUserWithRolesByID(ctx, jwt.GetUserIDFromContext(ctx))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	account := strings.ToLower(u.Email)
+
+	locked, err := h.attemptStore.GetLockout(ctx, types.LockoutAccount, account)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if locked > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.Seconds()))))
+		utils.WriteJSONError(w, http.StatusTooManyRequests, errPasswordLocked)
+		return
+	}
+
+	// the cached user has no password, so get it by email
+	withPassword, err := h.store.GetUserWithRolesByEmail(ctx, u.Email)
+	if err != nil || !hash.CompareHashedPassword(withPassword.Password, []byte(payload.CurrentPassword)) {
+		fails, err := h.attemptStore.RecordFailedLogin(ctx, types.LockoutAccount, account, passwordFailWindow)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if fails >= passwordLockAt {
+			_ = h.attemptStore.LockLogin(ctx, types.LockoutAccount, account, passwordLockTTL)
+		}
+
+		utils.WriteJSONError(w, http.StatusBadRequest, fmt.Errorf("wrong current password"))
+		return
+	}
+
+	_ = h.attemptStore.ClearLoginAttempts(ctx, types.LockoutAccount, account)
+
+	hashPass, err := hash.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.UpdateUserPassword(ctx, u.ID, hashPass); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.jwt.RevokeUserSessions(ctx, u.ID); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	token, refreshToken, err := h.jwt.CreateSession(ctx, u.ID, &types.Session{
+		Device:    utils.DeviceFromUserAgent(r.UserAgent()),
+		IP:        utils.GetClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cookie.SetRefreshCookie(w, refreshToken, config.Env.RefreshTokenTTL)
+
+	utils.WriteJSON(w, cok, utils.JsonData{
+		Code:    cok,
+		Message: "Password Changed! The other sessions are logged out.",
+		Status:  http.StatusText(cok),
+		Token:   token,
+	})
+}
+
+// save the checked avatar with random name and remove the old one, it return the new file name.
+func saveAvatar(avatar *upload.File, oldAvatar string) (string, error) {
+	fileName := xid.New().String() + avatar.Ext
+
+	if err := avatar.Save(filePublicPath + fileName); err != nil {
+		return "", err
+	}
+
+	if oldAvatar != "" {
+		fileImgOld := filePublicPath + oldAvatar
+
+		// for reason, to not delete the folder when file doesn't exist inside the dir
+		if info, err := os.Stat(fileImgOld); err == nil && !info.IsDir() {
+			os.Remove(fileImgOld)
+		}
+	}
+
+	return fileName, nil
+}
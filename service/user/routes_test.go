@@ -2,11 +2,18 @@ package user
 
 import (
 	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/perpus_backend/pkg/hash"
 	"github.com/perpus_backend/pkg/jwt"
 	"github.com/perpus_backend/types"
 
@@ -17,7 +24,7 @@ func TestHandlerUser(t *testing.T) {
 	jwt := &jwt.AuthJWT{}
 	us := &types.MockUserStore{}
 
-	h := NewHandler(jwt, us)
+	h := NewHandler(jwt, us, types.MockLoginAttemptStore{})
 
 	t.Run("should get users", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/users", nil)
@@ -51,6 +58,8 @@ func TestHandlerUser(t *testing.T) {
 	})
 
 	t.Run("should be created user", func(t *testing.T) {
+		t.Chdir(t.TempDir()) // the avatar is saved at ./assets
+
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
@@ -69,7 +78,10 @@ func TestHandlerUser(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		file.Write([]byte("fake img content"))
+		// the content is checked, so it must be real image
+		if err := jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 1, 1)), nil); err != nil {
+			t.Fatal(err)
+		}
 
 		writer.Close()
 
@@ -88,4 +100,157 @@ func TestHandlerUser(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, w.Code)
 		}
 	})
+
+	t.Run("should fail update profile, because the name is too short", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "ab")
+
+		req := httptest.NewRequest(http.MethodPatch, "/me", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me", h.handleUpdateProfile).Methods(http.MethodPatch)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("should fail change password, because the new password is same as the current", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("current_password", "miko12345")
+		form.Add("password", "miko12345")
+
+		req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/password", h.handleChangePassword).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("should fail change password, because the current password is missing", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "newpassword")
+
+		req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/password", h.handleChangePassword).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		// t.Log(w.Body)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+}
+
+// user store which has one user with the password "miko12345".
+type passwordUserStore struct {
+	types.MockUserStore
+
+	password string
+}
+
+func (s *passwordUserStore) GetUserWithRolesByID(ctx context.Context, id string) (*types.User, error) {
+	return &types.User{ID: "user-1", Email: "Miko@example.com"}, nil
+}
+
+func (s *passwordUserStore) GetUserWithRolesByEmail(ctx context.Context, email string) (*types.User, error) {
+	return &types.User{ID: "user-1", Email: email, Password: s.password}, nil
+}
+
+// count the failed attempts in memory, it's locked until the test clear it.
+type countAttemptStore struct {
+	types.MockLoginAttemptStore
+
+	fails  map[string]int64
+	locked map[string]bool
+}
+
+func (s *countAttemptStore) GetLockout(ctx context.Context, kind, value string) (time.Duration, error) {
+	if s.locked[kind+":"+value] {
+		return time.Minute, nil
+	}
+
+	return 0, nil
+}
+
+func (s *countAttemptStore) RecordFailedLogin(ctx context.Context, kind, value string, window time.Duration) (int64, error) {
+	s.fails[kind+":"+value]++
+	return s.fails[kind+":"+value], nil
+}
+
+func (s *countAttemptStore) LockLogin(ctx context.Context, kind, value string, ttl time.Duration) error {
+	s.locked[kind+":"+value] = true
+	return nil
+}
+
+func TestHandlerChangePasswordLockout(t *testing.T) {
+	password, err := hash.HashPassword("miko12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := &countAttemptStore{fails: make(map[string]int64), locked: make(map[string]bool)}
+	h := NewHandler(&jwt.AuthJWT{}, &passwordUserStore{password: password}, attempts)
+
+	changePassword := func(current string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Add("current_password", current)
+		form.Add("password", "newpassword123")
+
+		req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/me/password", h.handleChangePassword).Methods(http.MethodPost)
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("it should count the wrong current password at the account", func(t *testing.T) {
+		for range passwordLockAt {
+			if w := changePassword("wrong-password"); w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		}
+
+		if got := attempts.fails[types.LockoutAccount+":miko@example.com"]; got != passwordLockAt {
+			t.Errorf("expected %d failures of the account, got %d", passwordLockAt, got)
+		}
+	})
+
+	t.Run("it should lock the account even with the right password", func(t *testing.T) {
+		w := changePassword("miko12345")
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+
+		if w.Header().Get("Retry-After") != "60" {
+			t.Errorf("expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+		}
+	})
 }
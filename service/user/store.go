@@ -53,6 +53,8 @@ func (s *Store) GetUsersWithPagination(ctx context.Context, page int) ([]*types.
 	u.password AS user_password, 
 	u.avatar AS user_avatar, 
 	u.token_version AS user_token_version, 
+	u.email_verified_at, 
+	u.totp_enabled_at IS NOT NULL AS two_factor_enabled, 
 	u.created_at, 
 	u.updated_at, 
 	r.id AS role_id, 
@@ -123,6 +125,8 @@ func (s *Store) GetUsersForSearch(ctx context.Context) []*types.User {
 	u.password AS user_password, 
 	u.avatar AS user_avatar, 
 	u.token_version AS user_token_version, 
+	u.email_verified_at, 
+	u.totp_enabled_at IS NOT NULL AS two_factor_enabled, 
 	u.created_at, 
 	u.updated_at, 
 	r.id AS role_id, 
@@ -201,10 +205,12 @@ func (s *Store) GetUserWithRolesByID(ctx context.Context, id string) (*types.Use
 		u.password AS user_password,
 		u.avatar AS user_avatar,
 		u.token_version AS user_token_version,
+		u.email_verified_at,
+		u.totp_enabled_at IS NOT NULL AS two_factor_enabled,
 		u.created_at,
 		u.updated_at,
-		GROUP_CONCAT(r.id SEPARATOR ', ') AS role_id,
-		GROUP_CONCAT(r.name SEPARATOR ', ') AS role_name
+		GROUP_CONCAT(r.id ORDER BY r.id SEPARATOR ', ') AS role_id,
+		GROUP_CONCAT(r.name ORDER BY r.id SEPARATOR ', ') AS role_name
 		FROM users u
 		LEFT JOIN role_user ru ON u.id = ru.user_id 
 		LEFT JOIN roles r ON ru.role_id = r.id
@@ -239,10 +245,12 @@ func (s *Store) GetUserWithRolesByEmail(ctx context.Context, email string) (*typ
 	u.password AS user_password,
 	u.avatar AS user_avatar,
 	u.token_version AS user_token_version,
+	u.email_verified_at,
+	u.totp_enabled_at IS NOT NULL AS two_factor_enabled,
 	u.created_at,
 	u.updated_at,
-	GROUP_CONCAT(r.id SEPARATOR ', ') AS role_id,
-	GROUP_CONCAT(r.name SEPARATOR ', ') AS role_name
+	GROUP_CONCAT(r.id ORDER BY r.id SEPARATOR ', ') AS role_id,
+	GROUP_CONCAT(r.name ORDER BY r.id SEPARATOR ', ') AS role_name
 	FROM users u
 	LEFT JOIN role_user ru ON u.id = ru.user_id 
 	LEFT JOIN roles r ON ru.role_id = r.id
@@ -299,6 +307,22 @@ func (s *Store) UpdateUser(ctx context.Context, id string, u *types.User) error
 	return err
 }
 
+// update by the user itself, the email and password is not touched.
+func (s *Store) UpdateUserProfile(ctx context.Context, id, name, avatar string) error {
+	userKey, err := utils.Redis2Key("user", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET name = ?, avatar = ? WHERE id = ?", name, avatar, id)
+	if err != nil {
+		return err
+	}
+
+	s.rdb.Del(ctx, userKey)
+	return nil
+}
+
 func (s *Store) DeleteUser(ctx context.Context, id string) error {
 	userKey, err := utils.Redis2Key("user", id)
 	if err != nil {
@@ -342,3 +366,43 @@ func (s *Store) IncrementTokenVersion(ctx context.Context, id, token string) err
 	s.rdb.Del(ctx, userKey, token)
 	return nil
 }
+
+func (s *Store) VerifyUserEmail(ctx context.Context, id string) error {
+	userKey, err := utils.Redis2Key("user", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET email_verified_at = NOW() WHERE id = ? AND email_verified_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	s.rdb.Del(ctx, userKey)
+	return nil
+}
+
+// change the password and bump token_version, so every token of the old password is revoked.
+func (s *Store) UpdateUserPassword(ctx context.Context, id, password string) error {
+	userKey, err := utils.Redis2Key("user", id)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?", password, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	s.rdb.Del(ctx, userKey)
+	return nil
+}
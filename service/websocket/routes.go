@@ -4,266 +4,75 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/perpus_backend/helper"
 	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
 	"github.com/perpus_backend/types"
 	"github.com/perpus_backend/utils"
 
 	"github.com/gorilla/mux"
-	"github.com/meilisearch/meilisearch-go"
 )
 
 type Handler struct {
-	us types.UserStore
-	rs types.RoleStore
-	ms types.MemberStore
-	bs types.BookStore
-	cs types.CirculationStore
+	indexer *search.Indexer
 
 	jwt *jwt.AuthJWT
 }
 
-func NewHandler(jwt *jwt.AuthJWT, us types.UserStore, rs types.RoleStore, ms types.MemberStore, bs types.BookStore, cs types.CirculationStore) *Handler {
+func NewHandler(jwt *jwt.AuthJWT, indexer *search.Indexer) *Handler {
 	return &Handler{
-		us:  us,
-		rs:  rs,
-		ms:  ms,
-		bs:  bs,
-		cs:  cs,
-		jwt: jwt,
+		indexer: indexer,
+		jwt:     jwt,
 	}
 }
 
-func (h *Handler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/search/users", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetSearchForUsers, "admin", "staff", "user"))).Methods(http.MethodGet)
-
-	r.HandleFunc("/search/roles", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetSearchForRoles, "admin", "staff", "user"))).Methods(http.MethodGet)
+const (
+	defaultLimit = 10
+	maxLimit     = 50
 
-	r.HandleFunc("/search/members", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetSearchForMembers, "admin", "staff", "user"))).Methods(http.MethodGet)
+	// hits per resource at global search
+	globalLimit = 5
+)
 
-	r.HandleFunc("/search/books", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetSearchForBooks, "admin", "staff", "user"))).Methods(http.MethodGet)
+// the order of resources at global search result.
+var resourceOrder = []string{"books", "members", "circulations", "users", "roles"}
 
-	r.HandleFunc("/search/circulations", h.jwt.AuthWithJWTToken(h.jwt.RoleGate(h.handleGetSearchForCirculations, "admin", "staff", "user"))).Methods(http.MethodGet)
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/search", h.jwt.AuthWithJWTToken(h.jwt.RequirePermission(h.handleSearch, types.PermSearch))).Methods(http.MethodGet)
 }
 
-var req types.SetPayloadQuery
-
-func (h *Handler) handleGetSearchForUsers(w http.ResponseWriter, r *http.Request) {
-	conn, err := utils.WSUpgrader.Upgrade(w, r, nil)
+// one socket for search all resources. every message has request id, and the newer query
+// on the same resource will cancel the older one which still running.
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	// the resources and attributes is by the permissions, same as the REST routes
+	permissions, err := h.jwt.GetPermissions(r.Context())
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
+		utils.WriteJSONError(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	// initial clientUser meilisearch
-	clientUser := utils.MSClient
-
-	// assert value users to records meili
-	users := h.us.GetUsersForSearch(context.Background())
-
-	err = helper.AddDocumentsWithWait(clientUser, "users", "id", users)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	defer conn.Close()
-
-	for {
-		if err := conn.ReadJSON(&req); err != nil {
-			conn.WriteJSON("error read payload json")
-			return
-		}
-
-		if len(req.QueryUser) < 1 {
-			conn.WriteJSON("data not found")
-			continue
-		}
-
-		res, err := clientUser.Index("users").Search(req.QueryUser, &meilisearch.SearchRequest{
-			Limit: 20,
-		})
-		if err != nil {
-			conn.WriteJSON(err.Error())
-			continue
-		}
-
-		conn.WriteJSON(res)
-	}
-}
-
-func (h *Handler) handleGetSearchForRoles(w http.ResponseWriter, r *http.Request) {
 	conn, err := utils.WSUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	// initial clientRole meili
-	clientRole := utils.MSClient
-
-	// assert value roles to records meili
-	roles, _ := h.rs.GetRoles(context.Background())
-
-	err = helper.AddDocumentsWithWait(clientRole, "roles", "id", roles)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
 	defer conn.Close()
 
-	for {
-		if err := conn.ReadJSON(&req); err != nil {
-			conn.WriteJSON("error read payload json")
-			return
-		}
-
-		if len(req.QueryRole) < 1 {
-			conn.WriteJSON("data not found")
-			continue
-		}
+	// request context has short timeout from auth middleware, so the socket use its own context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		res, err := clientRole.Index("roles").Search(req.QueryRole, &meilisearch.SearchRequest{
-			Limit: 10,
-		})
-		if err != nil {
-			conn.WriteJSON(err.Error())
-			continue
-		}
-
-		conn.WriteJSON(res)
-	}
-}
-
-func (h *Handler) handleGetSearchForMembers(w http.ResponseWriter, r *http.Request) {
-	conn, err := utils.WSUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	// initial clientMember meili
-	clientMember := utils.MSClient
-
-	// assert value members to records meili
-	members := h.ms.GetMembersForSearch(context.Background())
-
-	err = helper.AddDocumentsWithWait(clientMember, "members", "id", members)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	defer conn.Close()
+	s := newSession(h, conn, permissions)
+	defer s.cancelAll()
 
 	for {
-		if err := conn.ReadJSON(&req); err != nil {
-			conn.WriteJSON("error read payload json")
-			return
-		}
-
-		if len(req.QueryMember) < 1 {
-			conn.WriteJSON("data not found")
-			continue
-		}
-
-		res, err := clientMember.Index("members").Search(req.QueryMember, &meilisearch.SearchRequest{
-			Limit: 10,
-		})
-		if err != nil {
-			conn.WriteJSON(err.Error())
-			continue
-		}
-
-		conn.WriteJSON(res)
-	}
-}
-
-func (h *Handler) handleGetSearchForBooks(w http.ResponseWriter, r *http.Request) {
-	conn, err := utils.WSUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	// initial clientBook meili
-	clientBook := utils.MSClient
-
-	// assert value books to records meili
-	books := h.bs.GetBooksForSearch(context.Background())
-
-	err = helper.AddDocumentsWithWait(clientBook, "books", "id", books)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	defer conn.Close()
-
-	for {
-		if err := conn.ReadJSON(&req); err != nil {
-			conn.WriteJSON("error read payload json")
-			return
-		}
-
-		if len(req.QueryBook) < 1 {
-			conn.WriteJSON("data not found")
-			continue
-		}
-
-		res, err := clientBook.Index("books").Search(req.QueryBook, &meilisearch.SearchRequest{
-			Limit: 10,
-		})
-		if err != nil {
-			conn.WriteJSON(err.Error())
-			continue
-		}
-
-		conn.WriteJSON(res)
-	}
-}
-
-func (h *Handler) handleGetSearchForCirculations(w http.ResponseWriter, r *http.Request) {
-	conn, err := utils.WSUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
+		var req types.SetPayloadSearch // new every message, so the previous filter doesn't carry over
 
-	// initial clientCirc meili
-	clientCirc := utils.MSClient
-
-	// assert value circulations to records meili
-	circulations := h.cs.GetCirculationsForSearch(context.Background())
-
-	err = helper.AddDocumentsWithWait(clientCirc, "circulations", "id", circulations)
-	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	defer conn.Close()
-
-	for {
 		if err := conn.ReadJSON(&req); err != nil {
-			conn.WriteJSON("error read payload json")
+			s.write(types.SearchMessage{Type: "error", Error: "error read payload json"})
 			return
 		}
 
-		if len(req.QueryCirculation) < 1 {
-			conn.WriteJSON("data not found")
-			continue
-		}
-
-		res, err := clientCirc.Index("circulations").Search(req.QueryCirculation, &meilisearch.SearchRequest{
-			Limit: 10,
-		})
-		if err != nil {
-			conn.WriteJSON(err.Error())
-			continue
-		}
-
-		conn.WriteJSON(res)
+		s.handle(ctx, req)
 	}
 }
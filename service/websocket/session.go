@@ -0,0 +1,227 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// state of one search socket.
+type session struct {
+	h           *Handler
+	conn        *websocket.Conn
+	permissions []string
+
+	writeMu sync.Mutex // websocket only allow one writer at a time
+
+	mu       sync.Mutex
+	inflight map[string]*query // slot (resource name or "global") -> running query
+}
+
+type query struct {
+	id     string
+	cancel context.CancelFunc
+}
+
+func newSession(h *Handler, conn *websocket.Conn, permissions []string) *session {
+	return &session{
+		h:           h,
+		conn:        conn,
+		permissions: permissions,
+		inflight:    make(map[string]*query),
+	}
+}
+
+func (s *session) write(msg types.SearchMessage) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.WriteJSON(msg)
+}
+
+func (s *session) writeError(id string, err error) {
+	s.write(types.SearchMessage{ID: id, Type: "error", Error: err.Error()})
+}
+
+func (s *session) handle(ctx context.Context, req types.SetPayloadSearch) {
+	switch req.Type {
+	case "search":
+		if !s.canSee(req.Resource) {
+			s.writeError(req.ID, fmt.Errorf("resource %s is not allowed", req.Resource))
+			return
+		}
+
+		qctx := s.start(ctx, req.Resource, req.ID)
+		go s.runSearch(qctx, req)
+	case "global":
+		qctx := s.start(ctx, "global", req.ID)
+		go s.runGlobal(qctx, req)
+	case "cancel":
+		s.cancelByID(req.ID)
+	default:
+		s.writeError(req.ID, fmt.Errorf("invalid type: %s", req.Type))
+	}
+}
+
+// register new query at the slot, and cancel the older one which still running.
+func (s *session) start(ctx context.Context, slot, id string) context.Context {
+	qctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, exists := s.inflight[slot]; exists {
+		old.cancel()
+	}
+
+	s.inflight[slot] = &query{id: id, cancel: cancel}
+
+	return qctx
+}
+
+// remove query from the slot when it done, only when it still the same query.
+func (s *session) finish(slot, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, exists := s.inflight[slot]; exists && q.id == id {
+		q.cancel()
+		delete(s.inflight, slot)
+	}
+}
+
+func (s *session) cancelByID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for slot, q := range s.inflight {
+		if q.id == id {
+			q.cancel()
+			delete(s.inflight, slot)
+		}
+	}
+}
+
+func (s *session) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for slot, q := range s.inflight {
+		q.cancel()
+		delete(s.inflight, slot)
+	}
+}
+
+func (s *session) canSee(name string) bool {
+	res, exists := s.h.indexer.Resource(name)
+	return exists && res.VisibleTo(s.permissions)
+}
+
+func (s *session) runSearch(ctx context.Context, req types.SetPayloadSearch) {
+	defer s.finish(req.Resource, req.ID)
+
+	// empty query is allowed when there is filter, for browse the catalogue by filter sidebar
+	if len(req.Query) < 1 && (req.Resource != "books" || req.BookFilter.IsEmpty()) {
+		s.writeError(req.ID, errors.New("data not found"))
+		return
+	}
+
+	page, limit := pagination(req.Page, req.Limit)
+
+	searchReq := &types.SearchRequest{
+		Sort:   req.Sort,
+		Facets: req.Facets,
+		Limit:  int64(limit),
+		Offset: int64((page - 1) * limit),
+	}
+
+	if req.Resource == "books" {
+		searchReq.Filters = req.BookFilter.ToSearchFilters()
+	}
+
+	res, err := s.h.indexer.Search(ctx, req.Resource, s.permissions, req.Query, searchReq)
+
+	// the query was replaced by the newer one or cancelled, so the result is stale
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		s.writeError(req.ID, err)
+		return
+	}
+
+	s.write(types.SearchMessage{
+		Data:     res,
+		ID:       req.ID,
+		Type:     "result",
+		Resource: req.Resource,
+		Page:     page,
+		LastPage: int64(math.Ceil(float64(res.EstimatedTotalHits) / float64(limit))),
+	})
+}
+
+// search all resources the caller allowed to see, and group the hits by resource.
+func (s *session) runGlobal(ctx context.Context, req types.SetPayloadSearch) {
+	defer s.finish("global", req.ID)
+
+	if len(req.Query) < 1 {
+		s.writeError(req.ID, errors.New("data not found"))
+		return
+	}
+
+	_, limit := pagination(1, req.Limit)
+	if req.Limit < 1 {
+		limit = globalLimit
+	}
+
+	groups := make(map[string]*types.SearchResult)
+
+	for _, name := range resourceOrder {
+		if !s.canSee(name) {
+			continue
+		}
+
+		res, err := s.h.indexer.Search(ctx, name, s.permissions, req.Query, &types.SearchRequest{
+			Limit: int64(limit),
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			s.writeError(req.ID, err)
+			return
+		}
+
+		groups[name] = res
+	}
+
+	s.write(types.SearchMessage{
+		Data: groups,
+		ID:   req.ID,
+		Type: "global",
+	})
+}
+
+func pagination(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+
+	if limit < 1 {
+		limit = defaultLimit
+	}
+
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	return page, limit
+}
@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perpus_backend/pkg/jwt"
+	"github.com/perpus_backend/pkg/search"
+	"github.com/perpus_backend/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// permission store which give the default permissions of the built-in roles, the role id is the name.
+type defaultPermissionStore struct {
+	types.MockPermissionStore
+}
+
+func (s defaultPermissionStore) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []string) (map[string][]string, error) {
+	granted := make(map[string][]string)
+
+	for _, id := range roleIDs {
+		granted[id] = types.DefaultRolePermissions[id]
+	}
+
+	return granted, nil
+}
+
+// the query "lambat" wait until it's cancelled, so the test can cancel the running query.
+type slowIndex struct {
+	*search.MemoryIndex
+
+	cancelled chan struct{}
+}
+
+func (i slowIndex) Search(ctx context.Context, index, query string, req *types.SearchRequest) (*types.SearchResult, error) {
+	if query == "lambat" {
+		<-ctx.Done()
+		i.cancelled <- struct{}{}
+
+		return nil, ctx.Err()
+	}
+
+	return i.MemoryIndex.Search(ctx, index, query, req)
+}
+
+func newTestHandler(t *testing.T, index slowIndex) *Handler {
+	t.Helper()
+
+	books := make([]*types.Book, 0, 5)
+	for i := 1; i <= 5; i++ {
+		books = append(books, &types.Book{ID: fmt.Sprintf("book-%d", i), JudulBuku: fmt.Sprintf("Pemrograman Go %d", i)})
+	}
+
+	resources := map[string]search.Resource{
+		"books": {
+			Permission: types.PermBooksRead,
+			Indexed:    []string{"id", "judul_buku"},
+			Docs:       func(ctx context.Context) any { return books },
+		},
+		"members": {
+			Permission: types.PermMembersRead,
+			Indexed:    []string{"id", "nama"},
+			Docs:       func(ctx context.Context) any { return []*types.Member{{ID: "member-1", Nama: "Budi Santoso"}} },
+		},
+	}
+
+	j := jwt.NewAuthJWT(types.MockUserStore{}, types.MockSessionStore{}, defaultPermissionStore{}, types.MockAPIKeyStore{}, nil, nil)
+
+	return NewHandler(j, search.NewIndexer(index, resources, time.Minute))
+}
+
+// connect to /search as the user with the role, the claims is set instead of the auth middleware.
+func dial(t *testing.T, h *Handler, role string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jwt.WithClaims(r.Context(), &jwt.Claims{UserID: "user-1", Roles: []string{role}, RoleIDs: []string{role}, TwoFactor: true})
+		h.jwt.RequirePermission(h.handleSearch, types.PermSearch)(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+
+	return conn, resp, err
+}
+
+func send(t *testing.T, conn *websocket.Conn, req types.SetPayloadSearch) {
+	t.Helper()
+
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testMessage struct {
+	types.SearchMessage
+
+	Data *types.SearchResult `json:"data"`
+}
+
+func read(t *testing.T, conn *websocket.Conn) testMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var msg testMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func waitCancelled(t *testing.T, index slowIndex) {
+	t.Helper()
+
+	select {
+	case <-index.cancelled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the running query is cancelled")
+	}
+}
+
+func TestSession(t *testing.T) {
+	index := slowIndex{MemoryIndex: search.NewMemoryIndex(), cancelled: make(chan struct{}, 1)}
+	h := newTestHandler(t, index)
+
+	t.Run("it should reject the user without search permission", func(t *testing.T) {
+		_, resp, err := dial(t, h, "guest")
+		if err == nil {
+			t.Fatal("expected the handshake is rejected")
+		}
+
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %v", http.StatusForbidden, resp)
+		}
+	})
+
+	t.Run("it should echo the request id", func(t *testing.T) {
+		conn, _, err := dial(t, h, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-1", Type: "search", Resource: "books", Query: "pemrograman"})
+
+		msg := read(t, conn)
+		if msg.ID != "req-1" || msg.Type != "result" || msg.Resource != "books" {
+			t.Errorf("expected the result of req-1, got %+v", msg.SearchMessage)
+		}
+	})
+
+	t.Run("it should reject the resource which isn't visible", func(t *testing.T) {
+		conn, _, err := dial(t, h, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-2", Type: "search", Resource: "members", Query: "budi"})
+
+		msg := read(t, conn)
+		if msg.ID != "req-2" || msg.Type != "error" || msg.Error != "resource members is not allowed" {
+			t.Errorf("expected the error of req-2, got %+v", msg.SearchMessage)
+		}
+	})
+
+	t.Run("it should allow the resource by the permission", func(t *testing.T) {
+		conn, _, err := dial(t, h, "staff")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-3", Type: "search", Resource: "members", Query: "budi"})
+
+		msg := read(t, conn)
+		if msg.ID != "req-3" || msg.Type != "result" || msg.Data == nil || len(msg.Data.Hits) != 1 {
+			t.Errorf("expected one member at req-3, got %+v", msg.SearchMessage)
+		}
+	})
+
+	t.Run("it should paginate the result", func(t *testing.T) {
+		conn, _, err := dial(t, h, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-4", Type: "search", Resource: "books", Query: "pemrograman", Page: 3, Limit: 2})
+
+		msg := read(t, conn)
+		if msg.Page != 3 || msg.LastPage != 3 {
+			t.Errorf("expected page 3 of 3, got %d of %d", msg.Page, msg.LastPage)
+		}
+
+		if msg.Data == nil || len(msg.Data.Hits) != 1 || msg.Data.Offset != 4 {
+			t.Errorf("expected the last book at offset 4, got %+v", msg.Data)
+		}
+	})
+
+	t.Run("it should cancel the running query", func(t *testing.T) {
+		conn, _, err := dial(t, h, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-5", Type: "search", Resource: "books", Query: "lambat"})
+		send(t, conn, types.SetPayloadSearch{ID: "req-5", Type: "cancel"})
+
+		waitCancelled(t, index)
+
+		// the cancelled query doesn't send anything, so the next message is the other query
+		send(t, conn, types.SetPayloadSearch{ID: "req-6", Type: "search", Resource: "books", Query: "pemrograman"})
+
+		if msg := read(t, conn); msg.ID != "req-6" {
+			t.Errorf("expected the result of req-6, got %+v", msg.SearchMessage)
+		}
+	})
+
+	t.Run("it should cancel the older query of the same resource", func(t *testing.T) {
+		conn, _, err := dial(t, h, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send(t, conn, types.SetPayloadSearch{ID: "req-7", Type: "search", Resource: "books", Query: "lambat"})
+		send(t, conn, types.SetPayloadSearch{ID: "req-8", Type: "search", Resource: "books", Query: "pemrograman"})
+
+		waitCancelled(t, index)
+
+		if msg := read(t, conn); msg.ID != "req-8" {
+			t.Errorf("expected the result of req-8, got %+v", msg.SearchMessage)
+		}
+	})
+}
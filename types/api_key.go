@@ -0,0 +1,38 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// key for machine client (kiosk, integration script), sent as "Authorization: ApiKey <key>".
+// only the hash is saved, the raw key is shown once when it's created.
+type APIKey struct {
+	CreatedAt  time.Time  `json:"created_at,omitzero"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"` // first characters of the key, for recognize it at the list
+	KeyHash   string `json:"-"`
+	CreatedBy string `json:"created_by"`
+
+	Permissions []string `json:"permissions"`
+}
+
+type APIKeyStore interface {
+	GetAPIKeys(ctx context.Context) ([]*APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+
+	CreateAPIKey(ctx context.Context, k *APIKey) error
+	RevokeAPIKey(ctx context.Context, id string) error
+	TouchAPIKey(ctx context.Context, id string) error
+}
+
+type SetPayloadAPIKey struct {
+	Name        string   `form:"name" validate:"required,min=3,max=100"`
+	Permissions []string `form:"permissions" validate:"required,min=1,dive,required"`
+	ExpiresAt   string   `form:"expires_at" validate:"omitempty,datetime=2006-01-02"`
+}
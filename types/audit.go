@@ -0,0 +1,53 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// one administrative action, the audit_log table is append-only. the rows only deleted by the retention.
+type AuditLog struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+
+	ID     int64 `json:"id"`
+	Status int   `json:"status"` // http status of the action
+
+	ActorID    string `json:"actor_id"`
+	ActorType  string `json:"actor_type"` // "user" or "api_key"
+	Action     string `json:"action"`     // ex: books.update
+	Resource   string `json:"resource"`
+	ResourceID string `json:"resource_id"`
+	IP         string `json:"ip"`
+	RequestID  string `json:"request_id"`
+
+	Changes map[string]AuditChange `json:"changes"` // only the changed fields
+}
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// the empty field is not filtered.
+type AuditFilter struct {
+	From, To *time.Time
+
+	ActorID, Action, Resource, ResourceID string
+}
+
+type AuditStore interface {
+	GetAuditLogs(ctx context.Context, f *AuditFilter, page int) ([]*AuditLog, int64, error)
+	CreateAuditLog(ctx context.Context, l *AuditLog) error
+
+	// for the retention, it return how many rows are deleted.
+	DeleteAuditLogsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SetPayloadAuditFilter struct {
+	ActorID    string `form:"actor_id" validate:"omitempty,max=64"`
+	Action     string `form:"action" validate:"omitempty,max=64"`
+	Resource   string `form:"resource" validate:"omitempty,max=64"`
+	ResourceID string `form:"resource_id" validate:"omitempty,max=64"`
+	From       string `form:"from" validate:"omitempty,datetime=2006-01-02"`
+	To         string `form:"to" validate:"omitempty,datetime=2006-01-02"`
+}
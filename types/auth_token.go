@@ -0,0 +1,47 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// purpose of the single-use token, the token of one purpose can't be used for the other one.
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+	TokenTwoFactor         = "two_factor" // challenge between the password and the 2fa code at login
+)
+
+type AuthTokenStore interface {
+	CreateAuthToken(ctx context.Context, purpose, userID string, ttl time.Duration) (string, error)
+	// return the user id of the token, and delete it so it can't be used again.
+	ConsumeAuthToken(ctx context.Context, purpose, token string) (string, error)
+	// delete every outstanding token of the user for the purpose, ex: the older reset links.
+	RevokeAuthTokens(ctx context.Context, purpose, userID string) error
+}
+
+// kind of failed login counter
+const (
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+type Lockout struct {
+	LockedUntil time.Time `json:"locked_until"`
+
+	Kind  string `json:"kind"`  // account or ip
+	Value string `json:"value"` // the email or the ip address
+
+	Failures int64 `json:"failures"`
+}
+
+type LoginAttemptStore interface {
+	// remaining lockout time, zero when it isn't locked.
+	GetLockout(ctx context.Context, kind, value string) (time.Duration, error)
+	GetLockouts(ctx context.Context) ([]*Lockout, error)
+
+	// increment the failed counter inside the window, and return the count.
+	RecordFailedLogin(ctx context.Context, kind, value string, window time.Duration) (int64, error)
+	LockLogin(ctx context.Context, kind, value string, ttl time.Duration) error
+	ClearLoginAttempts(ctx context.Context, kind, value string) error
+}
@@ -16,8 +16,12 @@ type Book struct {
 	BukuPDF   string `json:"buku_pdf,omitempty"`   // pdf
 	Penulis   string `json:"penulis,omitempty"`
 	Pengarang string `json:"pengarang,omitempty"`
+	Kategori  string `json:"kategori,omitempty"`
+	Bahasa    string `json:"bahasa,omitempty"`
 
 	Tahun int `json:"tahun,omitempty"`
+
+	Tersedia bool `json:"tersedia"` // false when the book is being borrowed in circulations
 }
 
 type BookStore interface {
@@ -37,6 +41,8 @@ type SetPayloadBook struct {
 	Penulis   string `form:"penulis" validate:"required"`
 	Pengarang string `form:"pengarang" validate:"required"`
 	Tahun     string `form:"tahun" validate:"required,min=2"`
+	Kategori  string `form:"kategori" validate:"omitempty,max=100"`
+	Bahasa    string `form:"bahasa" validate:"omitempty,max=50"`
 }
 
 type SetPayloadUpdateBook struct {
@@ -44,4 +50,6 @@ type SetPayloadUpdateBook struct {
 	Penulis   string `form:"penulis" validate:"omitempty,required"`
 	Pengarang string `form:"pengarang" validate:"omitempty,required"`
 	Tahun     string `form:"tahun" validate:"omitempty,required,min=2"`
+	Kategori  string `form:"kategori" validate:"omitempty,max=100"`
+	Bahasa    string `form:"bahasa" validate:"omitempty,max=50"`
 }
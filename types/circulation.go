@@ -12,9 +12,11 @@ type Circulation struct {
 	JatuhTempo    time.Time `json:"jatuh_tempo"`
 
 	ID       string `json:"id"`
-	BukuID   string `json:"buku_id"` // relation
-	IdSKL    string `json:"id_skl"`  // slug type
-	Peminjam string `json:"peminjam"`
+	BukuID   string `json:"buku_id"`  // relation
+	IdSKL    string `json:"id_skl"`   // slug type
+	Peminjam string `json:"peminjam"` // id_anggota of the member
+
+	MemberID *string `json:"member_id"` // relation, null at the old circulation which LinkCirculations can't link
 
 	Denda float64 `json:"denda"`
 
@@ -26,7 +28,7 @@ type CirculationStore interface {
 	GetCirculationsForSearch(ctx context.Context) []*Circulation
 
 	GetCirculationByID(ctx context.Context, id string) (*Circulation, error)
-	GetCirculationByPeminjam(ctx context.Context, borrowerName string) (*Circulation, error)
+	GetCirculationByMemberID(ctx context.Context, memberID string) (*Circulation, error)
 
 	CreateCirculation(ctx context.Context, c *Circulation) error
 	UpdateCirculation(ctx context.Context, id string, c *Circulation) error
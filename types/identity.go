@@ -0,0 +1,32 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// external account from the identity provider (OIDC), linked to our user.
+type Identity struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	Provider string `json:"provider"` // issuer url of the identity provider
+	Subject  string `json:"subject"`  // "sub" claim, unique per provider
+	Email    string `json:"email"`
+}
+
+// saved between the redirect to identity provider and the callback.
+type SSOLoginState struct {
+	Verifier string `json:"verifier"` // pkce code verifier
+	Nonce    string `json:"nonce"`
+	Device   string `json:"device"`
+}
+
+type SSOStore interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*Identity, error)
+	CreateIdentity(ctx context.Context, i *Identity) error
+
+	SaveLoginState(ctx context.Context, state string, s *SSOLoginState, ttl time.Duration) error
+	ConsumeLoginState(ctx context.Context, state string) (*SSOLoginState, error)
+}
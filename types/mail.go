@@ -0,0 +1,14 @@
+package types
+
+import "context"
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// mail delivery, smtp for production or file/log for local development.
+type MailSender interface {
+	Send(ctx context.Context, m *Mail) error
+}
@@ -2,9 +2,18 @@ package types
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	// the name is matched by more than one member, so the member must be chosen by id_anggota.
+	ErrAmbiguousMember = errors.New("the name is matched by more than one member, use id_anggota")
+	// the circulations before member_id has the name of the member, but it can't be linked to one member.
+	ErrUnlinkedLoans = errors.New("the member has circulations by name which isn't linked, update the peminjam with id_anggota first")
+)
+
 type Member struct {
 	CreatedAt time.Time `json:"created_at,omitzero"`
 	UpdatedAt time.Time `json:"updated_at,omitzero"`
@@ -16,6 +25,48 @@ type Member struct {
 	Kelas         string `json:"kelas"`
 	NoTelepon     string `json:"no_telepon"`
 	ProfilAnggota string `json:"profil_anggota"` // image type
+
+	TanggalLahir *time.Time `json:"tanggal_lahir"` // date type, null when it's unknown
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+
+	Status        string     `json:"status"`         // enum type, see MemberStatuses
+	BerlakuSampai *time.Time `json:"berlaku_sampai"` // date type, null when the membership has no end
+}
+
+const (
+	MemberStatusActive    = "active"
+	MemberStatusSuspended = "suspended"
+	MemberStatusExpired   = "expired"
+	MemberStatusGraduated = "graduated"
+)
+
+var MemberStatuses = []string{MemberStatusActive, MemberStatusSuspended, MemberStatusExpired, MemberStatusGraduated}
+
+// age of adult, the name of the younger member is encrypted.
+const AdultAge = 18
+
+// the member without tanggal_lahir is treated as minor, most of the members are students.
+func (m *Member) IsMinor() bool {
+	if m.TanggalLahir == nil {
+		return true
+	}
+
+	return time.Now().Before(m.TanggalLahir.AddDate(AdultAge, 0, 0))
+}
+
+// only the active member can borrow. berlaku_sampai is checked too, so the member is blocked before the expiry job is run.
+// the anonymized member keep its status for the statistics, but it can't borrow anymore.
+func (m *Member) IsActive() bool {
+	if m.Status != MemberStatusActive || m.AnonymizedAt != nil {
+		return false
+	}
+
+	if m.BerlakuSampai == nil {
+		return true
+	}
+
+	// the date is compared as the text, the last day is still valid
+	return m.BerlakuSampai.Format(time.DateOnly) >= time.Now().Format(time.DateOnly)
 }
 
 type MemberStore interface {
@@ -23,24 +74,60 @@ type MemberStore interface {
 	GetMembersForSearch(ctx context.Context) []*Member
 
 	GetMemberByID(ctx context.Context, id string) (*Member, error)
+	// ErrAmbiguousMember when the name is matched by more than one member.
 	GetMemberByNama(ctx context.Context, nama string) (*Member, error)
 	GetMemberByNoTelepon(ctx context.Context, no_phone string) (*Member, error)
+	GetMemberByIdAnggota(ctx context.Context, idAnggota string) (*Member, error)
 
 	CreateMember(ctx context.Context, m *Member) error
 	UpdateMember(ctx context.Context, id string, m *Member) error
 	DeleteMember(ctx context.Context, id string) error
+
+	// the circulations which is linked by member_id. ErrUnlinkedLoans when the circulations by name isn't linked yet.
+	GetMemberLoans(ctx context.Context, m *Member) ([]*Circulation, error)
+	// scrub the personal data, the circulations is kept with pseudonym. it return the ids of changed circulations.
+	// ErrUnlinkedLoans same as GetMemberLoans.
+	AnonymizeMember(ctx context.Context, m *Member) ([]string, error)
+
+	// the bulk operations of the lifecycle, it return the number of changed members.
+	// kelas "XII" is matched with "XII" and "XII IPA 1", but not "XI".
+	GraduateMembers(ctx context.Context, kelas string) (int64, error)
+	// the active and the expired members is renewed, the suspended and the graduated is kept. empty kelas is all of the members.
+	RenewMembers(ctx context.Context, kelas string, berlakuSampai time.Time) (int64, error)
+	// the active members which berlaku_sampai is before today is expired.
+	ExpireMembers(ctx context.Context) (int64, error)
+}
+
+// the fines of the member at export archive.
+type MemberFines struct {
+	Total float64        `json:"total"`
+	Items []*Circulation `json:"items"`
 }
 
 type SetPayloadMember struct {
-	Nama         string `form:"nama" validate:"required"`
-	JenisKelamin string `form:"jenis_kelamin" validate:"required"`
-	Kelas        string `form:"kelas" validate:"required"`
-	NoTelepon    string `form:"no_telepon" validate:"required,min=6"`
+	Nama          string `form:"nama" validate:"required"`
+	JenisKelamin  string `form:"jenis_kelamin" validate:"required"`
+	Kelas         string `form:"kelas" validate:"required"`
+	NoTelepon     string `form:"no_telepon" validate:"required,min=6"`
+	TanggalLahir  string `form:"tanggal_lahir" validate:"omitempty,datetime=2006-01-02"`
+	BerlakuSampai string `form:"berlaku_sampai" validate:"omitempty,datetime=2006-01-02"`
 }
 
 type SetPayloadUpdateMember struct {
-	Nama         string `form:"nama" validate:"omitempty,required"`
-	JenisKelamin string `form:"jenis_kelamin" validate:"omitempty,required"`
-	Kelas        string `form:"kelas" validate:"omitempty,required"`
-	NoTelepon    string `form:"no_telepon" validate:"omitempty,required,min=6"`
+	Nama          string `form:"nama" validate:"omitempty,required"`
+	JenisKelamin  string `form:"jenis_kelamin" validate:"omitempty,required"`
+	Kelas         string `form:"kelas" validate:"omitempty,required"`
+	NoTelepon     string `form:"no_telepon" validate:"omitempty,required,min=6"`
+	TanggalLahir  string `form:"tanggal_lahir" validate:"omitempty,datetime=2006-01-02"`
+	BerlakuSampai string `form:"berlaku_sampai" validate:"omitempty,datetime=2006-01-02"`
+	Status        string `form:"status" validate:"omitempty,oneof=active suspended expired graduated"`
+}
+
+type SetPayloadGraduateMembers struct {
+	Kelas string `form:"kelas" validate:"required"`
+}
+
+type SetPayloadRenewMembers struct {
+	Kelas         string `form:"kelas"`
+	BerlakuSampai string `form:"berlaku_sampai" validate:"required,datetime=2006-01-02"`
 }
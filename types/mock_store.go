@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // mock user store for test purpose
@@ -40,6 +41,18 @@ func (m MockUserStore) IncrementTokenVersion(ctx context.Context, id, token stri
 	return nil
 }
 
+func (m MockUserStore) VerifyUserEmail(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m MockUserStore) UpdateUserPassword(ctx context.Context, id, password string) error {
+	return nil
+}
+
+func (m MockUserStore) UpdateUserProfile(ctx context.Context, id, name, avatar string) error {
+	return nil
+}
+
 // mock role & user store for test purpose
 type MockRoleUserStore struct{}
 
@@ -96,11 +109,11 @@ func (m MockMemberStore) GetMemberByID(ctx context.Context, id string) (*Member,
 }
 
 func (m MockMemberStore) GetMemberByNama(ctx context.Context, nama string) (*Member, error) {
-	return nil, fmt.Errorf("member not found")
+	return nil, ErrMemberNotFound
 }
 
 func (m MockMemberStore) GetMemberByNoTelepon(ctx context.Context, no_phone string) (*Member, error) {
-	return nil, fmt.Errorf("member not found")
+	return nil, ErrMemberNotFound
 }
 
 func (mm MockMemberStore) CreateMember(ctx context.Context, m *Member) error {
@@ -115,6 +128,30 @@ func (m MockMemberStore) DeleteMember(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m MockMemberStore) GetMemberLoans(ctx context.Context, member *Member) ([]*Circulation, error) {
+	return nil, nil
+}
+
+func (m MockMemberStore) GetMemberByIdAnggota(ctx context.Context, idAnggota string) (*Member, error) {
+	return nil, ErrMemberNotFound
+}
+
+func (m MockMemberStore) AnonymizeMember(ctx context.Context, member *Member) ([]string, error) {
+	return nil, nil
+}
+
+func (m MockMemberStore) GraduateMembers(ctx context.Context, kelas string) (int64, error) {
+	return 0, nil
+}
+
+func (m MockMemberStore) RenewMembers(ctx context.Context, kelas string, berlakuSampai time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m MockMemberStore) ExpireMembers(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
 type MockCirculationStore struct{}
 
 func (m MockCirculationStore) GetCirculationsWithPagination(ctx context.Context, page int) ([]*Circulation, int64, error) {
@@ -129,7 +166,7 @@ func (m MockCirculationStore) GetCirculationByID(ctx context.Context, id string)
 	return nil, nil
 }
 
-func (m MockCirculationStore) GetCirculationByPeminjam(ctx context.Context, borrowerName string) (*Circulation, error) {
+func (m MockCirculationStore) GetCirculationByMemberID(ctx context.Context, memberID string) (*Circulation, error) {
 	return nil, fmt.Errorf("circulation not found")
 }
 
@@ -174,3 +211,185 @@ func (m MockBookStore) UpdateBook(ctx context.Context, id string, b *Book) error
 func (m MockBookStore) DeleteBook(ctx context.Context, id string) error {
 	return nil
 }
+
+// mock suggest store for test purpose
+type MockSuggestStore struct{}
+
+func (m MockSuggestStore) GetSuggestions(ctx context.Context, key string) ([]*Suggestion, error) {
+	return nil, fmt.Errorf("suggestions not found")
+}
+
+func (m MockSuggestStore) SetSuggestions(ctx context.Context, key string, s []*Suggestion) error {
+	return nil
+}
+
+// mock session store for test purpose
+type MockSessionStore struct{}
+
+func (m MockSessionStore) GetSessionsByUserID(ctx context.Context, userID string) ([]*Session, error) {
+	return nil, nil
+}
+
+func (m MockSessionStore) GetSessionByID(ctx context.Context, id string) (*Session, error) {
+	return nil, fmt.Errorf("session not found")
+}
+
+func (m MockSessionStore) CreateSession(ctx context.Context, s *Session, ttl time.Duration) error {
+	return nil
+}
+
+func (m MockSessionStore) ExtendSession(ctx context.Context, id string, ttl time.Duration) error {
+	return nil
+}
+
+func (m MockSessionStore) TouchSession(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m MockSessionStore) DeleteSession(ctx context.Context, id string) error {
+	return nil
+}
+
+// mock auth token store for test purpose
+type MockAuthTokenStore struct{}
+
+func (m MockAuthTokenStore) CreateAuthToken(ctx context.Context, purpose, userID string, ttl time.Duration) (string, error) {
+	return "token", nil
+}
+
+func (m MockAuthTokenStore) ConsumeAuthToken(ctx context.Context, purpose, token string) (string, error) {
+	return "", fmt.Errorf("invalid or expired token")
+}
+
+func (m MockAuthTokenStore) RevokeAuthTokens(ctx context.Context, purpose, userID string) error {
+	return nil
+}
+
+// mock mail sender for test purpose
+type MockMailSender struct{}
+
+func (m MockMailSender) Send(ctx context.Context, mail *Mail) error {
+	return nil
+}
+
+// mock login attempt store for test purpose
+type MockLoginAttemptStore struct{}
+
+func (m MockLoginAttemptStore) GetLockout(ctx context.Context, kind, value string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m MockLoginAttemptStore) GetLockouts(ctx context.Context) ([]*Lockout, error) {
+	return nil, nil
+}
+
+func (m MockLoginAttemptStore) RecordFailedLogin(ctx context.Context, kind, value string, window time.Duration) (int64, error) {
+	return 1, nil
+}
+
+func (m MockLoginAttemptStore) LockLogin(ctx context.Context, kind, value string, ttl time.Duration) error {
+	return nil
+}
+
+func (m MockLoginAttemptStore) ClearLoginAttempts(ctx context.Context, kind, value string) error {
+	return nil
+}
+
+// mock two factor store for test purpose
+type MockTwoFactorStore struct{}
+
+func (m MockTwoFactorStore) GetTOTPSecret(ctx context.Context, userID string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (m MockTwoFactorStore) SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	return nil
+}
+
+func (m MockTwoFactorStore) EnableTOTP(ctx context.Context, userID string, codeHashes []string) error {
+	return nil
+}
+
+func (m MockTwoFactorStore) DisableTOTP(ctx context.Context, userID string) error {
+	return nil
+}
+
+func (m MockTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	return nil
+}
+
+func (m MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	return fmt.Errorf("invalid recovery code")
+}
+
+func (m MockTwoFactorStore) MarkTOTPStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	return true, nil
+}
+
+// mock sso store for test purpose
+type MockSSOStore struct{}
+
+func (m MockSSOStore) GetIdentity(ctx context.Context, provider, subject string) (*Identity, error) {
+	return nil, fmt.Errorf("identity not found")
+}
+
+func (m MockSSOStore) CreateIdentity(ctx context.Context, i *Identity) error {
+	return nil
+}
+
+func (m MockSSOStore) SaveLoginState(ctx context.Context, state string, s *SSOLoginState, ttl time.Duration) error {
+	return nil
+}
+
+func (m MockSSOStore) ConsumeLoginState(ctx context.Context, state string) (*SSOLoginState, error) {
+	return nil, fmt.Errorf("invalid or expired state")
+}
+
+// mock permission store for test purpose
+type MockPermissionStore struct{}
+
+func (m MockPermissionStore) GetPermissionsByRoleIDs(ctx context.Context, roleIDs []string) (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+func (m MockPermissionStore) SetRolePermissions(ctx context.Context, roleID string, permissions []string) error {
+	return nil
+}
+
+// mock api key store for test purpose
+type MockAPIKeyStore struct{}
+
+func (m MockAPIKeyStore) GetAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	return []*APIKey{}, nil
+}
+
+func (m MockAPIKeyStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	return nil, fmt.Errorf("api key not found")
+}
+
+func (m MockAPIKeyStore) CreateAPIKey(ctx context.Context, k *APIKey) error {
+	return nil
+}
+
+func (m MockAPIKeyStore) RevokeAPIKey(ctx context.Context, id string) error {
+	return fmt.Errorf("api key not found")
+}
+
+func (m MockAPIKeyStore) TouchAPIKey(ctx context.Context, id string) error {
+	return nil
+}
+
+// mock audit store for test purpose
+type MockAuditStore struct{}
+
+func (m MockAuditStore) GetAuditLogs(ctx context.Context, f *AuditFilter, page int) ([]*AuditLog, int64, error) {
+	return []*AuditLog{}, 0, nil
+}
+
+func (m MockAuditStore) CreateAuditLog(ctx context.Context, l *AuditLog) error {
+	return nil
+}
+
+func (m MockAuditStore) DeleteAuditLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
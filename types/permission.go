@@ -0,0 +1,73 @@
+package types
+
+import (
+	"context"
+	"slices"
+)
+
+// permission is "resource:action", attached to roles through role_permissions table.
+const (
+	PermBooksRead          = "books:read"
+	PermBooksWrite         = "books:write"
+	PermMembersRead        = "members:read"
+	PermMembersWrite       = "members:write"
+	PermMembersPrivacy     = "members:privacy" // export and anonymize the member data
+	PermCirculationsRead   = "circulations:read"
+	PermCirculationsWrite  = "circulations:write"
+	PermCirculationsReturn = "circulations:return"
+	PermUsersAdmin         = "users:admin"
+	PermRolesAdmin         = "roles:admin"
+	PermAPIKeysAdmin       = "api_keys:admin"
+	PermAuditRead          = "audit:read"
+	PermSearch             = "search:read"
+	PermFilesRead          = "files:read"
+)
+
+// all permissions which can be given to role, the new permission must be added here.
+var Permissions = []string{
+	PermBooksRead,
+	PermBooksWrite,
+	PermMembersRead,
+	PermMembersWrite,
+	PermMembersPrivacy,
+	PermCirculationsRead,
+	PermCirculationsWrite,
+	PermCirculationsReturn,
+	PermUsersAdmin,
+	PermRolesAdmin,
+	PermAPIKeysAdmin,
+	PermAuditRead,
+	PermSearch,
+	PermFilesRead,
+}
+
+// permissions of the built-in roles, same as the role gate before. it's used when the role is created without permissions.
+var DefaultRolePermissions = map[string][]string{
+	"admin": Permissions,
+	"staff": {
+		PermBooksRead, PermBooksWrite,
+		PermMembersRead, PermMembersWrite,
+		PermCirculationsRead, PermCirculationsWrite, PermCirculationsReturn,
+		PermSearch, PermFilesRead,
+	},
+	"user": {PermBooksRead, PermSearch, PermFilesRead},
+}
+
+func IsValidPermission(permission string) bool {
+	return slices.Contains(Permissions, permission)
+}
+
+// api key can't have the admin, audit and member privacy permissions, so a leaked key can't make itself admin or export the personal data.
+func IsValidAPIKeyPermission(permission string) bool {
+	return IsValidPermission(permission) && !slices.Contains([]string{PermUsersAdmin, PermRolesAdmin, PermAPIKeysAdmin, PermAuditRead, PermMembersPrivacy}, permission)
+}
+
+type PermissionStore interface {
+	// permissions of each role, keyed by role id.
+	GetPermissionsByRoleIDs(ctx context.Context, roleIDs []string) (map[string][]string, error)
+	SetRolePermissions(ctx context.Context, roleID string, permissions []string) error
+}
+
+type SetPayloadRolePermissions struct {
+	Permissions []string `form:"permissions" validate:"dive,required"`
+}
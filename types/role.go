@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
@@ -34,3 +35,45 @@ type SetPayloadUpdateRole struct {
 
 // relation many to many with users.
 type Roles []Role
+
+// role id and name from db can be joined with ", " (GROUP_CONCAT), so split it into one role per item.
+func (r Roles) Split() Roles {
+	split := make(Roles, 0, len(r))
+
+	for _, role := range r {
+		ids := strings.Split(role.ID, ", ")
+		names := strings.Split(role.Name, ", ")
+
+		for i, name := range names {
+			item := Role{CreatedAt: role.CreatedAt, UpdatedAt: role.UpdatedAt, Name: name}
+
+			if i < len(ids) {
+				item.ID = ids[i]
+			}
+
+			split = append(split, item)
+		}
+	}
+
+	return split
+}
+
+func (r Roles) Names() []string {
+	names := make([]string, 0, len(r))
+
+	for _, role := range r.Split() {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
+
+func (r Roles) IDs() []string {
+	ids := make([]string, 0, len(r))
+
+	for _, role := range r.Split() {
+		ids = append(ids, role.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,50 @@
+package types
+
+import "context"
+
+// search backend used by the websocket handlers, meilisearch or in-process index.
+type SearchIndex interface {
+	Configure(ctx context.Context, index string, settings *SearchSettings) error
+	AddDocuments(ctx context.Context, index, primaryKey string, docs any) error
+	DeleteDocuments(ctx context.Context, index string, ids []string) error
+	// the primary keys of all documents at index, empty when the index isn't created yet.
+	DocumentIDs(ctx context.Context, index string) ([]string, error)
+	Search(ctx context.Context, index, query string, req *SearchRequest) (*SearchResult, error)
+}
+
+// attributes that can be used in filter, facets and sort. it should be set at startup.
+type SearchSettings struct {
+	FilterableAttributes []string
+	SortableAttributes   []string
+}
+
+type SearchRequest struct {
+	Filters    []SearchFilter
+	Sort       []string // attribute:asc or attribute:desc, ex: tahun:desc
+	Facets     []string
+	Attributes []string // attributes returned at hits, empty mean all
+
+	Limit  int64
+	Offset int64
+}
+
+// one condition of filter, all filters in request are joined with AND.
+type SearchFilter struct {
+	Value any
+
+	Field    string
+	Operator string // =, !=, >, >=, <, <=
+}
+
+// same json shape as meilisearch search response, so clients didn't need to care which backend is used.
+type SearchResult struct {
+	Hits              []map[string]any            `json:"hits"`
+	FacetDistribution map[string]map[string]int64 `json:"facetDistribution,omitempty"`
+
+	Query string `json:"query"`
+
+	Limit              int64 `json:"limit"`
+	Offset             int64 `json:"offset"`
+	EstimatedTotalHits int64 `json:"estimatedTotalHits"`
+	ProcessingTimeMs   int64 `json:"processingTimeMs"`
+}
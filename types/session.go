@@ -0,0 +1,30 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// one login at one device, the refresh tokens of the login are rotated inside the session.
+type Session struct {
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Device    string `json:"device"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+
+	Current bool `json:"current"` // session of the token which request it
+}
+
+type SessionStore interface {
+	GetSessionsByUserID(ctx context.Context, userID string) ([]*Session, error)
+	GetSessionByID(ctx context.Context, id string) (*Session, error)
+
+	CreateSession(ctx context.Context, s *Session, ttl time.Duration) error
+	ExtendSession(ctx context.Context, id string, ttl time.Duration) error
+	TouchSession(ctx context.Context, id string) error
+	DeleteSession(ctx context.Context, id string) error
+}
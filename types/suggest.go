@@ -0,0 +1,15 @@
+package types
+
+import "context"
+
+// one suggestion at autocomplete, the text is the value of attribute which match the query.
+type Suggestion struct {
+	Text      string `json:"text"`
+	Attribute string `json:"attribute"`
+	Resource  string `json:"resource"`
+}
+
+type SuggestStore interface {
+	GetSuggestions(ctx context.Context, key string) ([]*Suggestion, error)
+	SetSuggestions(ctx context.Context, key string, s []*Suggestion) error
+}
@@ -0,0 +1,39 @@
+package types
+
+import "context"
+
+type TwoFactorStore interface {
+	// the secret can be pending (enabled is false) between setup and enable.
+	GetTOTPSecret(ctx context.Context, userID string) (string, bool, error)
+	SetPendingTOTPSecret(ctx context.Context, userID, secret string) error
+
+	EnableTOTP(ctx context.Context, userID string, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID string) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+
+	// the code of one time step only can be used once.
+	MarkTOTPStepUsed(ctx context.Context, userID string, step int64) (bool, error)
+}
+
+// totp setup info, the qr code is png data url of the otpauth uri.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode string `json:"qr_code"`
+}
+
+type SetPayloadTwoFactorCode struct {
+	Code string `form:"code" validate:"required"`
+}
+
+type SetPayloadLoginTwoFactor struct {
+	Challenge string `form:"challenge" validate:"required"`
+	Code      string `form:"code" validate:"required"` // totp code or recovery code
+}
+
+type SetPayloadDisableTwoFactor struct {
+	Password string `form:"password" validate:"required"`
+	Code     string `form:"code" validate:"required"`
+}
@@ -10,6 +10,10 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at,omitzero"`
 	Roles     Roles     `json:"roles"`
 
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // nil mean the email isn't verified yet
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
@@ -31,6 +35,10 @@ type UserStore interface {
 	DeleteUser(ctx context.Context, id string) error
 
 	IncrementTokenVersion(ctx context.Context, id, token string) error
+
+	VerifyUserEmail(ctx context.Context, id string) error
+	UpdateUserPassword(ctx context.Context, id, password string) error
+	UpdateUserProfile(ctx context.Context, id, name, avatar string) error // only the fields which the user can change by itself
 }
 
 type SetPayloadLogin struct {
@@ -49,3 +57,25 @@ type SetPayloadUpdateUser struct {
 	Email    string `form:"email" validate:"omitempty,required,email"`
 	Password string `form:"password" validate:"omitempty,required,min=6"`
 }
+
+type SetPayloadUpdateProfile struct {
+	Name string `form:"name" validate:"omitempty,min=3,max=100"`
+}
+
+type SetPayloadChangePassword struct {
+	CurrentPassword string `form:"current_password" validate:"required"`
+	Password        string `form:"password" validate:"required,min=6,nefield=CurrentPassword"`
+}
+
+type SetPayloadForgotPassword struct {
+	Email string `form:"email" validate:"required,email"`
+}
+
+type SetPayloadResetPassword struct {
+	Token    string `form:"token" validate:"required"`
+	Password string `form:"password" validate:"required,min=6"`
+}
+
+type SetPayloadVerifyEmail struct {
+	Token string `form:"token" validate:"required"`
+}
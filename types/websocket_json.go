@@ -1,9 +1,72 @@
 package types
 
-type SetPayloadQuery struct {
-	QueryUser        string `json:"search_u"` // user search input
-	QueryRole        string `json:"search_r"` // role search input
-	QueryMember      string `json:"search_m"` // member search input
-	QueryBook        string `json:"search_b"` // book search input
-	QueryCirculation string `json:"search_c"` // circulation search input
+// message from client at /ws/search. one socket for all the resources.
+type SetPayloadSearch struct {
+	ID       string `json:"id"`       // request id, it will be sent back at the response
+	Type     string `json:"type"`     // search, global, or cancel
+	Resource string `json:"resource"` // users, roles, members, books, circulations. only for type search
+	Query    string `json:"query"`
+
+	BookFilter SetPayloadBookFilter `json:"filter"` // only for resource books
+
+	Sort   []string `json:"sort"`   // ex: ["tahun:desc"]
+	Facets []string `json:"facets"` // ex: ["kategori", "bahasa"]
+
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+}
+
+// message from server at /ws/search.
+type SearchMessage struct {
+	Data any `json:"data,omitempty"` // *SearchResult for type result, map[resource]*SearchResult for type global
+
+	ID       string `json:"id"`
+	Type     string `json:"type"` // result, global, or error
+	Resource string `json:"resource,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	Page     int   `json:"page,omitempty"`
+	LastPage int64 `json:"last_page,omitempty"`
+}
+
+type SetPayloadBookFilter struct {
+	Penulis  string `json:"penulis"`
+	Kategori string `json:"kategori"`
+	Bahasa   string `json:"bahasa"`
+
+	TahunMin int `json:"tahun_min"`
+	TahunMax int `json:"tahun_max"`
+
+	Tersedia *bool `json:"tersedia"` // nil mean doesn't filter the availability
+}
+
+// check the book filter has one of the field filled.
+func (f SetPayloadBookFilter) IsEmpty() bool {
+	return f.Penulis == "" && f.Kategori == "" && f.Bahasa == "" && f.TahunMin == 0 && f.TahunMax == 0 && f.Tersedia == nil
+}
+
+// convert the book filter payload into search filters.
+func (f SetPayloadBookFilter) ToSearchFilters() []SearchFilter {
+	filters := make([]SearchFilter, 0)
+
+	if f.Penulis != "" {
+		filters = append(filters, SearchFilter{Field: "penulis", Operator: "=", Value: f.Penulis})
+	}
+	if f.Kategori != "" {
+		filters = append(filters, SearchFilter{Field: "kategori", Operator: "=", Value: f.Kategori})
+	}
+	if f.Bahasa != "" {
+		filters = append(filters, SearchFilter{Field: "bahasa", Operator: "=", Value: f.Bahasa})
+	}
+	if f.TahunMin != 0 {
+		filters = append(filters, SearchFilter{Field: "tahun", Operator: ">=", Value: f.TahunMin})
+	}
+	if f.TahunMax != 0 {
+		filters = append(filters, SearchFilter{Field: "tahun", Operator: "<=", Value: f.TahunMax})
+	}
+	if f.Tersedia != nil {
+		filters = append(filters, SearchFilter{Field: "tersedia", Operator: "=", Value: *f.Tersedia})
+	}
+
+	return filters
 }
@@ -5,7 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -13,9 +15,11 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/perpus_backend/config"
+	"github.com/perpus_backend/pkg/cors"
 
 	"github.com/bytedance/sonic"
 	"github.com/go-playground/validator/v10"
@@ -31,11 +35,19 @@ var (
 		meilisearch.WithContentEncoding(meilisearch.GzipEncoding, meilisearch.BestCompression))
 
 	WSUpgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin: checkWebSocketOrigin,
 	}
 
+	// same allowed origins as cors, the invalid pattern is rejected when the server start.
+	wsOrigins = sync.OnceValue(func() *cors.Matcher {
+		m, err := cors.NewMatcher(config.Env.CORSOrigins)
+		if err != nil {
+			return nil
+		}
+
+		return m
+	})
+
 	Validate = validator.New(validator.WithRequiredStructEnabled()) // validate the request input.
 )
 
@@ -127,6 +139,62 @@ func GetTokenFromRequest(r *http.Request) string {
 	return ""
 }
 
+// the websocket handshake isn't protected by cors, so the browser at other site could open it with the user cookie.
+// only the same host and the allowed origins can connect, the client without Origin (not browser) is allowed.
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	m := wsOrigins()
+
+	return m != nil && m.Match(origin)
+}
+
+// ip address of the client from the connection.
+func GetClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// rough device name from user agent, for list of sessions.
+func DeviceFromUserAgent(ua string) string {
+	ua = strings.ToLower(ua)
+
+	switch {
+	case ua == "":
+		return "Unknown"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "iphone"):
+		return "iPhone"
+	case strings.Contains(ua, "ipad"):
+		return "iPad"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	default:
+		return "Unknown"
+	}
+}
+
 func IsItInBaseDir(path, baseDir string) bool {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -170,16 +238,11 @@ func ParseStringToFormatDate(date string) time.Time {
 	return d
 }
 
-// this was support names: admin, staff, and user. out of that, it should be invalid.
-func IsInputRoleNameWasValid(name string) bool {
-	roleNamesMap := map[string]struct{}{
-		"admin": {},
-		"staff": {},
-		"user":  {},
-	}
+// role name is custom, but it's used as the config value (ex: TWO_FACTOR_ROLES) so only simple lowercase name.
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{2,31}$`)
 
-	_, exist := roleNamesMap[name]
-	return exist
+func IsInputRoleNameWasValid(name string) bool {
+	return roleNamePattern.MatchString(name)
 }
 
 func IsValidSortColumn(column string) bool {